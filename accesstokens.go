@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/hatrnuhn/chirpy-webserver/internal/auth"
+	"github.com/hatrnuhn/chirpy-webserver/internal/database"
+)
+
+// how long personal access tokens can last at most, in seconds
+const maxPATTTL = 365 * 24 * 60 * 60
+
+type patResponse struct {
+	ID        int      `json:"id"`
+	Name      string   `json:"name"`
+	Scopes    []string `json:"scopes"`
+	CreatedAt int64    `json:"created_at"`
+	ExpiresAt int64    `json:"expires_at,omitempty"`
+	// only set when the token is created
+	Token string `json:"token,omitempty"`
+}
+
+func newPATResponse(pat database.PersonalAccessToken) patResponse {
+	return patResponse{
+		ID:        pat.ID,
+		Name:      pat.Name,
+		Scopes:    pat.Scopes,
+		CreatedAt: pat.CreatedAt,
+		ExpiresAt: pat.ExpiresAt,
+	}
+}
+
+// mints a named personal access token for the authenticated user, the token is only ever shown in this response
+func (cfg *apiConfig) handlePostUsersMeTokens(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	uID, err := cfg.authUserID(r)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	dat, err := io.ReadAll(r.Body)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "couldn't read request")
+		return
+	}
+
+	req := struct {
+		Name   string   `json:"name"`
+		Scopes []string `json:"scopes"`
+		// 0 for a token that never expires
+		Exp int64 `json:"expires_in_seconds"`
+	}{}
+	err = json.Unmarshal(dat, &req)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "couldn't unmarshal request")
+		return
+	}
+
+	if req.Name == "" || len(req.Name) > 100 {
+		respondWithError(w, http.StatusBadRequest, "name is required and must be at most 100 characters")
+		return
+	}
+
+	if len(req.Scopes) == 0 {
+		respondWithError(w, http.StatusBadRequest, "at least one scope is required")
+		return
+	}
+
+	scopes := make([]string, 0, len(req.Scopes))
+	seen := make(map[string]bool)
+	for _, s := range req.Scopes {
+		if !auth.ValidScope(s) {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("unknown scope: %s", s))
+			return
+		}
+		if !seen[s] {
+			seen[s] = true
+			scopes = append(scopes, s)
+		}
+	}
+
+	if req.Exp < 0 {
+		respondWithError(w, http.StatusBadRequest, "expires_in_seconds can't be negative")
+		return
+	}
+
+	if req.Exp > maxPATTTL {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("expires_in_seconds can't be more than %d", maxPATTTL))
+		return
+	}
+
+	token, err := auth.CreatePersonalAccessToken()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("couldn't create token: %s", err.Error()))
+		return
+	}
+
+	now := time.Now()
+	var expiresAt int64
+	if req.Exp > 0 {
+		expiresAt = now.Add(time.Duration(req.Exp) * time.Second).Unix()
+	}
+
+	pat, err := cfg.db.CreatePersonalAccessToken(database.PersonalAccessToken{
+		UserID:    uID,
+		Name:      req.Name,
+		Scopes:    scopes,
+		Hash:      auth.HashToken(token),
+		CreatedAt: now.Unix(),
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("couldn't write token to db: %s", err.Error()))
+		return
+	}
+
+	resp := newPATResponse(pat)
+	resp.Token = token
+	respondWithJSON(w, http.StatusCreated, resp)
+}
+
+// lists the authenticated user's personal access tokens
+func (cfg *apiConfig) handleGetUsersMeTokens(w http.ResponseWriter, r *http.Request) {
+	uID, err := cfg.authUserID(r)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	pats, err := cfg.db.GetPersonalAccessTokens(uID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't get tokens")
+		return
+	}
+
+	resp := make([]patResponse, 0, len(pats))
+	for _, pat := range pats {
+		resp = append(resp, newPATResponse(pat))
+	}
+
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+// revokes one of the authenticated user's personal access tokens
+func (cfg *apiConfig) handleDelUsersMeTokenID(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "tokenID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "bad url")
+		return
+	}
+
+	uID, err := cfg.authUserID(r)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	err = cfg.db.DeletePersonalAccessToken(uID, id)
+	if errors.Is(err, database.ErrNotExist) {
+		respondWithError(w, http.StatusNotFound, fmt.Sprintf("token with id: %d is not found", id))
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't delete token")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("OK"))
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/hatrnuhn/chirpy-webserver/internal/auth"
+)
+
+func TestPostUsersMeTokensExpiry(t *testing.T) {
+	cfg := newTestConfig(t)
+	h := cfg.routes()
+	u := createTestUser(t, cfg, "walt@example.com")
+	token := testAccessToken(t, cfg, u.ID)
+
+	// large enough to overflow a time.Duration in nanoseconds
+	for _, exp := range []int64{-1, maxPATTTL + 1, 1 << 62} {
+		w := doRequest(t, h, http.MethodPost, "/api/users/me/tokens", token, map[string]any{"name": "ci", "scopes": []string{auth.ScopeProfileRead}, "expires_in_seconds": exp})
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expires_in_seconds %d: got %d %s, want 400", exp, w.Code, w.Body.String())
+		}
+	}
+
+	w := doRequest(t, h, http.MethodPost, "/api/users/me/tokens", token, map[string]any{"name": "ci", "scopes": []string{auth.ScopeProfileRead}, "expires_in_seconds": maxPATTTL})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("got %d %s, want 201", w.Code, w.Body.String())
+	}
+
+	pat := patResponse{}
+	decodeBody(t, w, &pat)
+	if want := time.Now().Unix() + maxPATTTL; pat.ExpiresAt < want-60 || pat.ExpiresAt > want {
+		t.Errorf("got expires_at %d, want about %d", pat.ExpiresAt, want)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/hatrnuhn/chirpy-webserver/internal/database"
+)
+
+// how recently users without a password of their own must have signed in through the OIDC provider to
+// delete their account
+const oidcReauthWindow = 5 * time.Minute
+
+// deletes the user's account after they confirm their password, their TOTP or recovery code, or for users
+// who sign in through the OIDC provider, a sign in that recent. see database.DeleteUserData for what's kept
+func (cfg *apiConfig) handleDelUsersMe(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	uID, err := cfg.authUserID(r)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	dat, err := io.ReadAll(r.Body)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "couldn't read request")
+		return
+	}
+
+	req := struct {
+		Password     string `json:"password"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}{}
+	err = json.Unmarshal(dat, &req)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "couldn't unmarshal request")
+		return
+	}
+
+	user, err := cfg.db.GetUser(uID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("couldn't get user: %s", err.Error()))
+		return
+	}
+
+	// a stolen AJWT alone isn't enough to delete the account. users created through OIDC never got to
+	// know their password, so they sign in through the provider again instead
+	switch {
+	case req.Password != "":
+		_, err = cfg.verifyCredentials(r, user.Email, req.Password)
+	case req.Code != "" || req.RecoveryCode != "":
+		if !user.TOTPEnabled {
+			respondWithError(w, http.StatusBadRequest, "two-factor sign in isn't enabled")
+			return
+		}
+		err = cfg.verifySecondFactor(r, user, req.Code, req.RecoveryCode)
+	case user.OIDCSubject != "":
+		if time.Since(time.Unix(user.OIDCAuthAt, 0)) > oidcReauthWindow {
+			respondWithError(w, http.StatusUnauthorized, "password, TOTP code or a recent sign in through the OIDC provider is required")
+			return
+		}
+	default:
+		respondWithError(w, http.StatusBadRequest, "password is required")
+		return
+	}
+	if err != nil {
+		respondWithLoginError(w, err)
+		return
+	}
+
+	exports, media, err := cfg.db.DeleteUserData(uID)
+	if errors.Is(err, database.ErrNotExist) {
+		respondWithError(w, http.StatusNotFound, "user doesn't exist anymore")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("couldn't delete user: %s", err.Error()))
+		return
+	}
+
+	for _, e := range exports {
+		removeExportArchive(e)
+	}
+	cfg.removeMediaBlobs(r.Context(), media)
+
+	if usesSessionCookies(r) {
+		cfg.clearSessionCookies(w)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("OK"))
+}
+
+func removeExportArchive(e database.DataExport) {
+	if e.Path == "" {
+		return
+	}
+
+	err := os.Remove(e.Path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("couldn't remove data export %s: %s", e.ID, err.Error())
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/hatrnuhn/chirpy-webserver/internal/auth"
+	"github.com/hatrnuhn/chirpy-webserver/internal/database"
+)
+
+func TestDelUsersMe(t *testing.T) {
+	cfg := newTestConfig(t)
+	h := cfg.routes()
+
+	u := createTestUser(t, cfg, "walt@example.com")
+	_, err := cfg.db.CreateChirp(database.Chirp{Body: "say my name"}, u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := testAccessToken(t, cfg, u.ID)
+
+	w := doRequest(t, h, http.MethodDelete, "/api/users/me", token, map[string]string{})
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("without a password got %d %q, want %d", w.Code, w.Body.String(), http.StatusBadRequest)
+	}
+
+	w = doRequest(t, h, http.MethodDelete, "/api/users/me", token, map[string]string{"password": "not-the-password"})
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("with a wrong password got %d %q, want %d", w.Code, w.Body.String(), http.StatusUnauthorized)
+	}
+
+	w = doRequest(t, h, http.MethodDelete, "/api/users/me", token, map[string]string{"password": testPassword})
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d %q, want %d", w.Code, w.Body.String(), http.StatusOK)
+	}
+
+	deleted, err := cfg.db.GetUser(u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted.DeletedAt == 0 {
+		t.Error("user isn't marked deleted")
+	}
+
+	chirps, err := cfg.db.GetChirpsByAuthID(u.ID, "asc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chirps) != 0 {
+		t.Errorf("got chirps %+v of the deleted user, want none", chirps)
+	}
+
+	w = doRequest(t, h, http.MethodPost, "/api/login", "", map[string]string{"email": u.Email, "password": testPassword})
+	if w.Code == http.StatusOK {
+		t.Error("deleted user could still log in")
+	}
+}
+
+func TestDelUsersMeOIDC(t *testing.T) {
+	cfg := newTestConfig(t)
+	h := cfg.routes()
+
+	// the password is random, the user never learns it
+	u, _, err := cfg.db.LinkOIDCUser("https://idp.test", "subject-1", "jesse@example.com", "unknown-Random-password-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := testAccessToken(t, cfg, u.ID)
+
+	stale := u
+	stale.OIDCAuthAt = time.Now().Add(-oidcReauthWindow - time.Minute).Unix()
+	_, err = cfg.db.UpdateUser(&stale, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := doRequest(t, h, http.MethodDelete, "/api/users/me", token, map[string]string{})
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("with a stale sign in got %d %q, want %d", w.Code, w.Body.String(), http.StatusUnauthorized)
+	}
+
+	// signing in through the provider again
+	_, _, err = cfg.db.LinkOIDCUser("https://idp.test", "subject-1", "jesse@example.com", "another-Random-password-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w = doRequest(t, h, http.MethodDelete, "/api/users/me", token, map[string]string{})
+	if w.Code != http.StatusOK {
+		t.Fatalf("with a fresh sign in got %d %q, want %d", w.Code, w.Body.String(), http.StatusOK)
+	}
+}
+
+func TestDelUsersMeTOTP(t *testing.T) {
+	cfg := newTestConfig(t)
+	h := cfg.routes()
+
+	u := createTestUser(t, cfg, "walt@example.com")
+	token := testAccessToken(t, cfg, u.ID)
+
+	w := doRequest(t, h, http.MethodDelete, "/api/users/me", token, map[string]string{"code": "123456"})
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("without 2FA enabled got %d %q, want %d", w.Code, w.Body.String(), http.StatusBadRequest)
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	u.TOTPSecret = secret
+	u.TOTPEnabled = true
+	_, err = cfg.db.UpdateUser(&u, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, err := auth.TOTPCode(secret, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w = doRequest(t, h, http.MethodDelete, "/api/users/me", token, map[string]string{"code": code})
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d %q, want %d", w.Code, w.Body.String(), http.StatusOK)
+	}
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+	"github.com/hatrnuhn/chirpy-webserver/internal/audit"
+	"github.com/hatrnuhn/chirpy-webserver/internal/auth"
+	"github.com/hatrnuhn/chirpy-webserver/internal/database"
+)
+
+// how long impersonation AJWTs last at most, in seconds
+const impersonationTTL = 15 * 60
+
+// sets the role of a user, admins can't demote themselves so there's always one left
+func (cfg *apiConfig) handlePutAdminUserRole(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	id, err := strconv.Atoi(chi.URLParam(r, "userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "bad url")
+		return
+	}
+
+	dat, err := io.ReadAll(r.Body)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "couldn't read request")
+		return
+	}
+
+	req := struct {
+		Role string `json:"role"`
+	}{}
+	err = json.Unmarshal(dat, &req)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "couldn't unmarshal request")
+		return
+	}
+
+	if !auth.ValidRole(req.Role) {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("unknown role: %s", req.Role))
+		return
+	}
+
+	if p, ok := principalFromContext(r); ok && p.UserID == id && req.Role != auth.RoleAdmin {
+		respondWithError(w, http.StatusBadRequest, "admins can't demote themselves")
+		return
+	}
+
+	user, err := cfg.db.GetUser(id)
+	if errors.Is(err, database.ErrNotExist) {
+		respondWithError(w, http.StatusNotFound, fmt.Sprintf("user with id: %d is not found", id))
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't get user")
+		return
+	}
+
+	user.Role = req.Role
+	_, err = cfg.db.UpdateUser(&user, false)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("couldn't update user: %s", err.Error()))
+		return
+	}
+
+	// tokens carry the role, so the old ones mustn't outlive it
+	err = cfg.logOutEverywhere(user.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("couldn't revoke tokens: %s", err.Error()))
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, struct {
+		ID    int    `json:"id"`
+		Email string `json:"email"`
+		Role  string `json:"role"`
+	}{
+		ID:    user.ID,
+		Email: user.Email,
+		Role:  user.Role,
+	})
+}
+
+// lifts the login lockout of a user's account
+func (cfg *apiConfig) handlePostAdminUserUnlock(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "bad url")
+		return
+	}
+
+	user, err := cfg.db.GetUser(id)
+	if errors.Is(err, database.ErrNotExist) {
+		respondWithError(w, http.StatusNotFound, fmt.Sprintf("user with id: %d is not found", id))
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't get user")
+		return
+	}
+
+	cfg.accountThrottle.Reset(loginAccountKey(user.Email))
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("OK"))
+}
+
+// mints a short-lived AJWT for the admin to act as a user with, to see what they see. it's turned away from
+// account settings and admin routes, and every request made with it ends up in the audit log
+func (cfg *apiConfig) handlePostAdminUserImpersonate(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	id, err := strconv.Atoi(chi.URLParam(r, "userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "bad url")
+		return
+	}
+
+	dat, err := io.ReadAll(r.Body)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "couldn't read request")
+		return
+	}
+
+	req := struct {
+		Exp int `json:"expires_in_seconds"`
+	}{}
+	if len(dat) != 0 {
+		err = json.Unmarshal(dat, &req)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "couldn't unmarshal request")
+			return
+		}
+	}
+
+	if req.Exp <= 0 || req.Exp > impersonationTTL {
+		req.Exp = impersonationTTL
+	}
+
+	p, ok := principalFromContext(r)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "couldn't authenticate admin")
+		return
+	}
+
+	if p.UserID == id {
+		respondWithError(w, http.StatusBadRequest, "admins can't impersonate themselves")
+		return
+	}
+
+	user, err := cfg.db.GetUser(id)
+	if errors.Is(err, database.ErrNotExist) || (err == nil && user.DeletedAt != 0) {
+		respondWithError(w, http.StatusNotFound, fmt.Sprintf("user with id: %d is not found", id))
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't get user")
+		return
+	}
+
+	if auth.HasRole(user.Role, auth.RoleAdmin) {
+		respondWithError(w, http.StatusForbidden, "admins can't be impersonated")
+		return
+	}
+
+	aToken, jti, err := auth.CreateImpersonationToken(user.ID, user.Role, auth.PlanOf(user.IsChirpyRed), user.TokenGeneration, p.UserID, cfg.jwtSecret, int64(req.Exp))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("couldn't create access token: %s", err.Error()))
+		return
+	}
+
+	cfg.auditLog(audit.Entry{
+		Event:     "impersonation_started",
+		ActorID:   p.UserID,
+		SubjectID: user.ID,
+		TokenID:   jti,
+		Method:    r.Method,
+		Path:      r.URL.Path,
+		Status:    http.StatusCreated,
+		RemoteIP:  remoteIP(r),
+	})
+
+	respondWithJSON(w, http.StatusCreated, struct {
+		UserID    int    `json:"user_id"`
+		AToken    string `json:"access_token"`
+		ExpiresIn int    `json:"expires_in"`
+	}{
+		UserID:    user.ID,
+		AToken:    aToken,
+		ExpiresIn: req.Exp,
+	})
+}
+
+// makes the user registered with email an admin, creating it with password if it doesn't exist yet.
+// it's how the first admin comes to be, every other one can be promoted through /admin/users/{userID}/role
+func bootstrapAdmin(db *database.DB, email, password string) error {
+	user, err := db.GetUserByEmail(email)
+	if errors.Is(err, database.ErrNotExist) {
+		if password == "" {
+			return errors.New("ADMIN_PASSWORD is required to create the admin user")
+		}
+
+		body, err := json.Marshal(struct {
+			Email    string `json:"email"`
+			Password string `json:"password"`
+		}{
+			Email:    email,
+			Password: password,
+		})
+		if err != nil {
+			return err
+		}
+
+		user, err = db.CreateUser(string(body))
+		if err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+
+	if user.Role == auth.RoleAdmin {
+		return nil
+	}
+
+	user.Role = auth.RoleAdmin
+	_, err = db.UpdateUser(&user, false)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("%s is now an admin", email)
+	return nil
+}
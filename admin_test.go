@@ -0,0 +1,72 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/hatrnuhn/chirpy-webserver/internal/auth"
+)
+
+func TestBootstrapAdmin(t *testing.T) {
+	cfg := newTestConfig(t)
+
+	err := bootstrapAdmin(cfg.db, "gus@example.com", "")
+	if err == nil {
+		t.Fatal("created an admin without a password")
+	}
+
+	err = bootstrapAdmin(cfg.db, "gus@example.com", testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u, err := cfg.db.GetUserByEmail("gus@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Role != auth.RoleAdmin {
+		t.Errorf("got role %q, want %q", u.Role, auth.RoleAdmin)
+	}
+	loginTestUser(t, cfg.routes(), u.Email)
+
+	// running it again on every start leaves the admin as it is
+	err = bootstrapAdmin(cfg.db, "gus@example.com", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	users, err := cfg.db.GetUsers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	admins := 0
+	for _, u := range users {
+		if u.Email == "gus@example.com" {
+			admins++
+		}
+	}
+	if admins != 1 {
+		t.Errorf("got %d users with the admin's email, want 1", admins)
+	}
+}
+
+func TestBootstrapAdminPromotes(t *testing.T) {
+	cfg := newTestConfig(t)
+	u := createTestUser(t, cfg, "walt@example.com")
+
+	if u.Role != auth.RoleUser {
+		t.Errorf("new user got role %q, want %q", u.Role, auth.RoleUser)
+	}
+
+	err := bootstrapAdmin(cfg.db, u.Email, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u, err = cfg.db.GetUser(u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Role != auth.RoleAdmin {
+		t.Errorf("got role %q, want %q", u.Role, auth.RoleAdmin)
+	}
+}
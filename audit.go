@@ -0,0 +1,87 @@
+package main
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/hatrnuhn/chirpy-webserver/internal/audit"
+	"github.com/hatrnuhn/chirpy-webserver/internal/auth"
+)
+
+// records the status a handler responded with
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (rec *statusRecorder) WriteHeader(code int) {
+	if rec.status == 0 {
+		rec.status = code
+	}
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	return rec.ResponseWriter.Write(b)
+}
+
+// writes every request made with an impersonation AJWT to the audit log, whether or not it's let through
+func (cfg *apiConfig) middlewareAuditImpersonation(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokenString, err := auth.GetAuthHeadToken(r, "Bearer")
+		if err != nil || auth.IsPersonalAccessToken(tokenString) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token, err := auth.ParseToken(tokenString, cfg.jwtSecret)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		claims, ok := token.Claims.(*auth.Claims)
+		if !ok || claims.Actor == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		actorID, _ := strconv.Atoi(claims.Actor.Subject)
+		subjectID, _ := strconv.Atoi(claims.Subject)
+		cfg.auditLog(audit.Entry{
+			Event:     "impersonated_request",
+			ActorID:   actorID,
+			SubjectID: subjectID,
+			TokenID:   claims.ID,
+			Method:    r.Method,
+			Path:      r.URL.Path,
+			Status:    rec.status,
+			RemoteIP:  remoteIP(r),
+		})
+	})
+}
+
+// failing to audit shouldn't fail the request, it's logged instead
+func (cfg *apiConfig) auditLog(e audit.Entry) {
+	err := cfg.audit.Log(e)
+	if err != nil {
+		log.Printf("couldn't write audit log: %s", err.Error())
+	}
+}
+
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/hatrnuhn/chirpy-webserver/internal/audit"
+	"github.com/hatrnuhn/chirpy-webserver/internal/auth"
+)
+
+// decodes the audit log lines written to buf
+func auditEntries(t *testing.T, buf *bytes.Buffer) []audit.Entry {
+	t.Helper()
+
+	entries := make([]audit.Entry, 0)
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+
+		e := audit.Entry{}
+		err := json.Unmarshal([]byte(line), &e)
+		if err != nil {
+			t.Fatalf("couldn't decode audit entry %q: %s", line, err)
+		}
+		entries = append(entries, e)
+	}
+
+	return entries
+}
+
+func TestImpersonation(t *testing.T) {
+	cfg := newTestConfig(t)
+	buf := &bytes.Buffer{}
+	cfg.audit = audit.NewLogger(buf)
+	h := cfg.routes()
+
+	admin := createTestUser(t, cfg, "gus@example.com")
+	setTestRole(t, cfg, admin.ID, auth.RoleAdmin)
+	u := createTestUser(t, cfg, "walt@example.com")
+
+	w := doRequest(t, h, http.MethodPost, fmt.Sprintf("/admin/users/%d/impersonate", u.ID), testAccessToken(t, cfg, admin.ID), map[string]int{
+		"expires_in_seconds": 24 * 3600,
+	})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("got %d %q, want %d", w.Code, w.Body.String(), http.StatusCreated)
+	}
+
+	resp := struct {
+		UserID      int    `json:"user_id"`
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}{}
+	decodeBody(t, w, &resp)
+	if resp.UserID != u.ID || resp.ExpiresIn != impersonationTTL {
+		t.Errorf("got user %d expiring in %ds, want user %d expiring in %ds", resp.UserID, resp.ExpiresIn, u.ID, impersonationTTL)
+	}
+
+	token, err := auth.ParseToken(resp.AccessToken, cfg.jwtSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims := token.Claims.(*auth.Claims)
+	if claims.Subject != fmt.Sprint(u.ID) || claims.Actor == nil || claims.Actor.Subject != fmt.Sprint(admin.ID) {
+		t.Fatalf("got subject %s and actor %+v, want user %d acted as by %d", claims.Subject, claims.Actor, u.ID, admin.ID)
+	}
+
+	// seeing what the user sees is fine, touching their account isn't
+	w = doRequest(t, h, http.MethodGet, "/api/users/me", resp.AccessToken, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("reading the profile got %d %q, want %d", w.Code, w.Body.String(), http.StatusOK)
+	}
+	w = doRequest(t, h, http.MethodDelete, "/api/users/me", resp.AccessToken, map[string]string{"password": testPassword})
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("deleting the account got %d %q, want %d", w.Code, w.Body.String(), http.StatusForbidden)
+	}
+
+	entries := auditEntries(t, buf)
+	want := []audit.Entry{
+		{Event: "impersonation_started", Method: http.MethodPost, Path: fmt.Sprintf("/admin/users/%d/impersonate", u.ID), Status: http.StatusCreated},
+		{Event: "impersonated_request", Method: http.MethodGet, Path: "/api/users/me", Status: http.StatusOK},
+		{Event: "impersonated_request", Method: http.MethodDelete, Path: "/api/users/me", Status: http.StatusForbidden},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("got audit entries %+v, want %d", entries, len(want))
+	}
+	for i, e := range entries {
+		if e.Event != want[i].Event || e.Method != want[i].Method || e.Path != want[i].Path || e.Status != want[i].Status {
+			t.Errorf("entry %d: got %+v, want %+v", i, e, want[i])
+		}
+		if e.ActorID != admin.ID || e.SubjectID != u.ID || e.TokenID != claims.ID || e.Time.IsZero() {
+			t.Errorf("entry %d: got %+v, want admin %d acting as %d with token %s", i, e, admin.ID, u.ID, claims.ID)
+		}
+	}
+}
+
+func TestImpersonationRefused(t *testing.T) {
+	cfg := newTestConfig(t)
+	buf := &bytes.Buffer{}
+	cfg.audit = audit.NewLogger(buf)
+	h := cfg.routes()
+
+	admin := createTestUser(t, cfg, "gus@example.com")
+	setTestRole(t, cfg, admin.ID, auth.RoleAdmin)
+	other := createTestUser(t, cfg, "lydia@example.com")
+	setTestRole(t, cfg, other.ID, auth.RoleAdmin)
+	mod := createTestUser(t, cfg, "hank@example.com")
+	setTestRole(t, cfg, mod.ID, auth.RoleModerator)
+	u := createTestUser(t, cfg, "walt@example.com")
+
+	tests := []struct {
+		name   string
+		token  string
+		target int
+		want   int
+	}{
+		{"themselves", testAccessToken(t, cfg, admin.ID), admin.ID, http.StatusBadRequest},
+		{"another admin", testAccessToken(t, cfg, admin.ID), other.ID, http.StatusForbidden},
+		{"missing user", testAccessToken(t, cfg, admin.ID), 999, http.StatusNotFound},
+		{"moderator", testAccessToken(t, cfg, mod.ID), u.ID, http.StatusForbidden},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := doRequest(t, h, http.MethodPost, fmt.Sprintf("/admin/users/%d/impersonate", tc.target), tc.token, nil)
+			if w.Code != tc.want {
+				t.Fatalf("got %d %q, want %d", w.Code, w.Body.String(), tc.want)
+			}
+		})
+	}
+
+	if entries := auditEntries(t, buf); len(entries) != 0 {
+		t.Errorf("got audit entries %+v for refused impersonations, want none", entries)
+	}
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hatrnuhn/chirpy-webserver/internal/auth"
+	"github.com/hatrnuhn/chirpy-webserver/internal/database"
+)
+
+var errMissingScope = errors.New("token isn't granted the required scope")
+var errTokenRevoked = errors.New("token was revoked")
+var errImpersonated = errors.New("not allowed while impersonating a user")
+var errSuspended = errors.New("account is suspended")
+
+// principal is who a request is authenticated as
+type principal struct {
+	UserID int
+	// scopes of the personal access token or OAuth AJWT, first party AJWTs are granted every scope
+	Scopes []string
+	// id of the personal access token the request was made with, 0 for AJWTs
+	PATID int
+	// OAuth client the AJWT was issued to, empty for AJWTs issued to chirpy's own clients
+	ClientID string
+	Role     string
+	// admin acting as the user through an impersonation AJWT, 0 otherwise
+	ActorID int
+}
+
+// reports whether the request was made with a credential the user got directly rather than a PAT or a third-party app
+func (p principal) firstParty() bool {
+	return p.PATID == 0 && p.ClientID == ""
+}
+
+type principalCtxKey struct{}
+
+// returns the principal middlewareRequireRole authenticated the request as
+func principalFromContext(r *http.Request) (principal, bool) {
+	p, ok := r.Context().Value(principalCtxKey{}).(principal)
+	return p, ok
+}
+
+func (p principal) hasScope(scope string) bool {
+	if p.firstParty() {
+		return true
+	}
+
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// authenticates requests to endpoints anyone can use like authenticate, but only if they carry an
+// Authorization header. anonymous requests get the zero principal, whose UserID is 0
+func (cfg *apiConfig) authenticateOptional(r *http.Request, scope string) (principal, error) {
+	if r.Header.Get("Authorization") == "" {
+		return principal{}, nil
+	}
+
+	return cfg.authenticate(r, scope)
+}
+
+// authenticates the request with an AJWT or a personal access token in its Authorization header,
+// and makes sure the credential is granted scope
+func (cfg *apiConfig) authenticate(r *http.Request, scope string) (principal, error) {
+	if csrfFailed(r) {
+		return principal{}, errCSRF
+	}
+
+	tokenString, err := auth.GetAuthHeadToken(r, "Bearer")
+	if err != nil {
+		return principal{}, err
+	}
+
+	var p principal
+	if auth.IsPersonalAccessToken(tokenString) {
+		p, err = cfg.authPAT(tokenString)
+	} else {
+		p, err = cfg.authAJWT(r)
+	}
+
+	if err != nil {
+		return principal{}, err
+	}
+
+	if !p.hasScope(scope) {
+		return principal{}, errMissingScope
+	}
+
+	return p, nil
+}
+
+func (cfg *apiConfig) authPAT(tokenString string) (principal, error) {
+	pat, err := cfg.db.GetPersonalAccessTokenByHash(auth.HashToken(tokenString))
+	if errors.Is(err, database.ErrNotExist) {
+		return principal{}, errors.New("invalid personal access token")
+	}
+	if err != nil {
+		return principal{}, err
+	}
+
+	if pat.ExpiresAt != 0 && pat.ExpiresAt < time.Now().Unix() {
+		return principal{}, errors.New("personal access token is expired")
+	}
+
+	user, err := cfg.db.GetUser(pat.UserID)
+	if err != nil {
+		return principal{}, errors.New("couldn't get token owner")
+	}
+	if user.SuspendedAt != 0 {
+		return principal{}, errSuspended
+	}
+
+	return principal{
+		UserID: pat.UserID,
+		Scopes: pat.Scopes,
+		PATID:  pat.ID,
+		Role:   user.Role,
+	}, nil
+}
+
+// authenticates the request with the AJWT in its Authorization header and returns the id of the user it was issued to,
+// for endpoints personal access tokens aren't allowed on
+func (cfg *apiConfig) authUserID(r *http.Request) (int, error) {
+	p, err := cfg.authAJWT(r)
+	if err != nil {
+		return 0, err
+	}
+
+	if !p.firstParty() {
+		return 0, errors.New("third-party access tokens aren't allowed here")
+	}
+
+	// everything behind authUserID is about the account itself rather than what the user sees
+	if p.ActorID != 0 {
+		return 0, errImpersonated
+	}
+
+	return p.UserID, nil
+}
+
+func (cfg *apiConfig) authAJWT(r *http.Request) (principal, error) {
+	if csrfFailed(r) {
+		return principal{}, errCSRF
+	}
+
+	aToken, err := auth.ParseReq(r, cfg.jwtSecret, "Bearer")
+	if err != nil {
+		return principal{}, err
+	}
+
+	claims, ok := aToken.Claims.(*auth.Claims)
+	if !ok || claims.Issuer != "chirpy-access" {
+		return principal{}, errors.New("invalid AJWT")
+	}
+
+	user, err := cfg.checkTokenRevocation(claims)
+	if err != nil {
+		return principal{}, err
+	}
+
+	actorID := 0
+	if claims.Actor != nil {
+		actorID, err = strconv.Atoi(claims.Actor.Subject)
+		if err != nil {
+			return principal{}, errors.New("couldn't read actor ID off token")
+		}
+	}
+
+	return principal{
+		UserID:   user.ID,
+		Scopes:   strings.Fields(claims.Scope),
+		ClientID: claims.ClientID,
+		Role:     claims.Role,
+		ActorID:  actorID,
+	}, nil
+}
+
+// makes sure the AJWT or RJWT with claims wasn't revoked, either on its own through the denylist or along with
+// every token of its user by a generation bump, and returns the user it was issued to
+func (cfg *apiConfig) checkTokenRevocation(claims *auth.Claims) (database.User, error) {
+	uID, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		return database.User{}, errors.New("couldn't read ID off token")
+	}
+
+	// tokens issued before they carried an ID can only be revoked by generation
+	if claims.ID != "" {
+		denied, err := cfg.db.TokenIDDenied(claims.ID)
+		if err != nil {
+			return database.User{}, err
+		}
+		if denied {
+			return database.User{}, errTokenRevoked
+		}
+	}
+
+	user, err := cfg.db.GetUser(uID)
+	if err != nil || user.DeletedAt != 0 {
+		return database.User{}, errors.New("user doesn't exist anymore")
+	}
+
+	if claims.Generation < user.TokenGeneration {
+		return database.User{}, errTokenRevoked
+	}
+
+	// suspending bumps the generation too, this keeps tokens issued since from working
+	if user.SuspendedAt != 0 {
+		return database.User{}, errSuspended
+	}
+
+	return user, nil
+}
+
+// revokes every AJWT and RJWT issued to the user so far, first-party or not, effective immediately.
+// their personal access tokens are kept, scripts using them would break on every password or role change
+func (cfg *apiConfig) logOutEverywhere(uID int) error {
+	_, err := cfg.db.BumpTokenGeneration(uID)
+	return err
+}
+
+// only lets requests made with an AJWT whose role is at least role through,
+// the authenticated principal is available to next through principalFromContext
+func (cfg *apiConfig) middlewareRequireRole(role string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p, err := cfg.authAJWT(r)
+			if err != nil {
+				respondWithError(w, http.StatusUnauthorized, err.Error())
+				return
+			}
+
+			if !p.firstParty() {
+				respondWithError(w, http.StatusForbidden, "third-party access tokens aren't allowed here")
+				return
+			}
+
+			if p.ActorID != 0 {
+				respondWithError(w, http.StatusForbidden, errImpersonated.Error())
+				return
+			}
+
+			if !auth.HasRole(p.Role, role) {
+				respondWithError(w, http.StatusForbidden, "insufficient role")
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), principalCtxKey{}, p)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// responds with 403 when the credential lacks a scope, is impersonating a user where that isn't allowed,
+// is a session cookie sent without its CSRF token or belongs to a suspended user, and 401 for everything else
+func respondWithAuthError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errMissingScope) || errors.Is(err, errImpersonated) || errors.Is(err, errCSRF) || errors.Is(err, errSuspended) {
+		respondWithError(w, http.StatusForbidden, err.Error())
+		return
+	}
+
+	respondWithError(w, http.StatusUnauthorized, err.Error())
+}
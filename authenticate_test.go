@@ -0,0 +1,132 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hatrnuhn/chirpy-webserver/internal/auth"
+	"github.com/hatrnuhn/chirpy-webserver/internal/database"
+)
+
+// stands in for the errors authPAT makes up on the spot for tokens it doesn't accept
+var errInvalidToken = errors.New("invalid token")
+
+func TestAuthenticateScopes(t *testing.T) {
+	cfg := newTestConfig(t)
+	u := createTestUser(t, cfg, "walt@example.com")
+
+	expired, err := auth.CreatePersonalAccessToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = cfg.db.CreatePersonalAccessToken(database.PersonalAccessToken{
+		UserID:    u.ID,
+		Name:      "expired",
+		Scopes:    auth.Scopes,
+		Hash:      auth.HashToken(expired),
+		CreatedAt: time.Now().Add(-2 * time.Hour).Unix(),
+		ExpiresAt: time.Now().Add(-time.Hour).Unix(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		scope string
+		// nil if the token should be accepted
+		wantErr error
+		wantPAT bool
+	}{
+		{"access token has every scope", testAccessToken(t, cfg, u.ID), auth.ScopeProfileWrite, nil, false},
+		{"personal access token with the scope", testPAT(t, cfg, u.ID, auth.ScopeChirpsRead), auth.ScopeChirpsRead, nil, true},
+		{"personal access token without the scope", testPAT(t, cfg, u.ID, auth.ScopeChirpsRead), auth.ScopeChirpsWrite, errMissingScope, false},
+		{"third-party token with the scope", testOAuthToken(t, cfg, u.ID, auth.ScopeProfileRead), auth.ScopeProfileRead, nil, false},
+		{"third-party token without the scope", testOAuthToken(t, cfg, u.ID, auth.ScopeProfileRead), auth.ScopeProfileWrite, errMissingScope, false},
+		{"expired personal access token", expired, auth.ScopeChirpsRead, errInvalidToken, false},
+		{"unknown personal access token", auth.PATPrefix + "nope", auth.ScopeChirpsRead, errInvalidToken, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/users/me", nil)
+			r.Header.Set("Authorization", "Bearer "+tc.token)
+
+			p, err := cfg.authenticate(r, tc.scope)
+			if tc.wantErr == nil {
+				if err != nil {
+					t.Fatalf("got %s, want no error", err)
+				}
+				if p.UserID != u.ID || (p.PATID != 0) != tc.wantPAT {
+					t.Fatalf("got principal %+v", p)
+				}
+				return
+			}
+
+			if err == nil {
+				t.Fatalf("got principal %+v, want an error", p)
+			}
+			if tc.wantErr == errMissingScope && !errors.Is(err, errMissingScope) {
+				t.Fatalf("got %s, want %s", err, errMissingScope)
+			}
+		})
+	}
+}
+
+func TestAuthenticateOptional(t *testing.T) {
+	cfg := newTestConfig(t)
+	u := createTestUser(t, cfg, "walt@example.com")
+
+	r := httptest.NewRequest(http.MethodGet, "/api/chirps", nil)
+	p, err := cfg.authenticateOptional(r, auth.ScopeChirpsRead)
+	if err != nil || p.UserID != 0 {
+		t.Fatalf("anonymous request: got %+v, %v", p, err)
+	}
+
+	// a credential that's there still has to carry the scope
+	r.Header.Set("Authorization", "Bearer "+testPAT(t, cfg, u.ID, auth.ScopeProfileRead))
+	_, err = cfg.authenticateOptional(r, auth.ScopeChirpsRead)
+	if !errors.Is(err, errMissingScope) {
+		t.Fatalf("got %v, want %s", err, errMissingScope)
+	}
+}
+
+func TestMiddlewareRequireRole(t *testing.T) {
+	cfg := newTestConfig(t)
+
+	user := createTestUser(t, cfg, "walt@example.com")
+	mod := createTestUser(t, cfg, "hank@example.com")
+	setTestRole(t, cfg, mod.ID, auth.RoleModerator)
+	admin := createTestUser(t, cfg, "gus@example.com")
+	setTestRole(t, cfg, admin.ID, auth.RoleAdmin)
+
+	h := cfg.middlewareRequireRole(auth.RoleModerator)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name  string
+		token string
+		want  int
+	}{
+		{"no token", "", http.StatusUnauthorized},
+		{"user", testAccessToken(t, cfg, user.ID), http.StatusForbidden},
+		{"moderator", testAccessToken(t, cfg, mod.ID), http.StatusOK},
+		{"admin", testAccessToken(t, cfg, admin.ID), http.StatusOK},
+		{"third-party token", testOAuthToken(t, cfg, admin.ID, auth.ScopeProfileRead), http.StatusForbidden},
+		{"impersonation token", testImpersonationToken(t, cfg, mod.ID, admin.ID), http.StatusForbidden},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := doRequest(t, h, http.MethodGet, "/", tc.token, nil)
+			if w.Code != tc.want {
+				t.Fatalf("got %d %q, want %d", w.Code, w.Body.String(), tc.want)
+			}
+		})
+	}
+}
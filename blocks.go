@@ -0,0 +1,111 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+	"github.com/hatrnuhn/chirpy-webserver/internal/auth"
+	"github.com/hatrnuhn/chirpy-webserver/internal/database"
+)
+
+// blocks the user as the authenticated user, blocking them again does nothing. follows between them are removed
+func (cfg *apiConfig) handlePostUserBlock(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "bad url")
+		return
+	}
+
+	p, err := cfg.authenticate(r, auth.ScopeProfileWrite)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	b, created, err := cfg.db.Block(p.UserID, id)
+	if errors.Is(err, database.ErrSelfBlock) {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if errors.Is(err, database.ErrNotExist) {
+		respondWithError(w, http.StatusNotFound, fmt.Sprintf("user with id: %v is not found", id))
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't block user")
+		return
+	}
+
+	if created {
+		respondWithJSON(w, http.StatusCreated, b)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, b)
+}
+
+// unblocks the user as the authenticated user, if they're blocked
+func (cfg *apiConfig) handleDelUserBlock(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "bad url")
+		return
+	}
+
+	p, err := cfg.authenticate(r, auth.ScopeProfileWrite)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	err = cfg.db.Unblock(p.UserID, id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't unblock user")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("OK"))
+}
+
+// responds with a page of the users the authenticated user blocked, most recent first
+func (cfg *apiConfig) handleGetUsersMeBlocks(w http.ResponseWriter, r *http.Request) {
+	p, err := cfg.authenticate(r, auth.ScopeProfileRead)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	limit, offset, err := parsePage(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	blocks, err := cfg.db.GetBlocks(p.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't get blocks")
+		return
+	}
+
+	type blockedUser struct {
+		UserID    int   `json:"user_id"`
+		BlockedAt int64 `json:"blocked_at"`
+	}
+
+	start, end := pageBounds(len(blocks), limit, offset)
+	users := make([]blockedUser, 0, end-start)
+	for _, b := range blocks[start:end] {
+		users = append(users, blockedUser{UserID: b.BlockedID, BlockedAt: b.BlockedAt})
+	}
+
+	respondWithJSON(w, http.StatusOK, struct {
+		Total int           `json:"total"`
+		Users []blockedUser `json:"users"`
+	}{
+		Total: len(blocks),
+		Users: users,
+	})
+}
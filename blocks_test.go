@@ -0,0 +1,229 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/hatrnuhn/chirpy-webserver/internal/database"
+)
+
+// sets up walt and jesse, with walt blocking jesse
+func newBlockTest(t *testing.T) (*apiConfig, http.Handler, database.User, database.User) {
+	t.Helper()
+
+	cfg := newTestConfig(t)
+	h := cfg.routes()
+	blocker := createTestUser(t, cfg, "walt@example.com")
+	blocked := createTestUser(t, cfg, "jesse@example.com")
+
+	w := doRequest(t, h, http.MethodPost, fmt.Sprintf("/api/users/%d/block", blocked.ID), testAccessToken(t, cfg, blocker.ID), nil)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("couldn't block: %d %s", w.Code, w.Body.String())
+	}
+
+	return cfg, h, blocker, blocked
+}
+
+func TestBlockHidesChirps(t *testing.T) {
+	cfg, h, blocker, blocked := newBlockTest(t)
+	c, err := cfg.db.CreateChirp(database.Chirp{Body: "say my name"}, blocker.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := fmt.Sprintf("/api/chirps/%d", c.ID)
+
+	// either side of the block
+	for _, u := range []database.User{blocker, blocked} {
+		other, err := cfg.db.CreateChirp(database.Chirp{Body: fmt.Sprintf("chirp by %d", u.ID)}, u.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		viewer := blocked
+		if u.ID == blocked.ID {
+			viewer = blocker
+		}
+
+		w := doRequest(t, h, http.MethodGet, fmt.Sprintf("/api/chirps/%d", other.ID), testAccessToken(t, cfg, viewer.ID), nil)
+		if w.Code != http.StatusNotFound {
+			t.Fatalf("%d viewing %d's chirp: got %d, want 404", viewer.ID, u.ID, w.Code)
+		}
+	}
+
+	w := doRequest(t, h, http.MethodGet, path, "", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("anonymous viewer: got %d, want 200", w.Code)
+	}
+
+	w = doRequest(t, h, http.MethodGet, "/api/chirps", testAccessToken(t, cfg, blocked.ID), nil)
+	if strings.Contains(w.Body.String(), "say my name") {
+		t.Fatalf("chirp list shows the blocker's chirp: %s", w.Body.String())
+	}
+
+	token := testAccessToken(t, cfg, blocked.ID)
+	for name, body := range map[string]map[string]any{
+		"reply":   {"body": "yeah science", "in_reply_to_id": c.ID},
+		"rechirp": {"rechirp_of_id": c.ID},
+		"quote":   {"body": "yeah science", "quote_of_id": c.ID},
+	} {
+		w = doRequest(t, h, http.MethodPost, "/api/chirps", token, body)
+		if w.Code != http.StatusForbidden {
+			t.Fatalf("%s: got %d %s, want 403", name, w.Code, w.Body.String())
+		}
+	}
+
+	w = doRequest(t, h, http.MethodPost, path+"/likes", token, nil)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("like: got %d %s, want 403", w.Code, w.Body.String())
+	}
+}
+
+func TestBlockHidesLikes(t *testing.T) {
+	cfg := newTestConfig(t)
+	h := cfg.routes()
+	author := createTestUser(t, cfg, "gus@example.com")
+	blocker := createTestUser(t, cfg, "walt@example.com")
+	blocked := createTestUser(t, cfg, "jesse@example.com")
+
+	c, err := cfg.db.CreateChirp(database.Chirp{Body: "los pollos hermanos"}, author.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blockedChirp, err := cfg.db.CreateChirp(database.Chirp{Body: "magnets"}, blocked.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, like := range []struct{ cID, uID int }{{c.ID, blocked.ID}, {c.ID, author.ID}, {blockedChirp.ID, author.ID}} {
+		_, _, err = cfg.db.LikeChirp(like.cID, like.uID)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, _, err = cfg.db.Block(blocker.ID, blocked.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := testAccessToken(t, cfg, blocker.ID)
+
+	likers := struct {
+		Total int `json:"total"`
+		Users []struct {
+			UserID int `json:"user_id"`
+		} `json:"users"`
+	}{}
+	w := doRequest(t, h, http.MethodGet, fmt.Sprintf("/api/chirps/%d/likes", c.ID), token, nil)
+	decodeBody(t, w, &likers)
+	if likers.Total != 1 || likers.Users[0].UserID != author.ID {
+		t.Fatalf("likers: got %s", w.Body.String())
+	}
+
+	// anonymous viewers see everyone
+	w = doRequest(t, h, http.MethodGet, fmt.Sprintf("/api/chirps/%d/likes", c.ID), "", nil)
+	decodeBody(t, w, &likers)
+	if likers.Total != 2 {
+		t.Fatalf("anonymous likers: got %s", w.Body.String())
+	}
+
+	w = doRequest(t, h, http.MethodGet, fmt.Sprintf("/api/chirps/%d/likes", blockedChirp.ID), token, nil)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("likers of the blocked user's chirp: got %d, want 404", w.Code)
+	}
+
+	liked := struct {
+		Total int `json:"total"`
+	}{}
+	w = doRequest(t, h, http.MethodGet, fmt.Sprintf("/api/users/%d/likes", author.ID), token, nil)
+	decodeBody(t, w, &liked)
+	if liked.Total != 1 || strings.Contains(w.Body.String(), "magnets") {
+		t.Fatalf("liked chirps: got %s", w.Body.String())
+	}
+
+	w = doRequest(t, h, http.MethodGet, fmt.Sprintf("/api/users/%d/likes", blocked.ID), token, nil)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("the blocked user's likes: got %d, want 404", w.Code)
+	}
+	w = doRequest(t, h, http.MethodGet, fmt.Sprintf("/api/users/%d/likes", blocker.ID), testAccessToken(t, cfg, blocked.ID), nil)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("the blocker's likes: got %d, want 404", w.Code)
+	}
+}
+
+func TestBlockMasksThread(t *testing.T) {
+	cfg := newTestConfig(t)
+	h := cfg.routes()
+	root := createTestUser(t, cfg, "gus@example.com")
+	blocker := createTestUser(t, cfg, "walt@example.com")
+	blocked := createTestUser(t, cfg, "jesse@example.com")
+
+	c, err := cfg.db.CreateChirp(database.Chirp{Body: "family business"}, root.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply, err := cfg.db.CreateChirp(database.Chirp{Body: "yo mr white", InReplyToID: c.ID}, blocked.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = cfg.db.CreateChirp(database.Chirp{Body: "not now jesse", InReplyToID: reply.ID}, root.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err = cfg.db.Block(blocker.ID, blocked.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := doRequest(t, h, http.MethodGet, fmt.Sprintf("/api/chirps/%d/thread", c.ID), testAccessToken(t, cfg, blocker.ID), nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d %s", w.Code, w.Body.String())
+	}
+	body := w.Body.String()
+	// the reply below the hidden one is still there, hanging off a placeholder
+	if strings.Contains(body, "yo mr white") || !strings.Contains(body, `"unavailable":true`) || !strings.Contains(body, "not now jesse") {
+		t.Fatalf("thread: got %s", body)
+	}
+}
+
+func TestBlockFollowsAndMentions(t *testing.T) {
+	cfg := newTestConfig(t)
+	h := cfg.routes()
+	blocker := createTestUser(t, cfg, "walt@example.com")
+	blocked := createTestUser(t, cfg, "jesse@example.com")
+
+	_, _, err := cfg.db.Follow(blocked.ID, blocker.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = cfg.db.Block(blocker.ID, blocked.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the block removes the follow and keeps it from coming back either way
+	following, err := cfg.db.GetFollowing(blocked.ID)
+	if err != nil || len(following) != 0 {
+		t.Fatalf("follows after the block: got %+v, %v", following, err)
+	}
+	for _, pair := range [][2]database.User{{blocked, blocker}, {blocker, blocked}} {
+		w := doRequest(t, h, http.MethodPost, fmt.Sprintf("/api/users/%d/follow", pair[1].ID), testAccessToken(t, cfg, pair[0].ID), nil)
+		if w.Code != http.StatusForbidden {
+			t.Fatalf("%d following %d: got %d, want 403", pair[0].ID, pair[1].ID, w.Code)
+		}
+	}
+
+	_, err = cfg.db.CreateChirp(database.Chirp{Body: "@walt you there?"}, blocked.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	notifications, err := cfg.db.GetNotifications(blocker.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range notifications {
+		if n.Type == database.NotificationMention {
+			t.Fatalf("the blocker was notified of a mention: %+v", n)
+		}
+	}
+}
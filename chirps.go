@@ -2,78 +2,227 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"strconv"
 
 	"github.com/go-chi/chi"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/hatrnuhn/chirpy-webserver/internal/auth"
 	"github.com/hatrnuhn/chirpy-webserver/internal/database"
+	"github.com/hatrnuhn/chirpy-webserver/internal/moderation"
 )
 
-// requires body and authorization header, authenticates, then accepts and store a chirp POST and responds with a newly stored chirp with its associated author UserID
+// requires body and authorization header, authenticates, then accepts and store a chirp POST and responds with a newly stored chirp with its associated author UserID.
+// the body goes through the moderation pipeline first, which may rewrite it, reject the chirp or hold it for moderators to review
 func (cfg *apiConfig) handlePostChirps(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
-	aToken, err := auth.ParseReq(r, cfg.jwtSecret, "Bearer")
+	p, err := cfg.authenticate(r, auth.ScopeChirpsWrite)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "couldn't parse from header")
+		respondWithAuthError(w, err)
 		return
 	}
 
-	if claims, ok := aToken.Claims.(*jwt.RegisteredClaims); ok {
-		if claims.Issuer != "chirpy-access" {
-			respondWithError(w, http.StatusUnauthorized, "invalid AJWT")
-			return
+	dat, err := io.ReadAll(r.Body)
+	if err != nil {
+		respondWithError(w, 500, "couldn't read request")
+		return
+	}
+
+	req := database.Chirp{}
+	err = json.Unmarshal(dat, &req)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "couldn't unmarshal request")
+		return
+	}
+
+	// the text of a quote is counted like any other chirp's
+	if len(req.Body) > 140 {
+		respondWithError(w, http.StatusBadRequest, "Chirp is too long!")
+		return
+	}
+
+	refID := 0
+	for _, id := range []int{req.InReplyToID, req.RechirpOfID, req.QuoteOfID} {
+		if id == 0 {
+			continue
 		}
-		if !ok {
-			respondWithError(w, http.StatusUnauthorized, "AJWT expired")
+		if refID != 0 {
+			respondWithError(w, http.StatusBadRequest, "a chirp can only reply to, rechirp or quote one chirp")
 			return
 		}
+		refID = id
+	}
 
-		dat, err := io.ReadAll(r.Body)
+	if req.RechirpOfID != 0 && (req.Body != "" || len(req.MediaIDs) != 0) {
+		respondWithError(w, http.StatusBadRequest, "a rechirp can't have a body or media, quote the chirp instead")
+		return
+	}
+
+	if len(req.MediaIDs) > database.MaxChirpMedia {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("a chirp can't have more than %d media", database.MaxChirpMedia))
+		return
+	}
+
+	// rechirps have no body of their own to moderate
+	if req.Body != "" {
+		v, err := cfg.moderation.Run(moderation.Chirp{UserID: p.UserID, Body: req.Body})
 		if err != nil {
-			respondWithError(w, 500, "couldn't read request")
+			respondWithError(w, http.StatusInternalServerError, "couldn't moderate chirp")
 			return
 		}
 
-		req := database.Chirp{}
-		err = json.Unmarshal(dat, &req)
-		if err != nil {
-			respondWithError(w, http.StatusBadRequest, "couldn't unmarshal request")
+		// replacements can be longer than what they replace
+		if v.Action != moderation.Reject && len(v.Body) > 140 {
+			respondWithError(w, http.StatusBadRequest, "Chirp is too long once moderated!")
 			return
 		}
 
-		if len(req.Body) > 140 {
-			respondWithError(w, http.StatusBadRequest, "Chirp is too long!")
+		switch v.Action {
+		case moderation.Reject:
+			respondWithError(w, http.StatusUnprocessableEntity, fmt.Sprintf("chirp was rejected: %s", v.Reason))
 			return
-		}
+		case moderation.Hold:
+			req.Body = v.Body
+			h, err := cfg.db.CreateHeldChirp(req, p.UserID, v.Filter, v.Reason)
+			if err != nil {
+				respondWithError(w, http.StatusInternalServerError, "couldn't hold chirp for review")
+				return
+			}
 
-		uID, err := strconv.Atoi(claims.Subject)
-		if err != nil {
-			respondWithError(w, http.StatusBadRequest, "couldn't read ID off token")
+			respondWithJSON(w, http.StatusAccepted, struct {
+				HeldID int    `json:"held_id"`
+				Status string `json:"status"`
+				Reason string `json:"reason"`
+			}{
+				HeldID: h.ID,
+				Status: h.Status,
+				Reason: h.Reason,
+			})
 			return
 		}
+		req.Body = v.Body
+	}
 
-		newC, err := cfg.db.CreateChirp(req, uID)
-		if err != nil {
-			respondWithError(w, 500, "couldn't create chirp")
-			return
+	newC, err := cfg.db.CreateChirp(req, p.UserID)
+	if errors.Is(err, database.ErrNotExist) {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("chirp with id: %v is not found", refID))
+		return
+	}
+	if errors.Is(err, database.ErrAlreadyRechirped) {
+		respondWithError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if errors.Is(err, database.ErrBlocked) {
+		respondWithError(w, http.StatusForbidden, fmt.Sprintf("can't reply to, rechirp or quote chirp with id: %v", refID))
+		return
+	}
+	if errors.Is(err, database.ErrMediaUnusable) {
+		respondWithError(w, http.StatusBadRequest, "media must be your own uploads that aren't attached to another chirp")
+		return
+	}
+	if err != nil {
+		respondWithError(w, 500, "couldn't create chirp")
+		return
+	}
+
+	resp, err := cfg.newChirpResponses([]database.Chirp{newC})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't get chirps")
+		return
+	}
+
+	respondWithJSON(w, 201, resp[0])
+}
+
+// drops the chirps the user shouldn't be shown because of blocks or, if withMutes, mutes. anonymous users
+// with ID 0 are shown everything
+func (cfg *apiConfig) visibleChirps(uID int, chirps []database.Chirp, withMutes bool) ([]database.Chirp, error) {
+	if uID == 0 {
+		return chirps, nil
+	}
+
+	hidden, err := cfg.db.HiddenChirps(uID, chirps, withMutes)
+	if err != nil {
+		return nil, err
+	}
+
+	visible := make([]database.Chirp, 0, len(chirps))
+	for _, c := range chirps {
+		if !hidden[c.ID] {
+			visible = append(visible, c)
 		}
+	}
 
-		respondWithJSON(w, 201, newC)
-	} else {
-		respondWithError(w, http.StatusUnauthorized, "please authenticate with the associated user")
+	return visible, nil
+}
+
+// a chirp as it's rendered, with its media and the chirp it rechirps or quotes embedded. the latter is left
+// out if that chirp was deleted, the chirp's reference to it is kept so clients can tell
+type chirpResponse struct {
+	database.Chirp
+	Media     []mediaResponse `json:"media,omitempty"`
+	Rechirped *database.Chirp `json:"rechirped_chirp,omitempty"`
+	Quoted    *database.Chirp `json:"quoted_chirp,omitempty"`
+}
+
+// renders chirps, the chirps they rechirp or quote and their media are looked up all at once
+func (cfg *apiConfig) newChirpResponses(chirps []database.Chirp) ([]chirpResponse, error) {
+	ids := make([]int, 0)
+	mediaIDs := make([]string, 0)
+	for _, c := range chirps {
+		if c.RechirpOfID != 0 {
+			ids = append(ids, c.RechirpOfID)
+		}
+		if c.QuoteOfID != 0 {
+			ids = append(ids, c.QuoteOfID)
+		}
+		mediaIDs = append(mediaIDs, c.MediaIDs...)
+	}
+
+	refs, err := cfg.db.GetChirpsByID(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	media, err := cfg.db.GetMediaByID(mediaIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := make([]chirpResponse, 0, len(chirps))
+	for _, c := range chirps {
+		cr := chirpResponse{Chirp: c}
+		for _, id := range c.MediaIDs {
+			if m, ok := media[id]; ok {
+				cr.Media = append(cr.Media, cfg.newMediaResponse(m))
+			}
+		}
+		if ref, ok := refs[c.RechirpOfID]; ok && ref.DeletedAt == 0 {
+			cr.Rechirped = &ref
+		}
+		if ref, ok := refs[c.QuoteOfID]; ok && ref.DeletedAt == 0 {
+			cr.Quoted = &ref
+		}
+		resp = append(resp, cr)
 	}
+
+	return resp, nil
 }
 
-// responds with all chirps stored in database
+// responds with all chirps stored in database. authenticated users don't get the chirps their blocks hide,
+// nor those their mutes hide unless they asked for a single author's chirps
 func (cfg *apiConfig) handleGetChirps(w http.ResponseWriter, r *http.Request) {
 	authIDS := r.URL.Query().Get("author_id")
 	sortS := r.URL.Query().Get("sort")
 	var chirps []database.Chirp
-	var err error
+
+	p, err := cfg.authenticateOptional(r, auth.ScopeChirpsRead)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
 
 	if authIDS != "" {
 		AuthID, err := strconv.Atoi(authIDS)
@@ -91,7 +240,19 @@ func (cfg *apiConfig) handleGetChirps(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	respondWithJSON(w, http.StatusOK, chirps)
+	chirps, err = cfg.visibleChirps(p.UserID, chirps, authIDS == "")
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't get chirps")
+		return
+	}
+
+	resp, err := cfg.newChirpResponses(chirps)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't get chirps")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, resp)
 }
 
 // handles /chirps/{chirpID} endpoints
@@ -107,18 +268,41 @@ func (cfg *apiConfig) handleChirpID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	cps, err := cfg.db.GetChirps("asc")
+	p, err := cfg.authenticateOptional(r, auth.ScopeChirpsRead)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	// looked up by id rather than position, deleted chirps leave gaps
+	c, err := cfg.db.GetChirp(id)
+	if errors.Is(err, database.ErrNotExist) || c.DeletedAt != 0 {
+		respondWithError(w, http.StatusNotFound, fmt.Sprintf("chirp with id: %v is not found", id))
+		return
+	}
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "couldn't get chirps")
 		return
 	}
 
-	if id > len(cps) {
+	// a chirp hidden by a block is as good as gone
+	visible, err := cfg.visibleChirps(p.UserID, []database.Chirp{c}, false)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't get chirps")
+		return
+	}
+	if len(visible) == 0 {
 		respondWithError(w, http.StatusNotFound, fmt.Sprintf("chirp with id: %v is not found", id))
 		return
 	}
 
-	respondWithJSON(w, http.StatusOK, cps[id-1])
+	resp, err := cfg.newChirpResponses([]database.Chirp{c})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't get chirps")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, resp[0])
 }
 
 func (cfg *apiConfig) handleDelChirpID(w http.ResponseWriter, r *http.Request) {
@@ -129,64 +313,36 @@ func (cfg *apiConfig) handleDelChirpID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	// authenticate user
-	aToken, err := auth.ParseReq(r, cfg.jwtSecret, "Bearer")
+	p, err := cfg.authenticate(r, auth.ScopeChirpsWrite)
 	if err != nil {
-		respondWithError(w, http.StatusBadRequest, "couldn't parse token off request")
+		respondWithAuthError(w, err)
 		return
 	}
 
-	if claims, ok := aToken.Claims.(*jwt.RegisteredClaims); ok {
-		uID, err := strconv.Atoi(claims.Subject)
-		if err != nil {
-			respondWithError(w, http.StatusBadRequest, "couldn't get ID off token")
-			return
-		}
-
-		if claims.Issuer != "chirpy-access" {
-			respondWithError(w, http.StatusUnauthorized, "invalid AJWT")
-			return
-		}
-
-		if !ok {
-			respondWithError(w, http.StatusUnauthorized, "AJWT expired")
-			return
-		}
-
-		// check if id exists
-		cs, err := cfg.db.GetChirps("asc")
-		if err != nil {
-			respondWithError(w, http.StatusInternalServerError, "couldn't read db")
-			return
-		}
-
-		found := false
-		var chirp database.Chirp
-		for _, c := range cs {
-			if id == c.ID {
-				found = true
-				chirp = c
-				break
-			}
-		}
-
-		if !found {
-			respondWithError(w, http.StatusNotFound, fmt.Sprintf("ChirpID: %d doesn't exist", id))
-			return
-		}
-
-		// check if id and userid are associated
-		if chirp.UserID != uID {
-			respondWithError(w, http.StatusForbidden, "Chirp and user are not associated")
-			return
-		}
+	// check if id exists, a tombstone is as good as gone
+	chirp, err := cfg.db.GetChirp(id)
+	if errors.Is(err, database.ErrNotExist) || chirp.DeletedAt != 0 {
+		respondWithError(w, http.StatusNotFound, fmt.Sprintf("ChirpID: %d doesn't exist", id))
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't read db")
+		return
+	}
 
+	// check if id and userid are associated
+	if chirp.UserID != p.UserID {
+		respondWithError(w, http.StatusForbidden, "Chirp and user are not associated")
+		return
 	}
-	// delete chirp at id and write new db
-	err = cfg.db.DeleteChirp(id)
+
+	// delete chirp at id and write new db, replies keep a tombstone of it and rechirps of it go too
+	media, err := cfg.db.DeleteChirp(id)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "couldn't delete associated chirp")
 		return
 	}
+	cfg.removeMediaBlobs(r.Context(), media)
 
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("OK"))
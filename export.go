@@ -0,0 +1,473 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/hatrnuhn/chirpy-webserver/internal/auth"
+	"github.com/hatrnuhn/chirpy-webserver/internal/blobstore"
+	"github.com/hatrnuhn/chirpy-webserver/internal/database"
+)
+
+// how long a generated data export can be downloaded for
+const dataExportTTL = 7 * 24 * time.Hour
+
+type dataExportResponse struct {
+	ID          string `json:"id"`
+	Status      string `json:"status"`
+	CreatedAt   int64  `json:"created_at"`
+	CompletedAt int64  `json:"completed_at,omitempty"`
+	ExpiresAt   int64  `json:"expires_at,omitempty"`
+	DownloadURL string `json:"download_url,omitempty"`
+}
+
+func (cfg *apiConfig) newDataExportResponse(e database.DataExport) dataExportResponse {
+	resp := dataExportResponse{
+		ID:          e.ID,
+		Status:      e.Status,
+		CreatedAt:   e.CreatedAt,
+		CompletedAt: e.CompletedAt,
+		ExpiresAt:   e.ExpiresAt,
+	}
+
+	if e.Status == "ready" {
+		resp.DownloadURL = fmt.Sprintf("%s/api/users/me/export/%s/download", cfg.baseURL, e.ID)
+	}
+
+	return resp
+}
+
+// starts generating an archive of the user's data in the background, its status is polled through
+// GET /api/users/me/export/{exportID}
+func (cfg *apiConfig) handlePostUsersMeExport(w http.ResponseWriter, r *http.Request) {
+	uID, err := cfg.authUserID(r)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	exports, err := cfg.db.GetDataExports(uID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("couldn't get data exports: %s", err.Error()))
+		return
+	}
+
+	now := time.Now().Unix()
+	for _, e := range exports {
+		if e.Status == "pending" {
+			respondWithError(w, http.StatusConflict, "a data export is already being generated")
+			return
+		}
+
+		// drops archives nobody can download anymore
+		if e.ExpiresAt != 0 && e.ExpiresAt < now {
+			removeExportArchive(e)
+			err = cfg.db.DeleteDataExport(e.ID)
+			if err != nil {
+				log.Printf("couldn't delete data export %s: %s", e.ID, err.Error())
+			}
+		}
+	}
+
+	id, err := auth.MakeRandomToken(16)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't create export ID")
+		return
+	}
+
+	e := database.DataExport{
+		ID:        id,
+		UserID:    uID,
+		Status:    "pending",
+		CreatedAt: now,
+	}
+
+	err = cfg.db.CreateDataExport(e)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("couldn't write data export to db: %s", err.Error()))
+		return
+	}
+
+	go cfg.generateDataExport(e)
+
+	respondWithJSON(w, http.StatusAccepted, cfg.newDataExportResponse(e))
+}
+
+func (cfg *apiConfig) handleGetUsersMeExport(w http.ResponseWriter, r *http.Request) {
+	e, ok := cfg.userDataExport(w, r)
+	if !ok {
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, cfg.newDataExportResponse(e))
+}
+
+func (cfg *apiConfig) handleGetUsersMeExportDownload(w http.ResponseWriter, r *http.Request) {
+	e, ok := cfg.userDataExport(w, r)
+	if !ok {
+		return
+	}
+
+	if e.Status != "ready" {
+		respondWithError(w, http.StatusConflict, fmt.Sprintf("data export is %s", e.Status))
+		return
+	}
+
+	if e.ExpiresAt < time.Now().Unix() {
+		respondWithError(w, http.StatusGone, "data export is expired")
+		return
+	}
+
+	f, err := os.Open(e.Path)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't open data export")
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="chirpy-export-%s.zip"`, e.ID))
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	io.Copy(w, f)
+}
+
+// authenticates the request and returns the data export of the exportID URL param, responding with an error if it can't
+func (cfg *apiConfig) userDataExport(w http.ResponseWriter, r *http.Request) (database.DataExport, bool) {
+	uID, err := cfg.authUserID(r)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return database.DataExport{}, false
+	}
+
+	e, err := cfg.db.GetDataExport(uID, chi.URLParam(r, "exportID"))
+	if errors.Is(err, database.ErrNotExist) {
+		respondWithError(w, http.StatusNotFound, "data export doesn't exist")
+		return database.DataExport{}, false
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("couldn't get data export: %s", err.Error()))
+		return database.DataExport{}, false
+	}
+
+	return e, true
+}
+
+// writes the archive of e and marks it ready, or failed if it couldn't be written
+func (cfg *apiConfig) generateDataExport(e database.DataExport) {
+	path := filepath.Join(cfg.exportDir, e.ID+".zip")
+
+	err := cfg.writeDataExport(e.UserID, path)
+	if err != nil {
+		log.Printf("data export %s: %s", e.ID, err.Error())
+		os.Remove(path)
+		e.Status = "failed"
+	} else {
+		e.Status = "ready"
+		e.Path = path
+		e.ExpiresAt = time.Now().Add(dataExportTTL).Unix()
+	}
+	e.CompletedAt = time.Now().Unix()
+
+	err = cfg.db.UpdateDataExport(e)
+	if err != nil {
+		// the account may have been deleted meanwhile
+		log.Printf("data export %s: couldn't update: %s", e.ID, err.Error())
+		os.Remove(path)
+	}
+}
+
+type exportedFollows struct {
+	Followers []database.Follow `json:"followers"`
+	Following []database.Follow `json:"following"`
+}
+
+type exportedMedia struct {
+	mediaResponse
+	ChirpID   int   `json:"chirp_id,omitempty"`
+	CreatedAt int64 `json:"created_at"`
+	// where the upload and its thumbnail are in the archive
+	File          string `json:"file"`
+	ThumbnailFile string `json:"thumbnail_file"`
+}
+
+// the moderators who handled it are left out
+type exportedReport struct {
+	ID         int    `json:"id"`
+	ChirpID    int    `json:"chirp_id,omitempty"`
+	UserID     int    `json:"user_id"`
+	Reason     string `json:"reason"`
+	Details    string `json:"details,omitempty"`
+	Status     string `json:"status"`
+	CreatedAt  int64  `json:"created_at"`
+	Resolution string `json:"resolution,omitempty"`
+	ResolvedAt int64  `json:"resolved_at,omitempty"`
+}
+
+// writes a zip of everything the user has on chirpy to path: their profile, chirps, sessions, tokens, OAuth clients,
+// likes, follows, notifications, reports, blocks and mutes as JSON files, and their uploaded media under media/
+func (cfg *apiConfig) writeDataExport(uID int, path string) error {
+	user, err := cfg.db.GetUser(uID)
+	if err != nil {
+		return fmt.Errorf("couldn't get user: %w", err)
+	}
+
+	chirps, err := cfg.db.GetChirpsByAuthID(uID, "asc")
+	if err != nil {
+		return fmt.Errorf("couldn't get chirps: %w", err)
+	}
+
+	sessions, err := cfg.userSessions(uID)
+	if err != nil {
+		return fmt.Errorf("couldn't get sessions: %w", err)
+	}
+
+	pats, err := cfg.db.GetPersonalAccessTokens(uID)
+	if err != nil {
+		return fmt.Errorf("couldn't get personal access tokens: %w", err)
+	}
+	patResps := make([]patResponse, 0, len(pats))
+	for _, pat := range pats {
+		patResps = append(patResps, newPATResponse(pat))
+	}
+
+	clients, err := cfg.db.GetOAuthClients(uID)
+	if err != nil {
+		return fmt.Errorf("couldn't get OAuth clients: %w", err)
+	}
+	clientResps := make([]oauthClientResponse, 0, len(clients))
+	for _, c := range clients {
+		clientResps = append(clientResps, newOAuthClientResponse(c))
+	}
+
+	likes, err := cfg.db.GetUserLikes(uID)
+	if err != nil {
+		return fmt.Errorf("couldn't get likes: %w", err)
+	}
+
+	follows := exportedFollows{}
+	follows.Followers, err = cfg.db.GetFollowers(uID)
+	if err != nil {
+		return fmt.Errorf("couldn't get followers: %w", err)
+	}
+	follows.Following, err = cfg.db.GetFollowing(uID)
+	if err != nil {
+		return fmt.Errorf("couldn't get follows: %w", err)
+	}
+
+	media, err := cfg.db.GetUserMedia(uID)
+	if err != nil {
+		return fmt.Errorf("couldn't get media: %w", err)
+	}
+	mediaResps := make([]exportedMedia, 0, len(media))
+	for _, m := range media {
+		mediaResps = append(mediaResps, exportedMedia{
+			mediaResponse: cfg.newMediaResponse(m),
+			ChirpID:       m.ChirpID,
+			CreatedAt:     m.CreatedAt,
+			File:          "media/" + m.Key,
+			ThumbnailFile: "media/" + m.ThumbnailKey,
+		})
+	}
+
+	notifications, err := cfg.db.GetNotifications(uID)
+	if err != nil {
+		return fmt.Errorf("couldn't get notifications: %w", err)
+	}
+
+	settings, err := cfg.db.GetNotificationSettings(uID)
+	if err != nil {
+		return fmt.Errorf("couldn't get notification settings: %w", err)
+	}
+
+	reports, err := cfg.db.GetUserReports(uID)
+	if err != nil {
+		return fmt.Errorf("couldn't get reports: %w", err)
+	}
+	reportResps := make([]exportedReport, 0, len(reports))
+	for _, rep := range reports {
+		reportResps = append(reportResps, exportedReport{
+			ID:         rep.ID,
+			ChirpID:    rep.ChirpID,
+			UserID:     rep.UserID,
+			Reason:     rep.Reason,
+			Details:    rep.Details,
+			Status:     rep.Status,
+			CreatedAt:  rep.CreatedAt,
+			Resolution: rep.Resolution,
+			ResolvedAt: rep.ResolvedAt,
+		})
+	}
+
+	blocks, err := cfg.db.GetBlocks(uID)
+	if err != nil {
+		return fmt.Errorf("couldn't get blocks: %w", err)
+	}
+
+	mutes, err := cfg.db.GetMutes(uID)
+	if err != nil {
+		return fmt.Errorf("couldn't get mutes: %w", err)
+	}
+
+	keywords, err := cfg.db.GetMutedKeywords(uID)
+	if err != nil {
+		return fmt.Errorf("couldn't get muted keywords: %w", err)
+	}
+
+	profile := struct {
+		ID          int    `json:"id"`
+		Email       string `json:"email"`
+		Role        string `json:"role"`
+		IsChirpyRed bool   `json:"is_chirpy_red"`
+		TOTPEnabled bool   `json:"totp_enabled"`
+		OIDCIssuer  string `json:"oidc_issuer,omitempty"`
+		OIDCSubject string `json:"oidc_subject,omitempty"`
+	}{
+		ID:          user.ID,
+		Email:       user.Email,
+		Role:        user.Role,
+		IsChirpyRed: user.IsChirpyRed,
+		TOTPEnabled: user.TOTPEnabled,
+		OIDCIssuer:  user.OIDCIssuer,
+		OIDCSubject: user.OIDCSubject,
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+	files := []struct {
+		name string
+		v    any
+	}{
+		{name: "profile.json", v: profile},
+		{name: "chirps.json", v: chirps},
+		{name: "sessions.json", v: sessions},
+		{name: "personal_access_tokens.json", v: patResps},
+		{name: "oauth_clients.json", v: clientResps},
+		{name: "likes.json", v: likes},
+		{name: "follows.json", v: follows},
+		{name: "media.json", v: mediaResps},
+		{name: "notifications.json", v: notifications},
+		{name: "notification_settings.json", v: settings},
+		{name: "reports.json", v: reportResps},
+		{name: "blocks.json", v: blocks},
+		{name: "mutes.json", v: mutes},
+		{name: "muted_keywords.json", v: keywords},
+	}
+
+	for _, file := range files {
+		fw, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     file.name,
+			Method:   zip.Deflate,
+			Modified: time.Now(),
+		})
+		if err != nil {
+			return err
+		}
+
+		enc := json.NewEncoder(fw)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(file.v)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, m := range media {
+		for _, key := range []string{m.Key, m.ThumbnailKey} {
+			err = cfg.writeExportBlob(zw, key)
+			if err != nil {
+				return fmt.Errorf("couldn't export media %s: %w", m.ID, err)
+			}
+		}
+	}
+
+	err = zw.Close()
+	if err != nil {
+		return err
+	}
+
+	return f.Close()
+}
+
+// copies the blob under key into the archive as media/key, blobs that are gone are left out.
+// images are compressed already, so they're stored as they are
+func (cfg *apiConfig) writeExportBlob(zw *zip.Writer, key string) error {
+	rc, err := cfg.blobs.Get(context.Background(), key)
+	if errors.Is(err, blobstore.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	fw, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     "media/" + key,
+		Method:   zip.Store,
+		Modified: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(fw, rc)
+	return err
+}
+
+type exportedRefreshToken struct {
+	ID        string `json:"id,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	IssuedAt  int64  `json:"issued_at"`
+	ExpiresAt int64  `json:"expires_at"`
+	RevokedAt int64  `json:"revoked_at,omitempty"`
+}
+
+// returns the RJWTs issued to the user that haven't expired, without the tokens themselves
+func (cfg *apiConfig) userSessions(uID int) ([]exportedRefreshToken, error) {
+	sessions := make([]exportedRefreshToken, 0)
+
+	tokens, err := cfg.db.GetRefreshTokens()
+	if err != nil {
+		return nil, err
+	}
+
+	sub := strconv.Itoa(uID)
+	for t, revokedAt := range tokens {
+		// expired ones don't parse, they're of no use to anyone anymore
+		rToken, err := auth.ParseToken(t, cfg.jwtSecret)
+		if err != nil {
+			continue
+		}
+
+		claims, ok := rToken.Claims.(*auth.Claims)
+		if !ok || claims.Subject != sub || claims.ExpiresAt == nil || claims.IssuedAt == nil {
+			continue
+		}
+
+		sessions = append(sessions, exportedRefreshToken{
+			ID:        claims.ID,
+			ClientID:  claims.ClientID,
+			IssuedAt:  claims.IssuedAt.Unix(),
+			ExpiresAt: claims.ExpiresAt.Unix(),
+			RevokedAt: revokedAt,
+		})
+	}
+
+	return sessions, nil
+}
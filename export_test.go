@@ -0,0 +1,191 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/hatrnuhn/chirpy-webserver/internal/auth"
+	"github.com/hatrnuhn/chirpy-webserver/internal/database"
+)
+
+func TestUsersMeExport(t *testing.T) {
+	cfg := newTestConfig(t)
+	h := cfg.routes()
+
+	u := createTestUser(t, cfg, "walt@example.com")
+	other := createTestUser(t, cfg, "hank@example.com")
+	troll := createTestUser(t, cfg, "tuco@example.com")
+	bore := createTestUser(t, cfg, "marie@example.com")
+	_, err := cfg.db.CreateChirp(database.Chirp{Body: "say my name"}, u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := testAccessToken(t, cfg, u.ID)
+
+	// a bit of everything the archive has a file for
+	testPAT(t, cfg, u.ID, auth.ScopeProfileRead)
+	_, err = cfg.db.CreateOAuthClient(database.OAuthClient{ID: "lab", OwnerID: u.ID, Name: "lab", RedirectURIs: []string{"https://example.com/callback"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	media := uploadTestMedia(t, h, token)
+	c, err := cfg.db.CreateChirp(database.Chirp{Body: "tread lightly"}, other.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = cfg.db.LikeChirp(c.ID, u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = cfg.db.Follow(other.ID, u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = cfg.db.Block(u.ID, troll.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = cfg.db.Mute(u.ID, bore.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = cfg.db.UpdateMutedKeywords(u.ID, []string{"blue"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = cfg.db.ReportUser(u.ID, troll.ID, database.ReportHarassment, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := doRequest(t, h, http.MethodPost, "/api/users/me/export", token, nil)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("got %d %q, want %d", w.Code, w.Body.String(), http.StatusAccepted)
+	}
+	e := dataExportResponse{}
+	decodeBody(t, w, &e)
+
+	path := "/api/users/me/export/" + e.ID
+	for deadline := time.Now().Add(5 * time.Second); e.Status == "pending"; {
+		if time.Now().After(deadline) {
+			t.Fatal("data export is still pending")
+		}
+		time.Sleep(10 * time.Millisecond)
+
+		w = doRequest(t, h, http.MethodGet, path, token, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("got %d %q, want %d", w.Code, w.Body.String(), http.StatusOK)
+		}
+		decodeBody(t, w, &e)
+	}
+	if e.Status != "ready" {
+		t.Fatalf("data export is %s, want ready", e.Status)
+	}
+
+	w = doRequest(t, h, http.MethodGet, path+"/download", testAccessToken(t, cfg, other.ID), nil)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("another user's download got %d, want %d", w.Code, http.StatusNotFound)
+	}
+
+	w = doRequest(t, h, http.MethodGet, path+"/download", token, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d %q, want %d", w.Code, w.Body.String(), http.StatusOK)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := make(map[string][]byte)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[f.Name], err = io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	profile := struct {
+		ID    int    `json:"id"`
+		Email string `json:"email"`
+	}{}
+	err = json.Unmarshal(files["profile.json"], &profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile.ID != u.ID || profile.Email != u.Email {
+		t.Errorf("got profile %+v, want user %d", profile, u.ID)
+	}
+
+	chirps := make([]database.Chirp, 0)
+	err = json.Unmarshal(files["chirps.json"], &chirps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chirps) != 1 || chirps[0].Body != "say my name" {
+		t.Errorf("got chirps %+v, want the user's one chirp", chirps)
+	}
+
+	for _, name := range []string{
+		"sessions.json",
+		"personal_access_tokens.json",
+		"oauth_clients.json",
+		"likes.json",
+		"follows.json",
+		"media.json",
+		"notifications.json",
+		"notification_settings.json",
+		"reports.json",
+		"blocks.json",
+		"mutes.json",
+		"muted_keywords.json",
+	} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("export has no %s", name)
+		}
+	}
+
+	exported := make([]exportedMedia, 0)
+	err = json.Unmarshal(files["media.json"], &exported)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(exported) != 1 || exported[0].ID != media.ID {
+		t.Fatalf("got media %+v, want the user's upload %s", exported, media.ID)
+	}
+	for _, name := range []string{exported[0].File, exported[0].ThumbnailFile} {
+		if len(files[name]) == 0 {
+			t.Errorf("export has no %s", name)
+		}
+	}
+
+	for name, want := range map[string]int{
+		"personal_access_tokens.json": 1,
+		"oauth_clients.json":          1,
+		"likes.json":                  1,
+		"notifications.json":          1,
+		"reports.json":                1,
+		"blocks.json":                 1,
+		"mutes.json":                  1,
+		"muted_keywords.json":         1,
+	} {
+		got := make([]json.RawMessage, 0)
+		err = json.Unmarshal(files[name], &got)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		if len(got) != want {
+			t.Errorf("%s: got %d entries, want %d", name, len(got), want)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+	"github.com/hatrnuhn/chirpy-webserver/internal/auth"
+	"github.com/hatrnuhn/chirpy-webserver/internal/database"
+)
+
+// follows the user as the authenticated user, following them again does nothing
+func (cfg *apiConfig) handlePostUserFollow(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "bad url")
+		return
+	}
+
+	p, err := cfg.authenticate(r, auth.ScopeProfileWrite)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	f, created, err := cfg.db.Follow(p.UserID, id)
+	if errors.Is(err, database.ErrSelfFollow) {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if errors.Is(err, database.ErrNotExist) {
+		respondWithError(w, http.StatusNotFound, fmt.Sprintf("user with id: %v is not found", id))
+		return
+	}
+	if errors.Is(err, database.ErrBlocked) {
+		respondWithError(w, http.StatusForbidden, fmt.Sprintf("can't follow user with id: %v", id))
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't follow user")
+		return
+	}
+
+	if created {
+		respondWithJSON(w, http.StatusCreated, f)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, f)
+}
+
+// unfollows the user as the authenticated user, if they're followed
+func (cfg *apiConfig) handleDelUserFollow(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "bad url")
+		return
+	}
+
+	p, err := cfg.authenticate(r, auth.ScopeProfileWrite)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	err = cfg.db.Unfollow(p.UserID, id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't unfollow user")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("OK"))
+}
+
+// responds with a page of the user's followers, most recent first
+func (cfg *apiConfig) handleGetUserFollowers(w http.ResponseWriter, r *http.Request) {
+	cfg.respondWithFollows(w, r, cfg.db.GetFollowers, func(f database.Follow) int { return f.FollowerID })
+}
+
+// responds with a page of the users the user follows, most recent first
+func (cfg *apiConfig) handleGetUserFollowing(w http.ResponseWriter, r *http.Request) {
+	cfg.respondWithFollows(w, r, cfg.db.GetFollowing, func(f database.Follow) int { return f.FolloweeID })
+}
+
+// responds with a page of the follows get returns for the user in the URL, listing the users other picks out
+func (cfg *apiConfig) respondWithFollows(w http.ResponseWriter, r *http.Request, get func(int) ([]database.Follow, error), other func(database.Follow) int) {
+	uID, err := strconv.Atoi(chi.URLParam(r, "userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "bad url")
+		return
+	}
+
+	limit, offset, err := parsePage(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	user, err := cfg.db.GetUser(uID)
+	if errors.Is(err, database.ErrNotExist) || user.DeletedAt != 0 {
+		respondWithError(w, http.StatusNotFound, fmt.Sprintf("user with id: %v is not found", uID))
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't get user")
+		return
+	}
+
+	follows, err := get(uID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't get follows")
+		return
+	}
+
+	type followUser struct {
+		UserID     int   `json:"user_id"`
+		FollowedAt int64 `json:"followed_at"`
+	}
+
+	start, end := pageBounds(len(follows), limit, offset)
+	users := make([]followUser, 0, end-start)
+	for _, f := range follows[start:end] {
+		users = append(users, followUser{UserID: other(f), FollowedAt: f.FollowedAt})
+	}
+
+	respondWithJSON(w, http.StatusOK, struct {
+		Total int          `json:"total"`
+		Users []followUser `json:"users"`
+	}{
+		Total: len(follows),
+		Users: users,
+	})
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/hatrnuhn/chirpy-webserver/internal/auth"
+	"github.com/hatrnuhn/chirpy-webserver/internal/trends"
+)
+
+// hashtags used over the last day are trending, uses count half as much every 2 hours
+var trendsConfig = trends.Config{
+	Window:   24 * time.Hour,
+	HalfLife: 2 * time.Hour,
+}
+
+// responds with a page of the chirps tagged with the hashtag, newest first. authenticated users don't get
+// the chirps their blocks hide
+func (cfg *apiConfig) handleGetHashtagChirps(w http.ResponseWriter, r *http.Request) {
+	tag := strings.TrimPrefix(chi.URLParam(r, "tag"), "#")
+	if tag == "" {
+		respondWithError(w, http.StatusBadRequest, "bad url")
+		return
+	}
+
+	p, err := cfg.authenticateOptional(r, auth.ScopeChirpsRead)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	limit, offset, err := parsePage(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	chirps, err := cfg.db.GetChirpsByHashtag(tag)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't get chirps")
+		return
+	}
+
+	chirps, err = cfg.visibleChirps(p.UserID, chirps, false)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't get chirps")
+		return
+	}
+
+	start, end := pageBounds(len(chirps), limit, offset)
+	resp, err := cfg.newChirpResponses(chirps[start:end])
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't get chirps")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, struct {
+		Total  int             `json:"total"`
+		Chirps []chirpResponse `json:"chirps"`
+	}{
+		Total:  len(chirps),
+		Chirps: resp,
+	})
+}
+
+// responds with the hashtags trending right now, the number of them is limited by the limit query parameter
+func (cfg *apiConfig) handleGetTrends(w http.ResponseWriter, r *http.Request) {
+	limit, _, err := parsePage(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	now := time.Now()
+	chirps, err := cfg.db.GetChirpsSince(now.Add(-trendsConfig.Window).Unix())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't get chirps")
+		return
+	}
+
+	uses := make([]trends.Use, 0)
+	for _, c := range chirps {
+		if c.Entities == nil {
+			continue
+		}
+		for tag := range c.Entities.Tags() {
+			uses = append(uses, trends.Use{Tag: tag, UserID: c.UserID, At: time.Unix(c.CreatedAt, 0)})
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, struct {
+		WindowSeconds int64          `json:"window_seconds"`
+		Trends        []trends.Trend `json:"trends"`
+	}{
+		WindowSeconds: int64(trendsConfig.Window.Seconds()),
+		Trends:        trends.Compute(uses, now, trendsConfig, limit),
+	})
+}
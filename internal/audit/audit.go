@@ -0,0 +1,65 @@
+// Package audit records security relevant events as JSON lines
+package audit
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Entry is a single audit log record
+type Entry struct {
+	Time  time.Time `json:"time"`
+	Event string    `json:"event"`
+	// user who acted, and the user they acted as when it's someone else
+	ActorID   int    `json:"actor_id,omitempty"`
+	SubjectID int    `json:"subject_id,omitempty"`
+	TokenID   string `json:"token_id,omitempty"`
+	Method    string `json:"method,omitempty"`
+	Path      string `json:"path,omitempty"`
+	Status    int    `json:"status,omitempty"`
+	RemoteIP  string `json:"remote_ip,omitempty"`
+}
+
+// Logger appends entries to a writer, one JSON object per line
+type Logger struct {
+	mux *sync.Mutex
+	w   io.Writer
+}
+
+func NewLogger(w io.Writer) *Logger {
+	return &Logger{
+		mux: &sync.Mutex{},
+		w:   w,
+	}
+}
+
+// opens path for appending, creating it if it doesn't exist, and logs to it
+func NewFileLogger(path string) (*Logger, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewLogger(f), nil
+}
+
+// writes e, stamping it with the current time if it has none
+func (l *Logger) Log(e Entry) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+
+	dat, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	_, err = l.w.Write(append(dat, '\n'))
+	return err
+}
@@ -15,7 +15,13 @@ func ParseReq(r *http.Request, jwtSecret string, scheme string) (*jwt.Token, err
 	if err != nil {
 		return nil, errors.New("couldn't read request header")
 	}
-	token, err := jwt.ParseWithClaims(tokenString, &jwt.RegisteredClaims{}, func(token *jwt.Token) (interface{}, error) {
+
+	return ParseToken(tokenString, jwtSecret)
+}
+
+// parses and validates a signed AJWT or RJWT string
+func ParseToken(tokenString string, jwtSecret string) (*jwt.Token, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
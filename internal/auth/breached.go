@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"os"
+	"strings"
+)
+
+// BreachedList is a local list of breached password SHA-1 hashes, indexed the way the
+// k-anonymity range API of Have I Been Pwned is: by the first 5 hex characters of the hash
+type BreachedList struct {
+	ranges map[string]map[string]bool
+}
+
+// LoadBreachedList reads a file of uppercase or lowercase SHA-1 hashes, one per line and
+// optionally followed by :{count} like the Pwned Passwords downloads are
+func LoadBreachedList(path string) (*BreachedList, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	bl := &BreachedList{ranges: make(map[string]map[string]bool)}
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		hash, _, _ := strings.Cut(strings.TrimSpace(sc.Text()), ":")
+		if len(hash) != 40 {
+			continue
+		}
+		hash = strings.ToUpper(hash)
+
+		prefix, suffix := hash[:5], hash[5:]
+		if bl.ranges[prefix] == nil {
+			bl.ranges[prefix] = make(map[string]bool)
+		}
+		bl.ranges[prefix][suffix] = true
+	}
+
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	return bl, nil
+}
+
+// returns the hash suffixes of every breached password whose SHA-1 starts with prefix
+func (bl *BreachedList) Range(prefix string) map[string]bool {
+	return bl.ranges[strings.ToUpper(prefix)]
+}
+
+// reports whether password is in the list, only its hash prefix is used to look the range up
+func (bl *BreachedList) Contains(password string) bool {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	return bl.Range(hash[:5])[hash[5:]]
+}
@@ -0,0 +1,182 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Hasher is a password hashing algorithm
+type Hasher interface {
+	// reports whether encoded was made by this algorithm
+	Identifies(encoded string) bool
+	Hash(password string) (string, error)
+	Verify(password, encoded string) (bool, error)
+	// reports whether encoded was made with other parameters than the hasher's
+	NeedsRehash(encoded string) bool
+}
+
+// PasswordHasher hashes new passwords with Current and verifies hashes made by any of Known
+type PasswordHasher struct {
+	Current Hasher
+	Known   []Hasher
+}
+
+func (ph PasswordHasher) Hash(password string) (string, error) {
+	return ph.Current.Hash(password)
+}
+
+// reports whether password matches encoded, and whether encoded should be replaced by a hash of the current algorithm and parameters
+func (ph PasswordHasher) Verify(password, encoded string) (match bool, needsRehash bool, err error) {
+	for _, h := range append([]Hasher{ph.Current}, ph.Known...) {
+		if !h.Identifies(encoded) {
+			continue
+		}
+
+		match, err = h.Verify(password, encoded)
+		if err != nil || !match {
+			return false, false, err
+		}
+
+		return true, h != ph.Current || h.NeedsRehash(encoded), nil
+	}
+
+	return false, false, errors.New("unknown password hash format")
+}
+
+// BcryptHasher stores hashes in bcrypt's own $2a$ format
+type BcryptHasher struct {
+	Cost int
+}
+
+func (h BcryptHasher) Identifies(encoded string) bool {
+	return strings.HasPrefix(encoded, "$2a$") || strings.HasPrefix(encoded, "$2b$") || strings.HasPrefix(encoded, "$2y$")
+}
+
+func (h BcryptHasher) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), h.Cost)
+	if err != nil {
+		return "", err
+	}
+
+	return string(hash), nil
+}
+
+func (h BcryptHasher) Verify(password, encoded string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (h BcryptHasher) NeedsRehash(encoded string) bool {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	return err != nil || cost != h.Cost
+}
+
+// Argon2idHasher stores hashes in the PHC string format, $argon2id$v=19$m={memory},t={time},p={threads}${salt}${hash}
+type Argon2idHasher struct {
+	// memory in KiB
+	Memory  uint32
+	Time    uint32
+	Threads uint8
+	SaltLen uint32
+	KeyLen  uint32
+}
+
+// RFC 9106 second recommended option
+var DefaultArgon2id = Argon2idHasher{
+	Memory:  64 * 1024,
+	Time:    3,
+	Threads: 4,
+	SaltLen: 16,
+	KeyLen:  32,
+}
+
+var b64 = base64.RawStdEncoding
+
+func (h Argon2idHasher) Identifies(encoded string) bool {
+	return strings.HasPrefix(encoded, "$argon2id$")
+}
+
+func (h Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.SaltLen)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.Time, h.Memory, h.Threads, h.KeyLen)
+
+	return fmt.Sprintf(
+		"$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, h.Memory, h.Time, h.Threads, b64.EncodeToString(salt), b64.EncodeToString(key),
+	), nil
+}
+
+func (h Argon2idHasher) Verify(password, encoded string) (bool, error) {
+	params, salt, key, err := decodeArgon2id(encoded)
+	if err != nil {
+		return false, err
+	}
+
+	other := argon2.IDKey([]byte(password), salt, params.Time, params.Memory, params.Threads, uint32(len(key)))
+
+	return subtle.ConstantTimeCompare(key, other) == 1, nil
+}
+
+func (h Argon2idHasher) NeedsRehash(encoded string) bool {
+	params, salt, key, err := decodeArgon2id(encoded)
+	if err != nil {
+		return true
+	}
+
+	return params.Memory != h.Memory || params.Time != h.Time || params.Threads != h.Threads ||
+		uint32(len(salt)) != h.SaltLen || uint32(len(key)) != h.KeyLen
+}
+
+func decodeArgon2id(encoded string) (Argon2idHasher, []byte, []byte, error) {
+	// "", "argon2id", "v=19", "m=...,t=...,p=...", salt, hash
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return Argon2idHasher{}, nil, nil, errors.New("invalid argon2id hash")
+	}
+
+	var version int
+	_, err := fmt.Sscanf(parts[2], "v=%d", &version)
+	if err != nil || version != argon2.Version {
+		return Argon2idHasher{}, nil, nil, errors.New("unsupported argon2id version")
+	}
+
+	params := Argon2idHasher{}
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Time, &params.Threads)
+	if err != nil {
+		return Argon2idHasher{}, nil, nil, errors.New("invalid argon2id parameters")
+	}
+
+	salt, err := b64.DecodeString(parts[4])
+	if err != nil {
+		return Argon2idHasher{}, nil, nil, errors.New("invalid argon2id salt")
+	}
+
+	key, err := b64.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return Argon2idHasher{}, nil, nil, errors.New("invalid argon2id hash")
+	}
+
+	params.SaltLen = uint32(len(salt))
+	params.KeyLen = uint32(len(key))
+
+	return params, salt, key, nil
+}
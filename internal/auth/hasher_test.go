@@ -0,0 +1,53 @@
+package auth
+
+import (
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestPasswordHasher(t *testing.T) {
+	argon := Argon2idHasher{Memory: 64, Time: 1, Threads: 1, SaltLen: 16, KeyLen: 32}
+	bc := BcryptHasher{Cost: bcrypt.MinCost}
+	ph := PasswordHasher{Current: argon, Known: []Hasher{bc}}
+
+	argonHash, err := ph.Hash("hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bcHash, err := bc.Hash("hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldArgonHash, err := Argon2idHasher{Memory: 32, Time: 1, Threads: 1, SaltLen: 16, KeyLen: 32}.Hash("hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		password    string
+		hash        string
+		match       bool
+		needsRehash bool
+	}{
+		{password: "hunter2", hash: argonHash, match: true, needsRehash: false},
+		{password: "hunter3", hash: argonHash, match: false, needsRehash: false},
+		{password: "hunter2", hash: bcHash, match: true, needsRehash: true},
+		{password: "hunter3", hash: bcHash, match: false, needsRehash: false},
+		{password: "hunter2", hash: oldArgonHash, match: true, needsRehash: true},
+	}
+
+	for i, cs := range cases {
+		match, needsRehash, err := ph.Verify(cs.password, cs.hash)
+		if err != nil {
+			t.Errorf("case %d: %v", i, err)
+		}
+		if match != cs.match || needsRehash != cs.needsRehash {
+			t.Errorf("case %d: expected match %v rehash %v, got %v %v", i, cs.match, cs.needsRehash, match, needsRehash)
+		}
+	}
+
+	if _, _, err := ph.Verify("hunter2", "plaintext"); err == nil {
+		t.Error("unknown hash format should error")
+	}
+}
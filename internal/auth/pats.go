@@ -0,0 +1,40 @@
+package auth
+
+import "strings"
+
+// scopes a personal access token can be granted, AJWTs are granted all of them
+const (
+	ScopeChirpsRead   = "chirps:read"
+	ScopeChirpsWrite  = "chirps:write"
+	ScopeProfileRead  = "profile:read"
+	ScopeProfileWrite = "profile:write"
+)
+
+var Scopes = []string{ScopeChirpsRead, ScopeChirpsWrite, ScopeProfileRead, ScopeProfileWrite}
+
+// prefix of every personal access token, tells them apart from JWTs and makes leaked ones easy to grep for
+const PATPrefix = "chirpy_pat_"
+
+// creates a new personal access token, only its HashToken should be stored
+func CreatePersonalAccessToken() (string, error) {
+	t, err := MakeRandomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	return PATPrefix + t, nil
+}
+
+func IsPersonalAccessToken(token string) bool {
+	return strings.HasPrefix(token, PATPrefix)
+}
+
+func ValidScope(scope string) bool {
+	for _, s := range Scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,23 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+)
+
+// returns the RFC 7636 S256 challenge of verifier
+func PKCEChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// reports whether verifier hashes to the RFC 7636 S256 challenge
+func VerifyPKCE(verifier, challenge string) bool {
+	// 43 to 128 characters, section 4.1
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(PKCEChallenge(verifier)), []byte(challenge)) == 1
+}
@@ -0,0 +1,16 @@
+package auth
+
+// plans a user can be on
+const (
+	PlanFree      = "free"
+	PlanChirpyRed = "chirpy_red"
+)
+
+// returns the plan of a user who is or isn't on Chirpy Red
+func PlanOf(isChirpyRed bool) string {
+	if isChirpyRed {
+		return PlanChirpyRed
+	}
+
+	return PlanFree
+}
@@ -0,0 +1,80 @@
+package auth
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PasswordPolicy is what a password must satisfy before it's accepted
+type PasswordPolicy struct {
+	MinLength int
+	// minimum PasswordScore, 0 to 4
+	MinScore      int
+	DisallowEmail bool
+	// nil to skip the breached password check
+	Breached *BreachedList
+}
+
+// PolicyViolation is a rule a password broke
+type PolicyViolation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// PolicyError lists every rule a password broke
+type PolicyError struct {
+	Violations []PolicyViolation
+}
+
+func (e *PolicyError) Error() string {
+	msgs := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		msgs = append(msgs, v.Message)
+	}
+
+	return "password doesn't meet the policy: " + strings.Join(msgs, ", ")
+}
+
+// checks password of the user registered with email against the policy, returns a *PolicyError if it breaks any rule
+func (p PasswordPolicy) Check(password, email string) error {
+	violations := make([]PolicyViolation, 0)
+
+	if len([]rune(password)) < p.MinLength {
+		violations = append(violations, PolicyViolation{
+			Rule:    "min_length",
+			Message: fmt.Sprintf("password must be at least %d characters long", p.MinLength),
+		})
+	}
+
+	if p.DisallowEmail && email != "" {
+		pw := strings.ToLower(password)
+		email = strings.ToLower(email)
+		local, _, _ := strings.Cut(email, "@")
+		if pw == email || pw == local {
+			violations = append(violations, PolicyViolation{
+				Rule:    "not_email",
+				Message: "password can't be the email address",
+			})
+		}
+	}
+
+	if PasswordScore(password) < p.MinScore {
+		violations = append(violations, PolicyViolation{
+			Rule:    "strength",
+			Message: "password is too easy to guess",
+		})
+	}
+
+	if p.Breached != nil && p.Breached.Contains(password) {
+		violations = append(violations, PolicyViolation{
+			Rule:    "breached",
+			Message: "password has appeared in a data breach",
+		})
+	}
+
+	if len(violations) > 0 {
+		return &PolicyError{Violations: violations}
+	}
+
+	return nil
+}
@@ -0,0 +1,57 @@
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestPasswordPolicy(t *testing.T) {
+	// SHA-1 of "correct horse battery staple" in the Pwned Passwords download format
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	err := os.WriteFile(path, []byte("ABF7AAD6438836DBE526AA231ABDE2D0EEF74D42:7\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bl, err := LoadBreachedList(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	policy := PasswordPolicy{
+		MinLength:     8,
+		MinScore:      2,
+		DisallowEmail: true,
+		Breached:      bl,
+	}
+
+	cases := []struct {
+		password string
+		rules    []string
+	}{
+		{password: "we1rd-Chirps-at-noon", rules: nil},
+		{password: "short", rules: []string{"min_length", "strength"}},
+		{password: "password", rules: []string{"strength"}},
+		{password: "Walter.White@example.com", rules: []string{"not_email"}},
+		{password: "correct horse battery staple", rules: []string{"breached"}},
+	}
+
+	for _, cs := range cases {
+		err := policy.Check(cs.password, "walter.white@example.com")
+
+		var rules []string
+		if pErr, ok := err.(*PolicyError); ok {
+			for _, v := range pErr.Violations {
+				rules = append(rules, v.Rule)
+			}
+		} else if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(rules, cs.rules) {
+			t.Errorf("%q: expected %v, got %v", cs.password, cs.rules, rules)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// creates a hex encoded token from n random bytes, for single-use links and other opaque secrets
+func MakeRandomToken(n int) (string, error) {
+	b := make([]byte, n)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// hashes an opaque token for storage, tokens are random enough that a fast hash is fine
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// creates n one-time recovery codes formatted as xxxxx-xxxxx
+func GenerateRecoveryCodes(n int) ([]string, error) {
+	codes := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		t, err := MakeRandomToken(5)
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, t[:5]+"-"+t[5:])
+	}
+
+	return codes, nil
+}
@@ -0,0 +1,45 @@
+package auth
+
+// roles from least to most privileged, every role can do what the ones before it can
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+var roleRanks = map[string]int{
+	RoleUser:      1,
+	RoleModerator: 2,
+	RoleAdmin:     3,
+}
+
+func ValidRole(role string) bool {
+	_, ok := roleRanks[role]
+	return ok
+}
+
+// returns role and every role less privileged than it, an empty role is a user
+func EffectiveRoles(role string) []string {
+	roles := make([]string, 0, len(roleRanks))
+	for _, r := range []string{RoleUser, RoleModerator, RoleAdmin} {
+		if HasRole(role, r) {
+			roles = append(roles, r)
+		}
+	}
+
+	return roles
+}
+
+// reports whether role is at least as privileged as want, an empty role is a user
+func HasRole(role, want string) bool {
+	if role == "" {
+		role = RoleUser
+	}
+
+	have, ok := roleRanks[role]
+	if !ok {
+		return false
+	}
+
+	return have >= roleRanks[want]
+}
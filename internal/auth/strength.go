@@ -0,0 +1,146 @@
+package auth
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// a handful of the most used passwords, anything this common is guessed right away
+var commonPasswords = map[string]bool{
+	"password": true, "123456": true, "12345678": true, "123456789": true, "1234567890": true,
+	"qwerty": true, "qwertyuiop": true, "abc123": true, "111111": true, "123123": true,
+	"letmein": true, "welcome": true, "monkey": true, "dragon": true, "football": true,
+	"baseball": true, "iloveyou": true, "admin": true, "login": true, "master": true,
+	"sunshine": true, "princess": true, "passw0rd": true, "shadow": true, "superman": true,
+	"trustno1": true, "starwars": true, "whatever": true, "hello": true, "freedom": true,
+	"chirpy": true, "password1": true, "qwerty123": true, "1q2w3e4r": true, "zaq12wsx": true,
+}
+
+var keyboardRows = []string{"`1234567890-=", "qwertyuiop[]\\", "asdfghjkl;'", "zxcvbnm,./"}
+
+var leetSubs = strings.NewReplacer("0", "o", "1", "i", "3", "e", "4", "a", "5", "s", "7", "t", "@", "a", "$", "s")
+
+// PasswordScore estimates how hard password is to guess on zxcvbn's 0 to 4 scale,
+// by the log10 of the guesses needed: under 3, 6, 8 and 10, or more.
+// it's a rough estimate: common passwords score 0, repeats, sequences and keyboard runs
+// barely count, and characters are worth less the longer the password gets since people
+// mostly type words rather than random characters
+func PasswordScore(password string) int {
+	guesses := passwordGuessesLog10(password)
+
+	switch {
+	case guesses < 3:
+		return 0
+	case guesses < 6:
+		return 1
+	case guesses < 8:
+		return 2
+	case guesses < 10:
+		return 3
+	default:
+		return 4
+	}
+}
+
+func passwordGuessesLog10(password string) float64 {
+	lower := strings.ToLower(password)
+	if commonPasswords[lower] || commonPasswords[leetSubs.Replace(lower)] {
+		return 0
+	}
+
+	runes := []rune(lower)
+	chars := 0
+	for i := 0; i < len(runes); {
+		n := patternRun(runes[i:])
+		if n >= 3 {
+			// a whole run of a pattern is about as hard to guess as a couple characters
+			chars += 2
+			i += n
+			continue
+		}
+		chars++
+		i++
+	}
+
+	// NIST SP 800-63 (2004) entropy estimate: 4 bits for the first character, 2 for the next 7,
+	// 1.5 for the 12 after those and 1 for the rest, plus 6 for mixing cases and non letters
+	bits := 0.0
+	for i := 1; i <= chars; i++ {
+		switch {
+		case i == 1:
+			bits += 4
+		case i <= 8:
+			bits += 2
+		case i <= 20:
+			bits += 1.5
+		default:
+			bits++
+		}
+	}
+	if mixedComposition(password) {
+		bits += 6
+	}
+
+	return bits * math.Log10(2)
+}
+
+// returns the length of the repeat, sequence or keyboard run at the start of rs
+func patternRun(rs []rune) int {
+	if len(rs) < 2 {
+		return len(rs)
+	}
+
+	best := 1
+	for _, delta := range []rune{0, 1, -1} {
+		n := 1
+		for n < len(rs) && rs[n]-rs[n-1] == delta {
+			n++
+		}
+		if n > best {
+			best = n
+		}
+	}
+
+	for _, row := range keyboardRows {
+		for _, r := range []string{row, reverse(row)} {
+			start := strings.IndexRune(r, rs[0])
+			if start < 0 {
+				continue
+			}
+			n := 1
+			for n < len(rs) && start+n < len(r) && rune(r[start+n]) == rs[n] {
+				n++
+			}
+			if n > best {
+				best = n
+			}
+		}
+	}
+
+	return best
+}
+
+// reports whether password has both upper case letters and characters that aren't letters
+func mixedComposition(password string) bool {
+	var upper, nonLetter bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case !unicode.IsLetter(r):
+			nonLetter = true
+		}
+	}
+
+	return upper && nonLetter
+}
+
+func reverse(s string) string {
+	rs := []rune(s)
+	for i, j := 0, len(rs)-1; i < j; i, j = i+1, j-1 {
+		rs[i], rs[j] = rs[j], rs[i]
+	}
+
+	return string(rs)
+}
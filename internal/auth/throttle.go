@@ -0,0 +1,108 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+// ThrottleConfig tunes a LoginThrottle
+type ThrottleConfig struct {
+	// failures allowed before attempts get delayed
+	FreeAttempts int
+	// delay after the first failure past FreeAttempts, doubled on every failure after it up to MaxDelay
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// failures after which the key is locked out for LockoutDuration
+	LockoutAfter    int
+	LockoutDuration time.Duration
+	// failures are forgotten after this long without a new one
+	ForgetAfter time.Duration
+}
+
+// LoginThrottle tracks failed login attempts per key, usually an account or an IP address,
+// and backs off exponentially before locking the key out. state is kept in memory
+type LoginThrottle struct {
+	cfg      ThrottleConfig
+	mux      *sync.Mutex
+	attempts map[string]*loginAttempts
+	now      func() time.Time
+}
+
+type loginAttempts struct {
+	failures     int
+	lastFailure  time.Time
+	blockedUntil time.Time
+}
+
+func NewLoginThrottle(cfg ThrottleConfig) *LoginThrottle {
+	return &LoginThrottle{
+		cfg:      cfg,
+		mux:      &sync.Mutex{},
+		attempts: make(map[string]*loginAttempts),
+		now:      time.Now,
+	}
+}
+
+// reports whether key may attempt to log in, and if not how long until it may
+func (t *LoginThrottle) Allow(key string) (time.Duration, bool) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	a, ok := t.attempts[key]
+	if !ok {
+		return 0, true
+	}
+
+	now := t.now()
+	if wait := a.blockedUntil.Sub(now); wait > 0 {
+		return wait, false
+	}
+
+	return 0, true
+}
+
+// records a failed attempt for key
+func (t *LoginThrottle) Fail(key string) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	now := t.now()
+	t.forget(now)
+
+	a, ok := t.attempts[key]
+	if !ok {
+		a = &loginAttempts{}
+		t.attempts[key] = a
+	}
+
+	a.failures++
+	a.lastFailure = now
+
+	switch {
+	case t.cfg.LockoutAfter > 0 && a.failures >= t.cfg.LockoutAfter:
+		a.blockedUntil = now.Add(t.cfg.LockoutDuration)
+	case a.failures > t.cfg.FreeAttempts:
+		delay := t.cfg.BaseDelay << (a.failures - t.cfg.FreeAttempts - 1)
+		if delay > t.cfg.MaxDelay || delay <= 0 {
+			delay = t.cfg.MaxDelay
+		}
+		a.blockedUntil = now.Add(delay)
+	}
+}
+
+// forgets every failure of key, after a successful login or when an admin unlocks it
+func (t *LoginThrottle) Reset(key string) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	delete(t.attempts, key)
+}
+
+// drops keys that haven't failed in a while so the map doesn't grow forever, t.mux must be held
+func (t *LoginThrottle) forget(now time.Time) {
+	for k, a := range t.attempts {
+		if now.Sub(a.lastFailure) > t.cfg.ForgetAfter && now.After(a.blockedUntil) {
+			delete(t.attempts, k)
+		}
+	}
+}
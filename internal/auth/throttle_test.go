@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoginThrottle(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	lt := NewLoginThrottle(ThrottleConfig{
+		FreeAttempts:    2,
+		BaseDelay:       time.Second,
+		MaxDelay:        time.Minute,
+		LockoutAfter:    5,
+		LockoutDuration: time.Hour,
+		ForgetAfter:     24 * time.Hour,
+	})
+	lt.now = func() time.Time { return now }
+
+	cases := []struct {
+		wait time.Duration
+	}{
+		{wait: 0},
+		{wait: 0},
+		{wait: time.Second},
+		{wait: 2 * time.Second},
+		{wait: time.Hour},
+	}
+
+	for i, cs := range cases {
+		lt.Fail("a")
+		wait, ok := lt.Allow("a")
+		if wait != cs.wait || ok != (cs.wait == 0) {
+			t.Errorf("failure %d: expected to wait %v, got %v", i+1, cs.wait, wait)
+		}
+	}
+
+	if _, ok := lt.Allow("b"); !ok {
+		t.Error("other keys should not be throttled")
+	}
+
+	lt.Reset("a")
+	if _, ok := lt.Allow("a"); !ok {
+		t.Error("reset key should not be throttled")
+	}
+}
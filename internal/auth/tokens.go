@@ -2,19 +2,51 @@ package auth
 
 import (
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// Claims are the claims of every token issued by chirpy, only AJWTs carry a role and a plan
+type Claims struct {
+	jwt.RegisteredClaims
+	Role string `json:"role,omitempty"`
+	// one of the Plan constants, as of when the AJWT was issued
+	Plan string `json:"plan,omitempty"`
+	// the user's token generation when the AJWT or RJWT was issued, it's rejected once the user's is newer
+	Generation int `json:"gen,omitempty"`
+	// set on impersonation AJWTs, the admin acting as the subject
+	Actor *Actor `json:"act,omitempty"`
+	// set on tokens issued to third-party OAuth clients, which are limited to the space separated scopes
+	ClientID string `json:"client_id,omitempty"`
+	Scope    string `json:"scope,omitempty"`
+}
+
+// Actor is the RFC 8693 act claim
+type Actor struct {
+	Subject string `json:"sub"`
+}
+
 // creates access token, straightforward
-func CreateAccessToken(userID int, secretKey string, expiresInSeconds int64) (string, error) {
+func CreateAccessToken(userID int, role string, plan string, generation int, secretKey string, expiresInSeconds int64) (string, error) {
+	jti, err := MakeRandomToken(16)
+	if err != nil {
+		return "", err
+	}
+
 	// Create the Claims
-	aClaims := &jwt.RegisteredClaims{
-		Issuer:    "chirpy-access",
-		IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
-		ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(time.Duration(expiresInSeconds) * time.Second)),
-		Subject:   strconv.Itoa(userID), // Convert userID to string
+	aClaims := &Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    "chirpy-access",
+			IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(time.Duration(expiresInSeconds) * time.Second)),
+			Subject:   strconv.Itoa(userID), // Convert userID to string
+			ID:        jti,
+		},
+		Role:       role,
+		Plan:       plan,
+		Generation: generation,
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, aClaims)
@@ -26,16 +58,146 @@ func CreateAccessToken(userID int, secretKey string, expiresInSeconds int64) (st
 	return ss, nil
 }
 
+// creates a short-lived AJWT for an admin acting as another user, there's no RJWT to go with it.
+// the returned ID identifies it in the audit log
+func CreateImpersonationToken(userID int, role string, plan string, generation int, actorID int, secretKey string, expiresInSeconds int64) (string, string, error) {
+	jti, err := MakeRandomToken(16)
+	if err != nil {
+		return "", "", err
+	}
+
+	aClaims := &Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    "chirpy-access",
+			IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(time.Duration(expiresInSeconds) * time.Second)),
+			Subject:   strconv.Itoa(userID),
+			ID:        jti,
+		},
+		Role:       role,
+		Plan:       plan,
+		Generation: generation,
+		Actor:      &Actor{Subject: strconv.Itoa(actorID)},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, aClaims)
+	ss, err := token.SignedString([]byte(secretKey))
+	if err != nil {
+		return "", "", err
+	}
+
+	return ss, jti, nil
+}
+
 // creates refresh token, simple
-func CreateRefreshToken(userID int, secretKey string, expiresInSeconds int64) (string, error) {
-	rClaims := &jwt.RegisteredClaims{
-		Issuer:    "chirpy-refresh",
+func CreateRefreshToken(userID int, generation int, secretKey string, expiresInSeconds int64) (string, error) {
+	jti, err := MakeRandomToken(16)
+	if err != nil {
+		return "", err
+	}
+
+	rClaims := &Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    "chirpy-refresh",
+			IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(time.Duration(expiresInSeconds) * time.Second)),
+			Subject:   strconv.Itoa(userID),
+			ID:        jti,
+		},
+		Generation: generation,
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, rClaims)
+	ss, err := token.SignedString([]byte(secretKey))
+	if err != nil {
+		return "", err
+	}
+
+	return ss, nil
+}
+
+// creates a short-lived MFA challenge token, it proves the password was right and is exchanged
+// for an AJWT and RJWT pair along with a TOTP or recovery code
+func CreateMFAToken(userID int, secretKey string, expiresInSeconds int64) (string, error) {
+	jti, err := MakeRandomToken(16)
+	if err != nil {
+		return "", err
+	}
+
+	mClaims := &jwt.RegisteredClaims{
+		Issuer:    "chirpy-mfa",
 		IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
 		ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(time.Duration(expiresInSeconds) * time.Second)),
 		Subject:   strconv.Itoa(userID),
+		ID:        jti,
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, rClaims)
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, mClaims)
+	ss, err := token.SignedString([]byte(secretKey))
+	if err != nil {
+		return "", err
+	}
+
+	return ss, nil
+}
+
+// creates a short-lived magic link token that logs the user in without a password, the returned ID
+// has to be recorded so the link can only be used once
+func CreateMagicLinkToken(userID int, secretKey string, expiresInSeconds int64) (string, string, error) {
+	jti, err := MakeRandomToken(16)
+	if err != nil {
+		return "", "", err
+	}
+
+	mClaims := &jwt.RegisteredClaims{
+		Issuer:    "chirpy-magic-link",
+		IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
+		ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(time.Duration(expiresInSeconds) * time.Second)),
+		Subject:   strconv.Itoa(userID),
+		ID:        jti,
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, mClaims)
+	ss, err := token.SignedString([]byte(secretKey))
+	if err != nil {
+		return "", "", err
+	}
+
+	return ss, jti, nil
+}
+
+// creates an AJWT for a third-party OAuth client, it's only granted scopes
+func CreateOAuthAccessToken(userID int, role string, plan string, generation int, clientID string, scopes []string, secretKey string, expiresInSeconds int64) (string, error) {
+	return signOAuthToken("chirpy-access", userID, role, plan, generation, clientID, scopes, secretKey, expiresInSeconds)
+}
+
+// creates an RJWT for a third-party OAuth client, AJWTs it's refreshed into are only granted scopes
+func CreateOAuthRefreshToken(userID int, generation int, clientID string, scopes []string, secretKey string, expiresInSeconds int64) (string, error) {
+	return signOAuthToken("chirpy-refresh", userID, "", "", generation, clientID, scopes, secretKey, expiresInSeconds)
+}
+
+func signOAuthToken(issuer string, userID int, role string, plan string, generation int, clientID string, scopes []string, secretKey string, expiresInSeconds int64) (string, error) {
+	jti, err := MakeRandomToken(16)
+	if err != nil {
+		return "", err
+	}
+
+	claims := &Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    issuer,
+			IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(time.Duration(expiresInSeconds) * time.Second)),
+			Subject:   strconv.Itoa(userID),
+			ID:        jti,
+		},
+		Role:       role,
+		Plan:       plan,
+		Generation: generation,
+		ClientID:   clientID,
+		Scope:      strings.Join(scopes, " "),
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	ss, err := token.SignedString([]byte(secretKey))
 	if err != nil {
 		return "", err
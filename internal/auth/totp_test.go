@@ -0,0 +1,63 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+// RFC 6238 appendix B SHA1 test vectors
+func TestHOTPVectors(t *testing.T) {
+	key := []byte("12345678901234567890")
+	cases := []struct {
+		unix int64
+		code string
+	}{
+		{unix: 59, code: "94287082"},
+		{unix: 1111111109, code: "07081804"},
+		{unix: 1111111111, code: "14050471"},
+		{unix: 1234567890, code: "89005924"},
+		{unix: 2000000000, code: "69279037"},
+		{unix: 20000000000, code: "65353130"},
+	}
+
+	for _, cs := range cases {
+		act := hotp(key, uint64(cs.unix/totpPeriod), 8)
+		if act != cs.code {
+			t.Errorf("at %d: expected %s, got %s", cs.unix, cs.code, act)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Unix(1700000000, 0)
+	code, err := TOTPCode(secret, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		at time.Time
+		ok bool
+	}{
+		{at: now, ok: true},
+		{at: now.Add(totpPeriod * time.Second), ok: true},
+		{at: now.Add(-totpPeriod * time.Second), ok: true},
+		{at: now.Add(3 * totpPeriod * time.Second), ok: false},
+	}
+
+	for _, cs := range cases {
+		_, ok := ValidateTOTP(secret, code, cs.at)
+		if ok != cs.ok {
+			t.Errorf("at %v: expected %v, got %v", cs.at, cs.ok, ok)
+		}
+	}
+
+	if _, ok := ValidateTOTP(secret, "12345", now); ok {
+		t.Error("short code should not validate")
+	}
+}
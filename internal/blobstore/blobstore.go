@@ -0,0 +1,107 @@
+// Package blobstore stores binary objects, like uploaded media, under string keys
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrNotExist is returned when there's no blob under a key
+var ErrNotExist = errors.New("blob doesn't exist")
+
+// BlobStore stores blobs under keys made of letters, digits, dashes, underscores and dots
+type BlobStore interface {
+	Put(ctx context.Context, key string, r io.Reader) error
+	// the caller closes the returned reader
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// deleting a key that doesn't exist isn't an error
+	Delete(ctx context.Context, key string) error
+}
+
+// Disk stores each blob in a file of its own in a directory
+type Disk struct {
+	dir string
+}
+
+// returns a Disk storing blobs in dir, which is created if it doesn't exist
+func NewDisk(dir string) (*Disk, error) {
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Disk{dir: dir}, nil
+}
+
+func (d *Disk) Put(_ context.Context, key string, r io.Reader) error {
+	path, err := d.path(key)
+	if err != nil {
+		return err
+	}
+
+	// written to a temporary file first so a failed write never leaves a partial blob behind
+	f, err := os.CreateTemp(d.dir, ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	_, err = io.Copy(f, r)
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
+
+func (d *Disk) Get(_ context.Context, key string) (io.ReadCloser, error) {
+	path, err := d.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotExist
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+func (d *Disk) Delete(_ context.Context, key string) error {
+	path, err := d.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+// keys can't name anything outside of the directory
+func (d *Disk) path(key string) (string, error) {
+	if key == "" || strings.HasPrefix(key, ".") || strings.IndexFunc(key, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.')
+	}) != -1 {
+		return "", errors.New("invalid blob key")
+	}
+
+	return filepath.Join(d.dir, key), nil
+}
@@ -0,0 +1,49 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestDisk(t *testing.T) {
+	ctx := context.Background()
+	d, err := NewDisk(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = d.Put(ctx, "a.jpg", strings.NewReader("blob"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rc, err := d.Get(ctx, "a.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dat, _ := io.ReadAll(rc)
+	rc.Close()
+	if string(dat) != "blob" {
+		t.Errorf("expected to get the blob back, got %q", dat)
+	}
+
+	err = d.Delete(ctx, "a.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Get(ctx, "a.jpg"); !errors.Is(err, ErrNotExist) {
+		t.Errorf("expected ErrNotExist after deleting, got %v", err)
+	}
+	if err := d.Delete(ctx, "a.jpg"); err != nil {
+		t.Errorf("deleting twice should be fine, got %v", err)
+	}
+
+	for _, key := range []string{"", "../a", "a/b", ".hidden"} {
+		if err := d.Put(ctx, key, strings.NewReader("x")); err == nil {
+			t.Errorf("expected key %q to be rejected", key)
+		}
+	}
+}
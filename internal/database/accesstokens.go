@@ -0,0 +1,135 @@
+package database
+
+import (
+	"errors"
+	"sort"
+)
+
+// stores a new personal access token and returns it with its assigned ID
+func (db *DB) CreatePersonalAccessToken(pat PersonalAccessToken) (PersonalAccessToken, error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	dbS, err := db.loadDB()
+	if err != nil {
+		return PersonalAccessToken{}, err
+	}
+
+	if dbS.AccessTokens == nil {
+		dbS.AccessTokens = make(map[int]PersonalAccessToken)
+	}
+
+	// tokens get deleted, so the next ID can't be derived from the count
+	id := 1
+	for k := range dbS.AccessTokens {
+		if k >= id {
+			id = k + 1
+		}
+	}
+
+	pat.ID = id
+	dbS.AccessTokens[id] = pat
+
+	err = db.writeDB(dbS)
+	if err != nil {
+		return PersonalAccessToken{}, errors.New("couldn't write to db")
+	}
+
+	return pat, nil
+}
+
+// returns the user's personal access tokens sorted by ID
+func (db *DB) GetPersonalAccessTokens(uID int) ([]PersonalAccessToken, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	dbS, err := db.loadDB()
+	if err != nil {
+		return nil, err
+	}
+
+	pats := make([]PersonalAccessToken, 0)
+	for _, pat := range dbS.AccessTokens {
+		if pat.UserID == uID {
+			pats = append(pats, pat)
+		}
+	}
+
+	sort.Slice(pats, func(i, j int) bool {
+		return pats[i].ID < pats[j].ID
+	})
+
+	return pats, nil
+}
+
+// returns the personal access token with the given hash or ErrNotExist
+func (db *DB) GetPersonalAccessTokenByHash(hash string) (PersonalAccessToken, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	dbS, err := db.loadDB()
+	if err != nil {
+		return PersonalAccessToken{}, err
+	}
+
+	for _, pat := range dbS.AccessTokens {
+		if pat.Hash == hash {
+			return pat, nil
+		}
+	}
+
+	return PersonalAccessToken{}, ErrNotExist
+}
+
+// deletes the user's personal access token, ErrNotExist if the user has no token with that id
+func (db *DB) DeletePersonalAccessToken(uID, id int) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	dbS, err := db.loadDB()
+	if err != nil {
+		return err
+	}
+
+	pat, ok := dbS.AccessTokens[id]
+	if !ok || pat.UserID != uID {
+		return ErrNotExist
+	}
+
+	delete(dbS.AccessTokens, id)
+
+	err = db.writeDB(dbS)
+	if err != nil {
+		return errors.New("couldn't write to db")
+	}
+
+	return nil
+}
+
+// deletes every personal access token of the user
+func (db *DB) DeleteUserAccessTokens(uID int) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	dbS, err := db.loadDB()
+	if err != nil {
+		return err
+	}
+
+	deleteAccessTokens(&dbS, uID)
+
+	err = db.writeDB(dbS)
+	if err != nil {
+		return errors.New("couldn't write to db")
+	}
+
+	return nil
+}
+
+func deleteAccessTokens(dbS *DBStructure, uID int) {
+	for id, pat := range dbS.AccessTokens {
+		if pat.UserID == uID {
+			delete(dbS.AccessTokens, id)
+		}
+	}
+}
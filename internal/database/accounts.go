@@ -0,0 +1,131 @@
+package database
+
+import (
+	"errors"
+	"time"
+)
+
+// deletes the user's account: their chirps, likes, follows, timeline, notifications, personal access tokens, OAuth clients and grants,
+// held chirps, pending password resets, magic links, data exports and media are removed, and the user is anonymized rather
+// than removed so that its ID is never handed out again. every token issued to them is revoked by
+// bumping their token generation. returns the removed data exports and media so their archives and
+// blobs can be deleted too
+func (db *DB) DeleteUserData(uID int) ([]DataExport, []Media, error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	dbS, err := db.loadDB()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	u, ok := dbS.Users[uID]
+	if !ok || u.DeletedAt != 0 {
+		return nil, nil, ErrNotExist
+	}
+
+	media := make([]Media, 0)
+	for id, c := range dbS.Chirps {
+		if c.UserID == uID && c.DeletedAt == 0 {
+			media = append(media, removeChirp(&dbS, id)...)
+		}
+	}
+
+	for id, h := range dbS.HeldChirps {
+		if h.UserID == uID {
+			delete(dbS.HeldChirps, id)
+		}
+	}
+
+	// reports about the user are kept for moderators
+	for id, rep := range dbS.Reports {
+		if rep.ReporterID == uID {
+			delete(dbS.Reports, id)
+		}
+	}
+
+	// uploads that were never attached to a chirp
+	for id, m := range dbS.Media {
+		if m.UserID == uID {
+			media = append(media, m)
+			delete(dbS.Media, id)
+		}
+	}
+
+	for key, l := range dbS.Likes {
+		if l.UserID == uID {
+			removeLike(&dbS, key)
+		}
+	}
+
+	for key, f := range dbS.Follows {
+		if f.FollowerID == uID || f.FolloweeID == uID {
+			delete(dbS.Follows, key)
+		}
+	}
+
+	for key, b := range dbS.Blocks {
+		if b.BlockerID == uID || b.BlockedID == uID {
+			delete(dbS.Blocks, key)
+		}
+	}
+
+	for key, m := range dbS.Mutes {
+		if m.MuterID == uID || m.MutedID == uID {
+			delete(dbS.Mutes, key)
+		}
+	}
+	delete(dbS.Inboxes, uID)
+	unnotify(&dbS, func(n Notification) bool { return n.UserID == uID || n.ActorID == uID })
+
+	for id, pat := range dbS.AccessTokens {
+		if pat.UserID == uID {
+			delete(dbS.AccessTokens, id)
+		}
+	}
+
+	for id, c := range dbS.OAuthClients {
+		if c.OwnerID == uID {
+			delete(dbS.OAuthClients, id)
+		}
+	}
+
+	for h, c := range dbS.OAuthCodes {
+		if c.UserID == uID {
+			delete(dbS.OAuthCodes, h)
+		}
+	}
+
+	for h, pr := range dbS.PasswordResets {
+		if pr.UserID == uID {
+			delete(dbS.PasswordResets, h)
+		}
+	}
+
+	for id, ml := range dbS.MagicLinks {
+		if ml.UserID == uID {
+			delete(dbS.MagicLinks, id)
+		}
+	}
+
+	exports := make([]DataExport, 0)
+	for id, e := range dbS.DataExports {
+		if e.UserID == uID {
+			exports = append(exports, e)
+			delete(dbS.DataExports, id)
+		}
+	}
+
+	dbS.Users[uID] = User{
+		ID:              uID,
+		TokenGeneration: u.TokenGeneration + 1,
+		DeletedAt:       time.Now().Unix(),
+	}
+
+	err = db.writeDB(dbS)
+	if err != nil {
+		return nil, nil, errors.New("couldn't write to db")
+	}
+
+	return exports, media, nil
+}
@@ -0,0 +1,146 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+var (
+	// ErrSelfBlock is returned when a user tries to block themselves
+	ErrSelfBlock = errors.New("users can't block themselves")
+	// ErrBlocked is returned when a user interacts with someone they blocked or who blocked them
+	ErrBlocked = errors.New("one of the users blocked the other")
+)
+
+func blockKey(blockerID, blockedID int) string {
+	return fmt.Sprintf("%d:%d", blockerID, blockedID)
+}
+
+// reports whether either user blocked the other
+func blocked(dbS *DBStructure, uID, otherID int) bool {
+	_, ok := dbS.Blocks[blockKey(uID, otherID)]
+	if ok {
+		return true
+	}
+
+	_, ok = dbS.Blocks[blockKey(otherID, uID)]
+	return ok
+}
+
+// returns the IDs of the users the user blocked or who blocked them
+func (db *DB) BlockedUsers(uID int) (map[int]bool, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	dbS, err := db.loadDB()
+	if err != nil {
+		return nil, err
+	}
+
+	return newViewFilter(&dbS, uID, false).hidden, nil
+}
+
+// records the blocker blocking the blocked user, blocking someone twice is a no-op. the returned bool
+// is true if the block is new. follows between them are removed along with the notifications they caused
+// each other. returns ErrNotExist if there's no such user to block
+func (db *DB) Block(blockerID, blockedID int) (Block, bool, error) {
+	if blockerID == blockedID {
+		return Block{}, false, ErrSelfBlock
+	}
+
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	dbS, err := db.loadDB()
+	if err != nil {
+		return Block{}, false, err
+	}
+
+	u, ok := dbS.Users[blockedID]
+	if !ok || u.DeletedAt != 0 {
+		return Block{}, false, ErrNotExist
+	}
+
+	if dbS.Blocks == nil {
+		dbS.Blocks = make(map[string]Block)
+	}
+
+	key := blockKey(blockerID, blockedID)
+	if b, ok := dbS.Blocks[key]; ok {
+		return b, false, nil
+	}
+
+	b := Block{
+		BlockerID: blockerID,
+		BlockedID: blockedID,
+		BlockedAt: time.Now().Unix(),
+	}
+	dbS.Blocks[key] = b
+
+	delete(dbS.Follows, followKey(blockerID, blockedID))
+	delete(dbS.Follows, followKey(blockedID, blockerID))
+	unnotify(&dbS, func(n Notification) bool {
+		return (n.UserID == blockerID && n.ActorID == blockedID) || (n.UserID == blockedID && n.ActorID == blockerID)
+	})
+
+	err = db.writeDB(dbS)
+	if err != nil {
+		return Block{}, false, errors.New("couldn't write to db")
+	}
+
+	return b, true, nil
+}
+
+// removes the blocker's block of the blocked user, unblocking someone who isn't blocked is a no-op.
+// the follows the block removed aren't restored
+func (db *DB) Unblock(blockerID, blockedID int) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	dbS, err := db.loadDB()
+	if err != nil {
+		return err
+	}
+
+	key := blockKey(blockerID, blockedID)
+	if _, ok := dbS.Blocks[key]; !ok {
+		return nil
+	}
+	delete(dbS.Blocks, key)
+
+	err = db.writeDB(dbS)
+	if err != nil {
+		return errors.New("couldn't write to db")
+	}
+
+	return nil
+}
+
+// returns the blocks by the user, most recent first
+func (db *DB) GetBlocks(uID int) ([]Block, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	dbS, err := db.loadDB()
+	if err != nil {
+		return nil, err
+	}
+
+	blocks := make([]Block, 0)
+	for _, b := range dbS.Blocks {
+		if b.BlockerID == uID {
+			blocks = append(blocks, b)
+		}
+	}
+
+	sort.Slice(blocks, func(i, j int) bool {
+		if blocks[i].BlockedAt != blocks[j].BlockedAt {
+			return blocks[i].BlockedAt > blocks[j].BlockedAt
+		}
+		return blocks[i].BlockedID > blocks[j].BlockedID
+	})
+
+	return blocks, nil
+}
@@ -6,16 +6,34 @@ import (
 	"os"
 	"sort"
 	"sync"
+	"time"
 
+	"github.com/hatrnuhn/chirpy-webserver/internal/auth"
+	"github.com/hatrnuhn/chirpy-webserver/internal/entities"
 	"golang.org/x/crypto/bcrypt"
 )
 
+type bcryptHasher struct{}
+
+func (bcryptHasher) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	return string(hash), err
+}
+
+// ErrNotExist is returned when a looked up record isn't in the database
+var ErrNotExist = errors.New("record doesn't exist")
+
+// ErrTokenRevoked is returned when a refresh token that's revoked or was never issued is rotated
+var ErrTokenRevoked = errors.New("refresh token is revoked")
+
 // NewDB creates a new database connection
 // and creates the database file if it doesn't exist
 func NewDB(path string) (*DB, error) {
 	db := DB{
-		path: path,
-		mux:  &sync.RWMutex{},
+		path:        path,
+		mux:         &sync.RWMutex{},
+		hasher:      bcryptHasher{},
+		fanOutLimit: DefaultFanOutLimit,
 	}
 
 	err := db.ensureDB()
@@ -26,7 +44,20 @@ func NewDB(path string) (*DB, error) {
 	return &db, nil
 }
 
-// CreateChirp creates a new chirp and saves it to disk
+// SetPasswordHasher replaces the bcrypt hasher passwords are stored with by CreateUser and UpdateUser
+func (db *DB) SetPasswordHasher(h PasswordHasher) {
+	db.hasher = h
+}
+
+// SetFanOutLimit sets how many followers an author can have for their chirps to still be fanned out
+// to their followers' inboxes
+func (db *DB) SetFanOutLimit(n int) {
+	db.fanOutLimit = n
+}
+
+// CreateChirp creates a new chirp and saves it to disk, it can reply to, rechirp or quote another chirp.
+// returns ErrNotExist if that chirp doesn't exist and ErrAlreadyRechirped if the user rechirped it before.
+// up to MaxChirpMedia of the user's unattached media can be attached to it, ErrMediaUnusable is returned otherwise
 func (db *DB) CreateChirp(c Chirp, uID int) (Chirp, error) {
 	db.mux.Lock()
 	defer db.mux.Unlock()
@@ -36,15 +67,11 @@ func (db *DB) CreateChirp(c Chirp, uID int) (Chirp, error) {
 		return Chirp{}, err
 	}
 
-	id := len(dbS.Chirps) + 1
-
-	chp := Chirp{
-		ID:     id,
-		Body:   c.Body,
-		UserID: uID,
+	chp, err := createChirp(&dbS, c, uID, db.fanOutLimit)
+	if err != nil {
+		return Chirp{}, err
 	}
 
-	dbS.Chirps[id] = chp
 	err = db.writeDB(dbS)
 	if err != nil {
 		return Chirp{}, errors.New("writeDB error")
@@ -53,23 +80,78 @@ func (db *DB) CreateChirp(c Chirp, uID int) (Chirp, error) {
 	return chp, nil
 }
 
-func (db *DB) DeleteChirp(id int) error {
+func createChirp(dbS *DBStructure, c Chirp, uID int, fanOutLimit int) (Chirp, error) {
+	// chirps can be deleted, so the count isn't necessarily free
+	id := 1
+	for cID := range dbS.Chirps {
+		if cID >= id {
+			id = cID + 1
+		}
+	}
+
+	chp := Chirp{
+		ID:             id,
+		Body:           c.Body,
+		UserID:         uID,
+		CreatedAt:      time.Now().Unix(),
+		ConversationID: id,
+	}
+
+	if c.InReplyToID != 0 {
+		parent, ok := sharedChirp(dbS, c.InReplyToID)
+		if !ok {
+			return Chirp{}, ErrNotExist
+		}
+		if blocked(dbS, uID, parent.UserID) {
+			return Chirp{}, ErrBlocked
+		}
+
+		chp.InReplyToID = parent.ID
+		chp.ConversationID = conversationID(parent)
+	}
+
+	err := shareChirp(dbS, &chp, c)
+	if err != nil {
+		return Chirp{}, err
+	}
+
+	err = attachMedia(dbS, &chp, c.MediaIDs)
+	if err != nil {
+		return Chirp{}, err
+	}
+
+	if chp.Body != "" {
+		e := entities.Parse(chp.Body)
+		resolveMentions(dbS, uID, e.Mentions)
+		chp.Entities = &e
+	}
+
+	fanOut(dbS, &chp, fanOutLimit)
+	notifyChirp(dbS, chp)
+
+	dbS.Chirps[id] = chp
+
+	return chp, nil
+}
+
+// deletes the chirp, returns the removed media so their blobs can be deleted too
+func (db *DB) DeleteChirp(id int) ([]Media, error) {
 	db.mux.Lock()
 	defer db.mux.Unlock()
 
 	dbS, err := db.loadDB()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	delete(dbS.Chirps, id)
+	media := removeChirp(&dbS, id)
 
 	err = db.writeDB(dbS)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
+	return media, nil
 }
 
 // returns a slice of Chirps in db sorted by ID
@@ -84,6 +166,10 @@ func (db *DB) GetChirps(order string) ([]Chirp, error) {
 
 	chirps := make([]Chirp, 0, len(dbS.Chirps))
 	for _, chirp := range dbS.Chirps {
+		// tombstones are only shown in threads
+		if chirp.DeletedAt != 0 {
+			continue
+		}
 		chirps = append(chirps, chirp)
 	}
 
@@ -100,6 +186,24 @@ func (db *DB) GetChirps(order string) ([]Chirp, error) {
 	return chirps, nil
 }
 
+// returns the chirp with the given id or ErrNotExist, it may be a tombstone
+func (db *DB) GetChirp(id int) (Chirp, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	dbS, err := db.loadDB()
+	if err != nil {
+		return Chirp{}, err
+	}
+
+	c, ok := dbS.Chirps[id]
+	if !ok {
+		return Chirp{}, ErrNotExist
+	}
+
+	return c, nil
+}
+
 func (db *DB) GetChirpsByAuthID(aID int, order string) ([]Chirp, error) {
 	db.mux.RLock()
 	defer db.mux.RUnlock()
@@ -154,6 +258,19 @@ func (db *DB) loadDB() (DBStructure, error) {
 		dbS.Chirps = make(map[int]Chirp)
 		dbS.Users = make(map[int]User)
 		dbS.Tokens = make(map[string]int64)
+		dbS.PasswordResets = make(map[string]PasswordReset)
+		dbS.AccessTokens = make(map[int]PersonalAccessToken)
+		dbS.OAuthClients = make(map[string]OAuthClient)
+		dbS.OAuthCodes = make(map[string]OAuthCode)
+		dbS.MagicLinks = make(map[string]MagicLink)
+		dbS.DeniedTokens = make(map[string]int64)
+		dbS.DataExports = make(map[string]DataExport)
+		dbS.Likes = make(map[string]Like)
+		dbS.Follows = make(map[string]Follow)
+		dbS.Inboxes = make(map[int][]int)
+		dbS.Notifications = make(map[int]Notification)
+		dbS.Media = make(map[string]Media)
+		dbS.HeldChirps = make(map[int]HeldChirp)
 	}
 
 	return dbS, nil
@@ -174,6 +291,19 @@ func (db *DB) writeDB(dbStructure DBStructure) error {
 	return nil
 }
 
+// returns the ID the next user gets. accounts are only ever tombstoned, but IDs that came from a
+// count would collide the moment one was removed from the map, so it's derived from the largest one
+func nextUserID(dbS *DBStructure) int {
+	id := 1
+	for k := range dbS.Users {
+		if k >= id {
+			id = k + 1
+		}
+	}
+
+	return id
+}
+
 // creates a new user and saves it to disk
 func (db *DB) CreateUser(body string) (User, error) {
 	db.mux.Lock()
@@ -184,7 +314,7 @@ func (db *DB) CreateUser(body string) (User, error) {
 		return User{}, err
 	}
 
-	id := len(dbS.Users) + 1
+	id := nextUserID(&dbS)
 
 	req := User{}
 	err = json.Unmarshal([]byte(body), &req)
@@ -192,7 +322,7 @@ func (db *DB) CreateUser(body string) (User, error) {
 		return User{}, errors.New("CreatUser: unmarshall error")
 	}
 
-	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	hash, err := db.hasher.Hash(req.Password)
 	if err != nil {
 		return User{}, err
 	}
@@ -200,8 +330,9 @@ func (db *DB) CreateUser(body string) (User, error) {
 	u := User{
 		ID:          id,
 		Email:       req.Email,
-		Password:    string(hash),
+		Password:    hash,
 		IsChirpyRed: false,
+		Role:        auth.RoleUser,
 	}
 
 	dbS.Users[id] = u
@@ -234,6 +365,43 @@ func (db *DB) GetUsers() ([]User, error) {
 	return users, nil
 }
 
+// returns the user with the given id or ErrNotExist
+func (db *DB) GetUser(id int) (User, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	dbS, err := db.loadDB()
+	if err != nil {
+		return User{}, err
+	}
+
+	u, ok := dbS.Users[id]
+	if !ok {
+		return User{}, ErrNotExist
+	}
+
+	return u, nil
+}
+
+// returns the user registered with email or ErrNotExist
+func (db *DB) GetUserByEmail(email string) (User, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	dbS, err := db.loadDB()
+	if err != nil {
+		return User{}, err
+	}
+
+	for _, u := range dbS.Users {
+		if u.Email == email {
+			return u, nil
+		}
+	}
+
+	return User{}, ErrNotExist
+}
+
 func (db *DB) UpdateUser(user *User, newPw bool) (User, error) {
 	db.mux.Lock()
 	defer db.mux.Unlock()
@@ -246,11 +414,16 @@ func (db *DB) UpdateUser(user *User, newPw bool) (User, error) {
 	}
 
 	if newPw {
-		hash, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
+		hash, err := db.hasher.Hash(user.Password)
 		if err != nil {
 			return User{}, err
 		}
-		user.Password = string(hash)
+		user.Password = hash
+	}
+
+	// the generation is only ever bumped through BumpTokenGeneration, a stale copy of the user mustn't undo it
+	if stored, ok := dbS.Users[id]; ok && stored.TokenGeneration > user.TokenGeneration {
+		user.TokenGeneration = stored.TokenGeneration
 	}
 
 	dbS.Users[id] = *user
@@ -294,6 +467,45 @@ func (db *DB) WriteRefreshToken(jwtString string, time int64) (string, error) {
 	return jwtString, nil
 }
 
+// revokes the RJWT old at now and stores its replacement new in one go, so the same RJWT can't be
+// rotated twice by concurrent requests. returns ErrTokenRevoked if old isn't a valid stored RJWT
+func (db *DB) RotateRefreshToken(old, new string, now int64) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	dbS, err := db.loadDB()
+	if err != nil {
+		return err
+	}
+
+	revokedAt, ok := dbS.Tokens[old]
+	if !ok || revokedAt != 0 {
+		return ErrTokenRevoked
+	}
+
+	dbS.Tokens[old] = now
+	dbS.Tokens[new] = 0
+	err = db.writeDB(dbS)
+	if err != nil {
+		return errors.New("couldn't write to db")
+	}
+
+	return nil
+}
+
+// returns every stored RJWT mapped to the time it was revoked at, 0 if it's still valid
+func (db *DB) GetRefreshTokens() (map[string]int64, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	dbS, err := db.loadDB()
+	if err != nil {
+		return nil, err
+	}
+
+	return dbS.Tokens, nil
+}
+
 /*
 func (db *DB) WriteAccessToken(jwtString string) (string, error) {
 	db.mux.Lock()
@@ -0,0 +1,109 @@
+package database
+
+import "errors"
+
+// stores a new data export
+func (db *DB) CreateDataExport(e DataExport) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	dbS, err := db.loadDB()
+	if err != nil {
+		return err
+	}
+
+	if dbS.DataExports == nil {
+		dbS.DataExports = make(map[string]DataExport)
+	}
+
+	dbS.DataExports[e.ID] = e
+
+	err = db.writeDB(dbS)
+	if err != nil {
+		return errors.New("couldn't write to db")
+	}
+
+	return nil
+}
+
+// returns the user's data export with the given id or ErrNotExist
+func (db *DB) GetDataExport(uID int, id string) (DataExport, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	dbS, err := db.loadDB()
+	if err != nil {
+		return DataExport{}, err
+	}
+
+	e, ok := dbS.DataExports[id]
+	if !ok || e.UserID != uID {
+		return DataExport{}, ErrNotExist
+	}
+
+	return e, nil
+}
+
+// returns every data export of the user
+func (db *DB) GetDataExports(uID int) ([]DataExport, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	dbS, err := db.loadDB()
+	if err != nil {
+		return nil, err
+	}
+
+	exports := make([]DataExport, 0)
+	for _, e := range dbS.DataExports {
+		if e.UserID == uID {
+			exports = append(exports, e)
+		}
+	}
+
+	return exports, nil
+}
+
+// replaces a stored data export, once it's done generating
+func (db *DB) UpdateDataExport(e DataExport) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	dbS, err := db.loadDB()
+	if err != nil {
+		return err
+	}
+
+	if _, ok := dbS.DataExports[e.ID]; !ok {
+		return ErrNotExist
+	}
+
+	dbS.DataExports[e.ID] = e
+
+	err = db.writeDB(dbS)
+	if err != nil {
+		return errors.New("couldn't write to db")
+	}
+
+	return nil
+}
+
+// removes a data export, the archive itself is left to the caller
+func (db *DB) DeleteDataExport(id string) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	dbS, err := db.loadDB()
+	if err != nil {
+		return err
+	}
+
+	delete(dbS.DataExports, id)
+
+	err = db.writeDB(dbS)
+	if err != nil {
+		return errors.New("couldn't write to db")
+	}
+
+	return nil
+}
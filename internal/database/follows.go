@@ -0,0 +1,128 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ErrSelfFollow is returned when a user tries to follow themselves
+var ErrSelfFollow = errors.New("users can't follow themselves")
+
+func followKey(followerID, followeeID int) string {
+	return fmt.Sprintf("%d:%d", followerID, followeeID)
+}
+
+// records the follower following the followee, following someone twice is a no-op. the returned bool
+// is true if the follow is new. returns ErrNotExist if there's no such followee
+func (db *DB) Follow(followerID, followeeID int) (Follow, bool, error) {
+	if followerID == followeeID {
+		return Follow{}, false, ErrSelfFollow
+	}
+
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	dbS, err := db.loadDB()
+	if err != nil {
+		return Follow{}, false, err
+	}
+
+	u, ok := dbS.Users[followeeID]
+	if !ok || u.DeletedAt != 0 {
+		return Follow{}, false, ErrNotExist
+	}
+	if blocked(&dbS, followerID, followeeID) {
+		return Follow{}, false, ErrBlocked
+	}
+
+	if dbS.Follows == nil {
+		dbS.Follows = make(map[string]Follow)
+	}
+
+	key := followKey(followerID, followeeID)
+	if f, ok := dbS.Follows[key]; ok {
+		return f, false, nil
+	}
+
+	f := Follow{
+		FollowerID: followerID,
+		FolloweeID: followeeID,
+		FollowedAt: time.Now().Unix(),
+	}
+	dbS.Follows[key] = f
+	backfillInbox(&dbS, followerID, followeeID)
+	notify(&dbS, Notification{UserID: followeeID, Type: NotificationFollow, ActorID: followerID})
+
+	err = db.writeDB(dbS)
+	if err != nil {
+		return Follow{}, false, errors.New("couldn't write to db")
+	}
+
+	return f, true, nil
+}
+
+// removes the follower's follow of the followee, unfollowing someone who isn't followed is a no-op.
+// the followee's chirps are dropped from the follower's home timeline when it's read
+func (db *DB) Unfollow(followerID, followeeID int) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	dbS, err := db.loadDB()
+	if err != nil {
+		return err
+	}
+
+	key := followKey(followerID, followeeID)
+	if _, ok := dbS.Follows[key]; !ok {
+		return nil
+	}
+	delete(dbS.Follows, key)
+	unnotify(&dbS, func(n Notification) bool {
+		return n.Type == NotificationFollow && n.ActorID == followerID && n.UserID == followeeID
+	})
+
+	err = db.writeDB(dbS)
+	if err != nil {
+		return errors.New("couldn't write to db")
+	}
+
+	return nil
+}
+
+// returns the follows of the user, most recent first
+func (db *DB) GetFollowers(uID int) ([]Follow, error) {
+	return db.getFollows(func(f Follow) bool { return f.FolloweeID == uID })
+}
+
+// returns the follows by the user, most recent first
+func (db *DB) GetFollowing(uID int) ([]Follow, error) {
+	return db.getFollows(func(f Follow) bool { return f.FollowerID == uID })
+}
+
+func (db *DB) getFollows(match func(Follow) bool) ([]Follow, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	dbS, err := db.loadDB()
+	if err != nil {
+		return nil, err
+	}
+
+	follows := make([]Follow, 0)
+	for _, f := range dbS.Follows {
+		if match(f) {
+			follows = append(follows, f)
+		}
+	}
+
+	sort.Slice(follows, func(i, j int) bool {
+		if follows[i].FollowedAt != follows[j].FollowedAt {
+			return follows[i].FollowedAt > follows[j].FollowedAt
+		}
+		return followKey(follows[i].FollowerID, follows[i].FolloweeID) > followKey(follows[j].FollowerID, follows[j].FolloweeID)
+	})
+
+	return follows, nil
+}
@@ -0,0 +1,77 @@
+package database
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/hatrnuhn/chirpy-webserver/internal/entities"
+)
+
+// users don't have usernames, so a mention names a user by the part of their email before the @.
+// it's only resolved if that's unambiguous
+func resolveMentions(dbS *DBStructure, authorID int, mentions []entities.Mention) {
+	if len(mentions) == 0 {
+		return
+	}
+
+	byName := make(map[string][]int)
+	for _, u := range dbS.Users {
+		// users who blocked the author or were blocked by them can't be mentioned, the mention is left as text
+		if u.DeletedAt != 0 || blocked(dbS, authorID, u.ID) {
+			continue
+		}
+		name, _, _ := strings.Cut(strings.ToLower(u.Email), "@")
+		byName[name] = append(byName[name], u.ID)
+	}
+
+	for i, m := range mentions {
+		if ids := byName[strings.ToLower(m.Username)]; len(ids) == 1 {
+			mentions[i].UserID = ids[0]
+		}
+	}
+}
+
+// returns the chirps tagged with the hashtag, newest first. tag is matched case-insensitively
+func (db *DB) GetChirpsByHashtag(tag string) ([]Chirp, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	dbS, err := db.loadDB()
+	if err != nil {
+		return nil, err
+	}
+
+	tag = strings.ToLower(tag)
+	chirps := make([]Chirp, 0)
+	for _, c := range dbS.Chirps {
+		if c.DeletedAt == 0 && c.Entities != nil && c.Entities.Tags()[tag] {
+			chirps = append(chirps, c)
+		}
+	}
+
+	sort.Slice(chirps, func(i, j int) bool {
+		return chirps[i].ID > chirps[j].ID
+	})
+
+	return chirps, nil
+}
+
+// returns the chirps created at or after the unix time since, in no particular order
+func (db *DB) GetChirpsSince(since int64) ([]Chirp, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	dbS, err := db.loadDB()
+	if err != nil {
+		return nil, err
+	}
+
+	chirps := make([]Chirp, 0)
+	for _, c := range dbS.Chirps {
+		if c.DeletedAt == 0 && c.CreatedAt >= since {
+			chirps = append(chirps, c)
+		}
+	}
+
+	return chirps, nil
+}
@@ -0,0 +1,38 @@
+package database
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestMentionsDontExposeUserIDs(t *testing.T) {
+	db := newTestDB(t)
+	ids := createTestUsers(t, db, 2)
+
+	c := createTestChirp(t, db, ids[0], "hey @user2, look at this")
+
+	notes, err := db.GetNotifications(ids[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(notes) != 1 || notes[0].Type != NotificationMention || notes[0].ChirpID != c.ID {
+		t.Fatalf("got notifications %+v, want a mention in chirp %d", notes, c.ID)
+	}
+
+	dat, err := json.Marshal(c.Entities)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(dat), "user_id") {
+		t.Errorf("entities %s give away the mentioned user", dat)
+	}
+
+	stored, err := db.GetChirp(c.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored.Entities.Mentions) != 1 || stored.Entities.Mentions[0].UserID != 0 {
+		t.Errorf("got stored mentions %+v, want one without a user", stored.Entities.Mentions)
+	}
+}
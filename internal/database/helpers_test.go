@@ -0,0 +1,55 @@
+package database
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+// stores passwords as they are, bcrypt would only slow tests down
+type plainHasher struct{}
+
+func (plainHasher) Hash(password string) (string, error) {
+	return password, nil
+}
+
+// returns a database in a temporary directory
+func newTestDB(t *testing.T) *DB {
+	t.Helper()
+
+	db, err := NewDB(filepath.Join(t.TempDir(), "db.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetPasswordHasher(plainHasher{})
+
+	return db
+}
+
+// creates users with emails user1@example.com, user2@example.com and so on and returns their IDs
+func createTestUsers(t *testing.T, db *DB, n int) []int {
+	t.Helper()
+
+	ids := make([]int, 0, n)
+	for i := 0; i < n; i++ {
+		u, err := db.CreateUser(fmt.Sprintf(`{"email":"user%d@example.com","password":"pw"}`, i+1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, u.ID)
+	}
+
+	return ids
+}
+
+// creates a chirp by the user with body
+func createTestChirp(t *testing.T, db *DB, uID int, body string) Chirp {
+	t.Helper()
+
+	c, err := db.CreateChirp(Chirp{Body: body}, uID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return c
+}
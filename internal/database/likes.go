@@ -0,0 +1,164 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+func likeKey(cID, uID int) string {
+	return fmt.Sprintf("%d:%d", cID, uID)
+}
+
+// records the user liking the chirp and returns it with its updated like count, liking a chirp
+// twice is a no-op. the returned bool is true if the like is new. returns ErrNotExist if there's no such chirp
+func (db *DB) LikeChirp(cID, uID int) (Chirp, bool, error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	dbS, err := db.loadDB()
+	if err != nil {
+		return Chirp{}, false, err
+	}
+
+	c, ok := dbS.Chirps[cID]
+	if !ok || c.DeletedAt != 0 {
+		return Chirp{}, false, ErrNotExist
+	}
+	if blocked(&dbS, uID, c.UserID) {
+		return Chirp{}, false, ErrBlocked
+	}
+
+	if dbS.Likes == nil {
+		dbS.Likes = make(map[string]Like)
+	}
+
+	key := likeKey(cID, uID)
+	if _, ok := dbS.Likes[key]; ok {
+		return c, false, nil
+	}
+
+	dbS.Likes[key] = Like{
+		ChirpID: cID,
+		UserID:  uID,
+		LikedAt: time.Now().Unix(),
+	}
+	c.LikeCount++
+	dbS.Chirps[cID] = c
+	notify(&dbS, Notification{UserID: c.UserID, Type: NotificationLike, ActorID: uID, ChirpID: cID})
+
+	err = db.writeDB(dbS)
+	if err != nil {
+		return Chirp{}, false, errors.New("couldn't write to db")
+	}
+
+	return c, true, nil
+}
+
+// removes the user's like of the chirp and returns it with its updated like count, unliking a chirp
+// that isn't liked is a no-op. returns ErrNotExist if there's no such chirp
+func (db *DB) UnlikeChirp(cID, uID int) (Chirp, error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	dbS, err := db.loadDB()
+	if err != nil {
+		return Chirp{}, err
+	}
+
+	c, ok := dbS.Chirps[cID]
+	if !ok || c.DeletedAt != 0 {
+		return Chirp{}, ErrNotExist
+	}
+
+	key := likeKey(cID, uID)
+	if _, ok := dbS.Likes[key]; !ok {
+		return c, nil
+	}
+
+	removeLike(&dbS, key)
+
+	err = db.writeDB(dbS)
+	if err != nil {
+		return Chirp{}, errors.New("couldn't write to db")
+	}
+
+	return dbS.Chirps[cID], nil
+}
+
+// returns the likes of the chirp, most recent first. returns ErrNotExist if there's no such chirp
+func (db *DB) GetChirpLikes(cID int) ([]Like, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	dbS, err := db.loadDB()
+	if err != nil {
+		return nil, err
+	}
+
+	c, ok := dbS.Chirps[cID]
+	if !ok || c.DeletedAt != 0 {
+		return nil, ErrNotExist
+	}
+
+	likes := make([]Like, 0, c.LikeCount)
+	for _, l := range dbS.Likes {
+		if l.ChirpID == cID {
+			likes = append(likes, l)
+		}
+	}
+	sortLikes(likes)
+
+	return likes, nil
+}
+
+// returns the likes of the user, most recent first
+func (db *DB) GetUserLikes(uID int) ([]Like, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	dbS, err := db.loadDB()
+	if err != nil {
+		return nil, err
+	}
+
+	likes := make([]Like, 0)
+	for _, l := range dbS.Likes {
+		if l.UserID == uID {
+			likes = append(likes, l)
+		}
+	}
+	sortLikes(likes)
+
+	return likes, nil
+}
+
+func sortLikes(likes []Like) {
+	sort.Slice(likes, func(i, j int) bool {
+		if likes[i].LikedAt != likes[j].LikedAt {
+			return likes[i].LikedAt > likes[j].LikedAt
+		}
+		if likes[i].ChirpID != likes[j].ChirpID {
+			return likes[i].ChirpID > likes[j].ChirpID
+		}
+		return likes[i].UserID > likes[j].UserID
+	})
+}
+
+// removes the like stored under key from dbS and decrements the like count of its chirp
+func removeLike(dbS *DBStructure, key string) {
+	l, ok := dbS.Likes[key]
+	if !ok {
+		return
+	}
+	delete(dbS.Likes, key)
+	unnotify(dbS, func(n Notification) bool {
+		return n.Type == NotificationLike && n.ActorID == l.UserID && n.ChirpID == l.ChirpID
+	})
+
+	if c, ok := dbS.Chirps[l.ChirpID]; ok && c.LikeCount > 0 {
+		c.LikeCount--
+		dbS.Chirps[l.ChirpID] = c
+	}
+}
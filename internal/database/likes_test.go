@@ -0,0 +1,108 @@
+package database
+
+import "testing"
+
+func TestLikeCounts(t *testing.T) {
+	db := newTestDB(t)
+	ids := createTestUsers(t, db, 3)
+	c := createTestChirp(t, db, ids[0], "like me")
+
+	steps := []struct {
+		name    string
+		like    bool
+		uID     int
+		created bool
+		want    int
+	}{
+		{name: "first like", like: true, uID: ids[1], created: true, want: 1},
+		{name: "liking again", like: true, uID: ids[1], created: false, want: 1},
+		{name: "second liker", like: true, uID: ids[2], created: true, want: 2},
+		{name: "unlike", like: false, uID: ids[1], want: 1},
+		{name: "unliking again", like: false, uID: ids[1], want: 1},
+		{name: "unliking what was never liked", like: false, uID: ids[0], want: 1},
+	}
+
+	for _, s := range steps {
+		var got Chirp
+		var err error
+		if s.like {
+			var created bool
+			got, created, err = db.LikeChirp(c.ID, s.uID)
+			if err == nil && created != s.created {
+				t.Errorf("%s: got created %v, want %v", s.name, created, s.created)
+			}
+		} else {
+			got, err = db.UnlikeChirp(c.ID, s.uID)
+		}
+		if err != nil {
+			t.Fatalf("%s: %s", s.name, err)
+		}
+
+		if got.LikeCount != s.want {
+			t.Errorf("%s: got like count %d, want %d", s.name, got.LikeCount, s.want)
+		}
+		stored, err := db.GetChirp(c.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stored.LikeCount != s.want {
+			t.Errorf("%s: got stored like count %d, want %d", s.name, stored.LikeCount, s.want)
+		}
+	}
+
+	likes, err := db.GetChirpLikes(c.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(likes) != 1 || likes[0].UserID != ids[2] {
+		t.Errorf("got likes %+v, want only user %d's", likes, ids[2])
+	}
+
+	// the only like left notifies the author once
+	notes, err := db.GetNotifications(ids[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(notes) != 1 || notes[0].Type != NotificationLike || notes[0].ActorID != ids[2] {
+		t.Errorf("got notifications %+v, want user %d's like", notes, ids[2])
+	}
+}
+
+func TestLikeMissingChirp(t *testing.T) {
+	db := newTestDB(t)
+	ids := createTestUsers(t, db, 1)
+
+	_, _, err := db.LikeChirp(42, ids[0])
+	if err != ErrNotExist {
+		t.Errorf("liking got %v, want ErrNotExist", err)
+	}
+
+	_, err = db.UnlikeChirp(42, ids[0])
+	if err != ErrNotExist {
+		t.Errorf("unliking got %v, want ErrNotExist", err)
+	}
+}
+
+func TestDeleteUserDataUncountsLikes(t *testing.T) {
+	db := newTestDB(t)
+	ids := createTestUsers(t, db, 2)
+	c := createTestChirp(t, db, ids[0], "like me")
+
+	_, _, err := db.LikeChirp(c.ID, ids[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err = db.DeleteUserData(ids[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := db.GetChirp(c.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.LikeCount != 0 {
+		t.Errorf("got like count %d after the liker was deleted, want 0", stored.LikeCount)
+	}
+}
@@ -0,0 +1,76 @@
+package database
+
+import (
+	"errors"
+	"time"
+)
+
+// records a magic link emailed to the user, jti is the ID of its token
+func (db *DB) CreateMagicLink(jti string, uID int, expiresAt int64) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	dbS, err := db.loadDB()
+	if err != nil {
+		return err
+	}
+
+	if dbS.MagicLinks == nil {
+		dbS.MagicLinks = make(map[string]MagicLink)
+	}
+
+	// drops links nobody can use anymore
+	now := time.Now().Unix()
+	for id, ml := range dbS.MagicLinks {
+		if ml.ExpiresAt < now {
+			delete(dbS.MagicLinks, id)
+		}
+	}
+
+	dbS.MagicLinks[jti] = MagicLink{
+		UserID:    uID,
+		ExpiresAt: expiresAt,
+	}
+
+	err = db.writeDB(dbS)
+	if err != nil {
+		return errors.New("couldn't write to db")
+	}
+
+	return nil
+}
+
+// marks the magic link as used and returns the associated user id, a link can only be consumed once
+func (db *DB) ConsumeMagicLink(jti string) (int, error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	dbS, err := db.loadDB()
+	if err != nil {
+		return 0, err
+	}
+
+	ml, ok := dbS.MagicLinks[jti]
+	if !ok {
+		return 0, ErrNotExist
+	}
+
+	if ml.UsedAt != 0 {
+		return 0, errors.New("magic link is already used")
+	}
+
+	now := time.Now().Unix()
+	if ml.ExpiresAt < now {
+		return 0, errors.New("magic link is expired")
+	}
+
+	ml.UsedAt = now
+	dbS.MagicLinks[jti] = ml
+
+	err = db.writeDB(dbS)
+	if err != nil {
+		return 0, errors.New("couldn't write to db")
+	}
+
+	return ml.UserID, nil
+}
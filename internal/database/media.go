@@ -0,0 +1,130 @@
+package database
+
+import (
+	"errors"
+	"sort"
+)
+
+// MaxChirpMedia is how many media can be attached to a chirp
+const MaxChirpMedia = 4
+
+// ErrMediaUnusable is returned when media attached to a new chirp don't exist, aren't its author's,
+// are already attached to another chirp or are too many
+var ErrMediaUnusable = errors.New("media can't be attached")
+
+// stores newly uploaded media
+func (db *DB) CreateMedia(m Media) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	dbS, err := db.loadDB()
+	if err != nil {
+		return err
+	}
+
+	if dbS.Media == nil {
+		dbS.Media = make(map[string]Media)
+	}
+
+	dbS.Media[m.ID] = m
+
+	err = db.writeDB(dbS)
+	if err != nil {
+		return errors.New("couldn't write to db")
+	}
+
+	return nil
+}
+
+// returns the media with the given id or ErrNotExist
+func (db *DB) GetMedia(id string) (Media, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	dbS, err := db.loadDB()
+	if err != nil {
+		return Media{}, err
+	}
+
+	m, ok := dbS.Media[id]
+	if !ok {
+		return Media{}, ErrNotExist
+	}
+
+	return m, nil
+}
+
+// returns the media with the given ids that exist, keyed by ID
+func (db *DB) GetMediaByID(ids []string) (map[string]Media, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	dbS, err := db.loadDB()
+	if err != nil {
+		return nil, err
+	}
+
+	media := make(map[string]Media, len(ids))
+	for _, id := range ids {
+		if m, ok := dbS.Media[id]; ok {
+			media[id] = m
+		}
+	}
+
+	return media, nil
+}
+
+// returns the media uploaded by the user, oldest first
+func (db *DB) GetUserMedia(uID int) ([]Media, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	dbS, err := db.loadDB()
+	if err != nil {
+		return nil, err
+	}
+
+	media := make([]Media, 0)
+	for _, m := range dbS.Media {
+		if m.UserID == uID {
+			media = append(media, m)
+		}
+	}
+
+	sort.Slice(media, func(i, j int) bool {
+		if media[i].CreatedAt != media[j].CreatedAt {
+			return media[i].CreatedAt < media[j].CreatedAt
+		}
+		return media[i].ID < media[j].ID
+	})
+
+	return media, nil
+}
+
+// attaches the media with the given ids to the new chirp chp
+func attachMedia(dbS *DBStructure, chp *Chirp, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	if len(ids) > MaxChirpMedia || chp.RechirpOfID != 0 {
+		return ErrMediaUnusable
+	}
+
+	seen := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		m, ok := dbS.Media[id]
+		if !ok || m.UserID != chp.UserID || m.ChirpID != 0 || seen[id] {
+			return ErrMediaUnusable
+		}
+		seen[id] = true
+	}
+
+	for _, id := range ids {
+		m := dbS.Media[id]
+		m.ChirpID = chp.ID
+		dbS.Media[id] = m
+	}
+	chp.MediaIDs = ids
+
+	return nil
+}
@@ -0,0 +1,131 @@
+package database
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+// statuses of held chirps
+const (
+	HeldPending  = "pending"
+	HeldApproved = "approved"
+	HeldRejected = "rejected"
+)
+
+// ErrAlreadyReviewed is returned when a held chirp that was already approved or rejected is reviewed
+var ErrAlreadyReviewed = errors.New("held chirp was already reviewed")
+
+// ErrTargetGone is returned when a held chirp is approved after the chirp it replies to, rechirps or quotes
+// was deleted
+var ErrTargetGone = errors.New("the chirp it replies to, rechirps or quotes doesn't exist anymore")
+
+// stores c, created by the user, as held for review with the reason the moderation filter gave
+func (db *DB) CreateHeldChirp(c Chirp, uID int, filter, reason string) (HeldChirp, error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	dbS, err := db.loadDB()
+	if err != nil {
+		return HeldChirp{}, err
+	}
+
+	if dbS.HeldChirps == nil {
+		dbS.HeldChirps = make(map[int]HeldChirp)
+	}
+
+	id := 1
+	for hID := range dbS.HeldChirps {
+		if hID >= id {
+			id = hID + 1
+		}
+	}
+
+	h := HeldChirp{
+		ID:        id,
+		UserID:    uID,
+		Chirp:     c,
+		Reason:    reason,
+		Filter:    filter,
+		Status:    HeldPending,
+		CreatedAt: time.Now().Unix(),
+	}
+	dbS.HeldChirps[id] = h
+
+	err = db.writeDB(dbS)
+	if err != nil {
+		return HeldChirp{}, errors.New("couldn't write to db")
+	}
+
+	return h, nil
+}
+
+// returns the held chirps with the given status, oldest first so the queue is worked through in order
+func (db *DB) GetHeldChirps(status string) ([]HeldChirp, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	dbS, err := db.loadDB()
+	if err != nil {
+		return nil, err
+	}
+
+	held := make([]HeldChirp, 0)
+	for _, h := range dbS.HeldChirps {
+		if h.Status == status {
+			held = append(held, h)
+		}
+	}
+
+	sort.Slice(held, func(i, j int) bool {
+		return held[i].ID < held[j].ID
+	})
+
+	return held, nil
+}
+
+// approves or rejects the held chirp with the given id as the reviewer, an approved chirp is published
+// right away. returns ErrNotExist if there's no such held chirp and ErrAlreadyReviewed if it was reviewed
+// before. approving fails with CreateChirp's errors if the chirp can't be published anymore, with
+// ErrTargetGone in place of ErrNotExist, and it stays pending then
+func (db *DB) ReviewHeldChirp(id, reviewerID int, approve bool) (HeldChirp, error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	dbS, err := db.loadDB()
+	if err != nil {
+		return HeldChirp{}, err
+	}
+
+	h, ok := dbS.HeldChirps[id]
+	if !ok {
+		return HeldChirp{}, ErrNotExist
+	}
+	if h.Status != HeldPending {
+		return HeldChirp{}, ErrAlreadyReviewed
+	}
+
+	h.Status = HeldRejected
+	if approve {
+		chp, err := createChirp(&dbS, h.Chirp, h.UserID, db.fanOutLimit)
+		// the held chirp itself was found, so it's what it refers to that's missing
+		if errors.Is(err, ErrNotExist) {
+			return HeldChirp{}, ErrTargetGone
+		}
+		if err != nil {
+			return HeldChirp{}, err
+		}
+		h.Status = HeldApproved
+		h.ChirpID = chp.ID
+	}
+	h.ReviewerID = reviewerID
+	h.ReviewedAt = time.Now().Unix()
+	dbS.HeldChirps[id] = h
+
+	err = db.writeDB(dbS)
+	if err != nil {
+		return HeldChirp{}, errors.New("couldn't write to db")
+	}
+
+	return h, nil
+}
@@ -0,0 +1,201 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// MaxMutedKeywords is how many keywords a user can mute
+	MaxMutedKeywords = 100
+	// MaxMutedKeywordLength is how many characters a muted keyword can have
+	MaxMutedKeywordLength = 50
+)
+
+// ErrSelfMute is returned when a user tries to mute themselves
+var ErrSelfMute = errors.New("users can't mute themselves")
+
+func muteKey(muterID, mutedID int) string {
+	return fmt.Sprintf("%d:%d", muterID, mutedID)
+}
+
+// records the muter muting the muted user, muting someone twice is a no-op. the returned bool is true
+// if the mute is new. returns ErrNotExist if there's no such user to mute
+func (db *DB) Mute(muterID, mutedID int) (Mute, bool, error) {
+	if muterID == mutedID {
+		return Mute{}, false, ErrSelfMute
+	}
+
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	dbS, err := db.loadDB()
+	if err != nil {
+		return Mute{}, false, err
+	}
+
+	u, ok := dbS.Users[mutedID]
+	if !ok || u.DeletedAt != 0 {
+		return Mute{}, false, ErrNotExist
+	}
+
+	if dbS.Mutes == nil {
+		dbS.Mutes = make(map[string]Mute)
+	}
+
+	key := muteKey(muterID, mutedID)
+	if m, ok := dbS.Mutes[key]; ok {
+		return m, false, nil
+	}
+
+	m := Mute{
+		MuterID: muterID,
+		MutedID: mutedID,
+		MutedAt: time.Now().Unix(),
+	}
+	dbS.Mutes[key] = m
+
+	err = db.writeDB(dbS)
+	if err != nil {
+		return Mute{}, false, errors.New("couldn't write to db")
+	}
+
+	return m, true, nil
+}
+
+// removes the muter's mute of the muted user, unmuting someone who isn't muted is a no-op. nothing
+// was removed by muting them, so their chirps and notifications show up again
+func (db *DB) Unmute(muterID, mutedID int) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	dbS, err := db.loadDB()
+	if err != nil {
+		return err
+	}
+
+	key := muteKey(muterID, mutedID)
+	if _, ok := dbS.Mutes[key]; !ok {
+		return nil
+	}
+	delete(dbS.Mutes, key)
+
+	err = db.writeDB(dbS)
+	if err != nil {
+		return errors.New("couldn't write to db")
+	}
+
+	return nil
+}
+
+// returns the mutes by the user, most recent first
+func (db *DB) GetMutes(uID int) ([]Mute, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	dbS, err := db.loadDB()
+	if err != nil {
+		return nil, err
+	}
+
+	mutes := make([]Mute, 0)
+	for _, m := range dbS.Mutes {
+		if m.MuterID == uID {
+			mutes = append(mutes, m)
+		}
+	}
+
+	sort.Slice(mutes, func(i, j int) bool {
+		if mutes[i].MutedAt != mutes[j].MutedAt {
+			return mutes[i].MutedAt > mutes[j].MutedAt
+		}
+		return mutes[i].MutedID > mutes[j].MutedID
+	})
+
+	return mutes, nil
+}
+
+// returns the keywords the user muted, sorted
+func (db *DB) GetMutedKeywords(uID int) ([]string, error) {
+	u, err := db.GetUser(uID)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.MutedKeywords == nil {
+		return make([]string, 0), nil
+	}
+	return u.MutedKeywords, nil
+}
+
+// replaces the keywords the user muted with keywords, which are trimmed, lowercased and deduplicated.
+// returns the keywords as they're stored
+func (db *DB) UpdateMutedKeywords(uID int, keywords []string) ([]string, error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	dbS, err := db.loadDB()
+	if err != nil {
+		return nil, err
+	}
+
+	u, ok := dbS.Users[uID]
+	if !ok || u.DeletedAt != 0 {
+		return nil, ErrNotExist
+	}
+
+	seen := make(map[string]bool)
+	muted := make([]string, 0, len(keywords))
+	for _, kw := range keywords {
+		kw = strings.ToLower(strings.TrimSpace(kw))
+		if kw == "" || seen[kw] {
+			continue
+		}
+		seen[kw] = true
+		muted = append(muted, kw)
+	}
+	sort.Strings(muted)
+
+	u.MutedKeywords = muted
+	dbS.Users[uID] = u
+
+	err = db.writeDB(dbS)
+	if err != nil {
+		return nil, errors.New("couldn't write to db")
+	}
+
+	return muted, nil
+}
+
+// reports whether body contains the lowercased keyword as a whole word or phrase, so muting "cat"
+// hides "Cat!" and "#cat" but not "category"
+func containsKeyword(body, keyword string) bool {
+	body = strings.ToLower(body)
+	for i := 0; i < len(body); {
+		j := strings.Index(body[i:], keyword)
+		if j < 0 {
+			return false
+		}
+		start, end := i+j, i+j+len(keyword)
+
+		before, _ := utf8.DecodeLastRuneInString(body[:start])
+		after, _ := utf8.DecodeRuneInString(body[end:])
+		if (start == 0 || !isWordRune(before)) && (end == len(body) || !isWordRune(after)) {
+			return true
+		}
+
+		_, size := utf8.DecodeRuneInString(body[start:])
+		i = start + size
+	}
+
+	return false
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
@@ -0,0 +1,27 @@
+package database
+
+import "testing"
+
+func TestContainsKeyword(t *testing.T) {
+	cases := []struct {
+		body    string
+		keyword string
+		want    bool
+	}{
+		{body: "I love my Cat!", keyword: "cat", want: true},
+		{body: "#cat pics", keyword: "cat", want: true},
+		{body: "a category of its own", keyword: "cat", want: false},
+		{body: "concatenate, then cat", keyword: "cat", want: true},
+		{body: "spoilers for the finale", keyword: "the finale", want: true},
+		{body: "spoilers for the finales", keyword: "the finale", want: false},
+		{body: "ÜBER alles", keyword: "über", want: true},
+		{body: "cat_facts", keyword: "cat", want: false},
+	}
+
+	for _, cs := range cases {
+		got := containsKeyword(cs.body, cs.keyword)
+		if got != cs.want {
+			t.Errorf("containsKeyword(%q, %q) = %v, want %v", cs.body, cs.keyword, got, cs.want)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package database
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+// types of notifications
+const (
+	NotificationReply   = "reply"
+	NotificationMention = "mention"
+	NotificationQuote   = "quote"
+	NotificationRechirp = "rechirp"
+	NotificationLike    = "like"
+	NotificationFollow  = "follow"
+	NotificationReport  = "report"
+)
+
+var NotificationTypes = []string{
+	NotificationReply,
+	NotificationMention,
+	NotificationQuote,
+	NotificationRechirp,
+	NotificationLike,
+	NotificationFollow,
+	NotificationReport,
+}
+
+func ValidNotificationType(t string) bool {
+	for _, nt := range NotificationTypes {
+		if nt == t {
+			return true
+		}
+	}
+	return false
+}
+
+// records every notification the new chirp causes: replies, quotes and rechirps notify the author of the
+// chirp they reference, and mentions the mentioned users. nobody is notified twice about the same chirp
+func notifyChirp(dbS *DBStructure, chp Chirp) {
+	notified := make(map[int]bool)
+	add := func(uID int, t string, cID int) {
+		if notified[uID] {
+			return
+		}
+		notified[uID] = true
+		notify(dbS, Notification{UserID: uID, Type: t, ActorID: chp.UserID, ChirpID: cID})
+	}
+
+	if parent, ok := dbS.Chirps[chp.InReplyToID]; ok && chp.InReplyToID != 0 {
+		add(parent.UserID, NotificationReply, chp.ID)
+	}
+	if orig, ok := dbS.Chirps[chp.QuoteOfID]; ok && chp.QuoteOfID != 0 {
+		add(orig.UserID, NotificationQuote, chp.ID)
+	}
+	// the rechirp has no body of its own, so it's about the rechirped chirp
+	if orig, ok := dbS.Chirps[chp.RechirpOfID]; ok && chp.RechirpOfID != 0 {
+		add(orig.UserID, NotificationRechirp, orig.ID)
+	}
+
+	if chp.Entities != nil {
+		for _, m := range chp.Entities.Mentions {
+			if m.UserID != 0 {
+				add(m.UserID, NotificationMention, chp.ID)
+			}
+		}
+	}
+}
+
+// stores n, unless it would notify users of their own actions, deleted users, users who turned
+// notifications of its type off or users who blocked the actor or were blocked by them
+func notify(dbS *DBStructure, n Notification) {
+	if n.UserID == n.ActorID || blocked(dbS, n.UserID, n.ActorID) {
+		return
+	}
+
+	u, ok := dbS.Users[n.UserID]
+	if !ok || u.DeletedAt != 0 {
+		return
+	}
+	if enabled, ok := u.NotificationSettings[n.Type]; ok && !enabled {
+		return
+	}
+
+	if dbS.Notifications == nil {
+		dbS.Notifications = make(map[int]Notification)
+	}
+
+	id := 1
+	for nID := range dbS.Notifications {
+		if nID >= id {
+			id = nID + 1
+		}
+	}
+
+	n.ID = id
+	n.CreatedAt = time.Now().Unix()
+	dbS.Notifications[id] = n
+}
+
+// removes the notifications match picks out, for when what caused them is undone
+func unnotify(dbS *DBStructure, match func(Notification) bool) {
+	for id, n := range dbS.Notifications {
+		if match(n) {
+			delete(dbS.Notifications, id)
+		}
+	}
+}
+
+// returns the user's notifications, newest first, leaving out those about users they muted or chirps
+// containing keywords they muted
+func (db *DB) GetNotifications(uID int) ([]Notification, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	dbS, err := db.loadDB()
+	if err != nil {
+		return nil, err
+	}
+
+	f := newViewFilter(&dbS, uID, true)
+	notifications := make([]Notification, 0)
+	for _, n := range dbS.Notifications {
+		if n.UserID == uID && !f.hidesNotification(n) {
+			notifications = append(notifications, n)
+		}
+	}
+
+	sort.Slice(notifications, func(i, j int) bool {
+		return notifications[i].ID > notifications[j].ID
+	})
+
+	return notifications, nil
+}
+
+// marks the user's notifications with the given ids as read, or all of them if there are none.
+// ids of notifications that don't exist or aren't the user's are ignored
+func (db *DB) MarkNotificationsRead(uID int, ids []int) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	dbS, err := db.loadDB()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().Unix()
+	mark := func(id int) {
+		n, ok := dbS.Notifications[id]
+		if !ok || n.UserID != uID || n.ReadAt != 0 {
+			return
+		}
+		n.ReadAt = now
+		dbS.Notifications[id] = n
+	}
+
+	if len(ids) == 0 {
+		for id := range dbS.Notifications {
+			mark(id)
+		}
+	}
+	for _, id := range ids {
+		mark(id)
+	}
+
+	err = db.writeDB(dbS)
+	if err != nil {
+		return errors.New("couldn't write to db")
+	}
+
+	return nil
+}
+
+// returns whether each type of notification is on for the user
+func (db *DB) GetNotificationSettings(uID int) (map[string]bool, error) {
+	u, err := db.GetUser(uID)
+	if err != nil {
+		return nil, err
+	}
+
+	return notificationSettings(u), nil
+}
+
+// turns the types of notifications in settings on or off for the user, and returns whether each type
+// is on afterwards. types that aren't in settings are left as they are
+func (db *DB) UpdateNotificationSettings(uID int, settings map[string]bool) (map[string]bool, error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	dbS, err := db.loadDB()
+	if err != nil {
+		return nil, err
+	}
+
+	u, ok := dbS.Users[uID]
+	if !ok || u.DeletedAt != 0 {
+		return nil, ErrNotExist
+	}
+
+	if u.NotificationSettings == nil {
+		u.NotificationSettings = make(map[string]bool)
+	}
+	for t, enabled := range settings {
+		u.NotificationSettings[t] = enabled
+	}
+	dbS.Users[uID] = u
+
+	err = db.writeDB(dbS)
+	if err != nil {
+		return nil, errors.New("couldn't write to db")
+	}
+
+	return notificationSettings(u), nil
+}
+
+// every type of notification is on unless the user turned it off
+func notificationSettings(u User) map[string]bool {
+	settings := make(map[string]bool, len(NotificationTypes))
+	for _, t := range NotificationTypes {
+		enabled, ok := u.NotificationSettings[t]
+		settings[t] = !ok || enabled
+	}
+	return settings
+}
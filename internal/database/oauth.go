@@ -0,0 +1,168 @@
+package database
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+// stores a newly registered OAuth client
+func (db *DB) CreateOAuthClient(c OAuthClient) (OAuthClient, error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	dbS, err := db.loadDB()
+	if err != nil {
+		return OAuthClient{}, err
+	}
+
+	if dbS.OAuthClients == nil {
+		dbS.OAuthClients = make(map[string]OAuthClient)
+	}
+
+	if _, ok := dbS.OAuthClients[c.ID]; ok {
+		return OAuthClient{}, errors.New("client id is already taken")
+	}
+
+	dbS.OAuthClients[c.ID] = c
+	err = db.writeDB(dbS)
+	if err != nil {
+		return OAuthClient{}, errors.New("couldn't write to db")
+	}
+
+	return c, nil
+}
+
+// returns the OAuth client with the given id or ErrNotExist
+func (db *DB) GetOAuthClient(id string) (OAuthClient, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	dbS, err := db.loadDB()
+	if err != nil {
+		return OAuthClient{}, err
+	}
+
+	c, ok := dbS.OAuthClients[id]
+	if !ok {
+		return OAuthClient{}, ErrNotExist
+	}
+
+	return c, nil
+}
+
+// returns the OAuth clients registered by the user, oldest first
+func (db *DB) GetOAuthClients(ownerID int) ([]OAuthClient, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	dbS, err := db.loadDB()
+	if err != nil {
+		return nil, err
+	}
+
+	cs := make([]OAuthClient, 0)
+	for _, c := range dbS.OAuthClients {
+		if c.OwnerID == ownerID {
+			cs = append(cs, c)
+		}
+	}
+
+	sort.Slice(cs, func(i, j int) bool {
+		return cs[i].CreatedAt < cs[j].CreatedAt
+	})
+
+	return cs, nil
+}
+
+// deletes an OAuth client registered by the user, ErrNotExist if the user has no client with that id
+func (db *DB) DeleteOAuthClient(ownerID int, id string) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	dbS, err := db.loadDB()
+	if err != nil {
+		return err
+	}
+
+	c, ok := dbS.OAuthClients[id]
+	if !ok || c.OwnerID != ownerID {
+		return ErrNotExist
+	}
+
+	delete(dbS.OAuthClients, id)
+
+	err = db.writeDB(dbS)
+	if err != nil {
+		return errors.New("couldn't write to db")
+	}
+
+	return nil
+}
+
+// stores an authorization code, codeHash is the hash of the code handed to the client
+func (db *DB) CreateOAuthCode(codeHash string, code OAuthCode) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	dbS, err := db.loadDB()
+	if err != nil {
+		return err
+	}
+
+	if dbS.OAuthCodes == nil {
+		dbS.OAuthCodes = make(map[string]OAuthCode)
+	}
+
+	// drops codes nobody can exchange anymore
+	now := time.Now().Unix()
+	for h, c := range dbS.OAuthCodes {
+		if c.ExpiresAt < now {
+			delete(dbS.OAuthCodes, h)
+		}
+	}
+
+	dbS.OAuthCodes[codeHash] = code
+
+	err = db.writeDB(dbS)
+	if err != nil {
+		return errors.New("couldn't write to db")
+	}
+
+	return nil
+}
+
+// marks the authorization code as used and returns it, a code can only be consumed once
+func (db *DB) ConsumeOAuthCode(codeHash string) (OAuthCode, error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	dbS, err := db.loadDB()
+	if err != nil {
+		return OAuthCode{}, err
+	}
+
+	c, ok := dbS.OAuthCodes[codeHash]
+	if !ok {
+		return OAuthCode{}, ErrNotExist
+	}
+
+	if c.UsedAt != 0 {
+		return OAuthCode{}, errors.New("authorization code is already used")
+	}
+
+	now := time.Now().Unix()
+	if c.ExpiresAt < now {
+		return OAuthCode{}, errors.New("authorization code is expired")
+	}
+
+	c.UsedAt = now
+	dbS.OAuthCodes[codeHash] = c
+
+	err = db.writeDB(dbS)
+	if err != nil {
+		return OAuthCode{}, errors.New("couldn't write to db")
+	}
+
+	return c, nil
+}
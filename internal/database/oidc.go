@@ -0,0 +1,89 @@
+package database
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/hatrnuhn/chirpy-webserver/internal/auth"
+)
+
+// returned when the email of an external identity belongs to a user already linked to another one
+var ErrIdentityConflict = errors.New("email is linked to another identity")
+
+// returns the user linked to the external identity of issuer and subject. a user with a matching email
+// is linked to it if there's none, and a new one with password is created if there's no such user either.
+// the bool reports whether the user was created. callers must only pass emails the provider verified,
+// it records the sign in as the user's OIDCAuthAt
+func (db *DB) LinkOIDCUser(issuer, subject, email, password string) (User, bool, error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	dbS, err := db.loadDB()
+	if err != nil {
+		return User{}, false, err
+	}
+
+	now := time.Now().Unix()
+	for id, u := range dbS.Users {
+		if u.OIDCIssuer != issuer || u.OIDCSubject != subject {
+			continue
+		}
+
+		u.OIDCAuthAt = now
+		dbS.Users[id] = u
+
+		err = db.writeDB(dbS)
+		if err != nil {
+			return User{}, false, errors.New("couldn't write to db")
+		}
+
+		return u, false, nil
+	}
+
+	for id, u := range dbS.Users {
+		if !strings.EqualFold(u.Email, email) {
+			continue
+		}
+
+		if u.OIDCSubject != "" {
+			return User{}, false, ErrIdentityConflict
+		}
+
+		u.OIDCIssuer = issuer
+		u.OIDCSubject = subject
+		u.OIDCAuthAt = now
+		dbS.Users[id] = u
+
+		err = db.writeDB(dbS)
+		if err != nil {
+			return User{}, false, errors.New("couldn't write to db")
+		}
+
+		return u, false, nil
+	}
+
+	hash, err := db.hasher.Hash(password)
+	if err != nil {
+		return User{}, false, err
+	}
+
+	id := nextUserID(&dbS)
+	u := User{
+		ID:          id,
+		Email:       email,
+		Password:    hash,
+		Role:        auth.RoleUser,
+		OIDCIssuer:  issuer,
+		OIDCSubject: subject,
+		OIDCAuthAt:  now,
+	}
+
+	dbS.Users[id] = u
+	err = db.writeDB(dbS)
+	if err != nil {
+		return User{}, false, errors.New("couldn't write to db")
+	}
+
+	return u, true, nil
+}
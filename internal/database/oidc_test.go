@@ -0,0 +1,54 @@
+package database
+
+import "testing"
+
+func TestLinkOIDCUserIDs(t *testing.T) {
+	db := newTestDB(t)
+	createTestUsers(t, db, 3)
+
+	// a gap in the IDs must not make the next user take the highest one
+	dbS, err := db.loadDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	delete(dbS.Users, 1)
+	err = db.writeDB(dbS)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u, created, err := db.LinkOIDCUser("https://idp.example", "sub-1", "oidc@example.com", "pw")
+	if err != nil || !created {
+		t.Fatalf("got %+v, %v, %v", u, created, err)
+	}
+	if u.ID != 4 {
+		t.Fatalf("got ID %d, want 4", u.ID)
+	}
+
+	third, err := db.GetUser(3)
+	if err != nil || third.Email != "user3@example.com" {
+		t.Fatalf("user 3 was overwritten: %+v, %v", third, err)
+	}
+
+	// the identity is found again rather than creating another user
+	again, created, err := db.LinkOIDCUser("https://idp.example", "sub-1", "oidc@example.com", "pw")
+	if err != nil || created || again.ID != u.ID {
+		t.Fatalf("got %+v, %v, %v", again, created, err)
+	}
+
+	// as is a user with the email
+	linked, created, err := db.LinkOIDCUser("https://idp.example", "sub-2", "user2@example.com", "pw")
+	if err != nil || created || linked.ID != 2 || linked.OIDCSubject != "sub-2" {
+		t.Fatalf("got %+v, %v, %v", linked, created, err)
+	}
+
+	_, _, err = db.LinkOIDCUser("https://idp.example", "sub-3", "user2@example.com", "pw")
+	if err != ErrIdentityConflict {
+		t.Fatalf("got %v, want %s", err, ErrIdentityConflict)
+	}
+
+	next, err := db.CreateUser(`{"email":"user5@example.com","password":"pw"}`)
+	if err != nil || next.ID != 5 {
+		t.Fatalf("got %+v, %v, want ID 5", next, err)
+	}
+}
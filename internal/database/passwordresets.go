@@ -0,0 +1,94 @@
+package database
+
+import (
+	"errors"
+	"time"
+)
+
+// stores a password reset for the user, tokenHash is the hash of the token sent to the user
+func (db *DB) CreatePasswordReset(tokenHash string, uID int, expiresAt int64) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	dbS, err := db.loadDB()
+	if err != nil {
+		return err
+	}
+
+	if dbS.PasswordResets == nil {
+		dbS.PasswordResets = make(map[string]PasswordReset)
+	}
+
+	// drops resets nobody can redeem anymore
+	now := time.Now().Unix()
+	for h, pr := range dbS.PasswordResets {
+		if pr.ExpiresAt < now {
+			delete(dbS.PasswordResets, h)
+		}
+	}
+
+	dbS.PasswordResets[tokenHash] = PasswordReset{
+		UserID:    uID,
+		ExpiresAt: expiresAt,
+	}
+
+	err = db.writeDB(dbS)
+	if err != nil {
+		return errors.New("couldn't write to db")
+	}
+
+	return nil
+}
+
+// returns the password reset stored for tokenHash or ErrNotExist
+func (db *DB) GetPasswordReset(tokenHash string) (PasswordReset, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	dbS, err := db.loadDB()
+	if err != nil {
+		return PasswordReset{}, err
+	}
+
+	pr, ok := dbS.PasswordResets[tokenHash]
+	if !ok {
+		return PasswordReset{}, ErrNotExist
+	}
+
+	return pr, nil
+}
+
+// marks the password reset as used and returns the associated user id, a reset can only be consumed once
+func (db *DB) ConsumePasswordReset(tokenHash string) (int, error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	dbS, err := db.loadDB()
+	if err != nil {
+		return 0, err
+	}
+
+	pr, ok := dbS.PasswordResets[tokenHash]
+	if !ok {
+		return 0, ErrNotExist
+	}
+
+	if pr.UsedAt != 0 {
+		return 0, errors.New("reset token is already used")
+	}
+
+	now := time.Now().Unix()
+	if pr.ExpiresAt < now {
+		return 0, errors.New("reset token is expired")
+	}
+
+	pr.UsedAt = now
+	dbS.PasswordResets[tokenHash] = pr
+
+	err = db.writeDB(dbS)
+	if err != nil {
+		return 0, errors.New("couldn't write to db")
+	}
+
+	return pr.UserID, nil
+}
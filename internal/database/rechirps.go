@@ -0,0 +1,102 @@
+package database
+
+import "errors"
+
+// ErrAlreadyRechirped is returned when a user rechirps a chirp they've already rechirped
+var ErrAlreadyRechirped = errors.New("chirp is already rechirped")
+
+// returns the chirp with the given id for a reply, rechirp or quote to reference. a rechirp stands
+// for the chirp it re-shares, so that one is returned instead. tombstones can't be referenced
+func sharedChirp(dbS *DBStructure, id int) (Chirp, bool) {
+	c, ok := dbS.Chirps[id]
+	if ok && c.RechirpOfID != 0 {
+		c, ok = dbS.Chirps[c.RechirpOfID]
+	}
+	if !ok || c.DeletedAt != 0 {
+		return Chirp{}, false
+	}
+
+	return c, true
+}
+
+// makes chp the rechirp or quote of the chirp req asks for, if any, and counts it on that chirp
+func shareChirp(dbS *DBStructure, chp *Chirp, req Chirp) error {
+	switch {
+	case req.RechirpOfID != 0:
+		orig, ok := sharedChirp(dbS, req.RechirpOfID)
+		if !ok {
+			return ErrNotExist
+		}
+		if blocked(dbS, chp.UserID, orig.UserID) {
+			return ErrBlocked
+		}
+
+		for _, c := range dbS.Chirps {
+			if c.RechirpOfID == orig.ID && c.UserID == chp.UserID {
+				return ErrAlreadyRechirped
+			}
+		}
+
+		chp.Body = ""
+		chp.RechirpOfID = orig.ID
+		orig.RechirpCount++
+		dbS.Chirps[orig.ID] = orig
+	case req.QuoteOfID != 0:
+		orig, ok := sharedChirp(dbS, req.QuoteOfID)
+		if !ok {
+			return ErrNotExist
+		}
+		if blocked(dbS, chp.UserID, orig.UserID) {
+			return ErrBlocked
+		}
+
+		chp.QuoteOfID = orig.ID
+		orig.QuoteCount++
+		dbS.Chirps[orig.ID] = orig
+	}
+
+	return nil
+}
+
+// undoes shareChirp for c, which is being removed. its own rechirps are removed along with it like any
+// other chirp, while quotes keep their body and only lose the quoted chirp
+func unshareChirp(dbS *DBStructure, c Chirp) {
+	if orig, ok := dbS.Chirps[c.RechirpOfID]; ok && c.RechirpOfID != 0 && orig.RechirpCount > 0 {
+		orig.RechirpCount--
+		dbS.Chirps[orig.ID] = orig
+	}
+
+	if orig, ok := dbS.Chirps[c.QuoteOfID]; ok && c.QuoteOfID != 0 && orig.QuoteCount > 0 {
+		orig.QuoteCount--
+		dbS.Chirps[orig.ID] = orig
+	}
+
+	for id, rc := range dbS.Chirps {
+		if rc.RechirpOfID != c.ID {
+			continue
+		}
+
+		// rechirps have no media or replies, so nothing else is left to clean up
+		removeChirp(dbS, id)
+	}
+}
+
+// returns the chirps with the given ids that exist, keyed by ID
+func (db *DB) GetChirpsByID(ids []int) (map[int]Chirp, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	dbS, err := db.loadDB()
+	if err != nil {
+		return nil, err
+	}
+
+	chirps := make(map[int]Chirp, len(ids))
+	for _, id := range ids {
+		if c, ok := dbS.Chirps[id]; ok {
+			chirps[id] = c
+		}
+	}
+
+	return chirps, nil
+}
@@ -0,0 +1,54 @@
+package database
+
+import "testing"
+
+func TestDeleteChirpRemovesRechirps(t *testing.T) {
+	db := newTestDB(t)
+	ids := createTestUsers(t, db, 3)
+	author, rechirper, follower := ids[0], ids[1], ids[2]
+
+	_, _, err := db.Follow(follower, rechirper)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	orig := createTestChirp(t, db, author, "worth sharing")
+	rc, err := db.CreateChirp(Chirp{RechirpOfID: orig.ID}, rechirper)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	timeline, err := db.GetHomeTimeline(follower)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(timeline) != 1 || timeline[0].ID != rc.ID {
+		t.Fatalf("got home timeline %+v, want the rechirp", timeline)
+	}
+
+	_, err = db.DeleteChirp(orig.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dbS, err := db.loadDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := dbS.Chirps[rc.ID]; ok {
+		t.Error("rechirp wasn't removed along with the original")
+	}
+	for uID, inbox := range dbS.Inboxes {
+		for _, id := range inbox {
+			if id == orig.ID || id == rc.ID {
+				t.Errorf("inbox of user %d still has chirp %d", uID, id)
+			}
+		}
+	}
+	for _, n := range dbS.Notifications {
+		if n.ChirpID == orig.ID || n.ChirpID == rc.ID {
+			t.Errorf("notification %+v is left behind", n)
+		}
+	}
+}
@@ -0,0 +1,380 @@
+package database
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+// reasons users can report a chirp or a user for
+const (
+	ReportSpam           = "spam"
+	ReportHarassment     = "harassment"
+	ReportHate           = "hate"
+	ReportViolence       = "violence"
+	ReportSelfHarm       = "self_harm"
+	ReportMisinformation = "misinformation"
+	ReportOther          = "other"
+)
+
+var ReportReasons = []string{
+	ReportSpam,
+	ReportHarassment,
+	ReportHate,
+	ReportViolence,
+	ReportSelfHarm,
+	ReportMisinformation,
+	ReportOther,
+}
+
+func ValidReportReason(reason string) bool {
+	for _, r := range ReportReasons {
+		if r == reason {
+			return true
+		}
+	}
+	return false
+}
+
+// statuses of reports
+const (
+	ReportOpen     = "open"
+	ReportClaimed  = "claimed"
+	ReportResolved = "resolved"
+)
+
+// actions moderators can resolve a report with
+const (
+	ReportDismiss     = "dismiss"
+	ReportDeleteChirp = "delete_chirp"
+	ReportSuspendUser = "suspend_user"
+)
+
+func ValidReportAction(action string) bool {
+	return action == ReportDismiss || action == ReportDeleteChirp || action == ReportSuspendUser
+}
+
+// ReportChirpRemoved is the resolution of chirp reports closed because the chirp was removed some other way
+const ReportChirpRemoved = "chirp_removed"
+
+var (
+	// ErrSelfReport is returned when users report themselves or their own chirps
+	ErrSelfReport = errors.New("users can't report themselves")
+	// ErrAlreadyReported is returned when a user reports what they have an unresolved report of already
+	ErrAlreadyReported = errors.New("already reported")
+	// ErrReportClaimed is returned when a report another moderator claimed is claimed or resolved
+	ErrReportClaimed = errors.New("report was claimed by another moderator")
+	// ErrReportResolved is returned when a report that was resolved already is claimed or resolved
+	ErrReportResolved = errors.New("report was resolved already")
+	// ErrInvalidReportAction is returned when a user report is resolved by deleting a chirp
+	ErrInvalidReportAction = errors.New("only chirp reports can be resolved by deleting the chirp")
+)
+
+// reports the chirp with the given id as the user, returns ErrNotExist if there's no such chirp
+func (db *DB) ReportChirp(reporterID, cID int, reason, details string) (Report, error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	dbS, err := db.loadDB()
+	if err != nil {
+		return Report{}, err
+	}
+
+	c, ok := dbS.Chirps[cID]
+	if !ok || c.DeletedAt != 0 {
+		return Report{}, ErrNotExist
+	}
+
+	rep, err := addReport(&dbS, Report{
+		ReporterID: reporterID,
+		ChirpID:    cID,
+		UserID:     c.UserID,
+		Reason:     reason,
+		Details:    details,
+	})
+	if err != nil {
+		return Report{}, err
+	}
+
+	err = db.writeDB(dbS)
+	if err != nil {
+		return Report{}, errors.New("couldn't write to db")
+	}
+
+	return rep, nil
+}
+
+// reports the user with the given id as the reporter, returns ErrNotExist if there's no such user
+func (db *DB) ReportUser(reporterID, uID int, reason, details string) (Report, error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	dbS, err := db.loadDB()
+	if err != nil {
+		return Report{}, err
+	}
+
+	u, ok := dbS.Users[uID]
+	if !ok || u.DeletedAt != 0 {
+		return Report{}, ErrNotExist
+	}
+
+	rep, err := addReport(&dbS, Report{
+		ReporterID: reporterID,
+		UserID:     uID,
+		Reason:     reason,
+		Details:    details,
+	})
+	if err != nil {
+		return Report{}, err
+	}
+
+	err = db.writeDB(dbS)
+	if err != nil {
+		return Report{}, errors.New("couldn't write to db")
+	}
+
+	return rep, nil
+}
+
+// adds rep to dbS as an open report, unless users report themselves or something they reported already
+func addReport(dbS *DBStructure, rep Report) (Report, error) {
+	if rep.ReporterID == rep.UserID {
+		return Report{}, ErrSelfReport
+	}
+
+	for _, r := range dbS.Reports {
+		if r.ReporterID == rep.ReporterID && r.ChirpID == rep.ChirpID && r.UserID == rep.UserID && r.Status != ReportResolved {
+			return Report{}, ErrAlreadyReported
+		}
+	}
+
+	if dbS.Reports == nil {
+		dbS.Reports = make(map[int]Report)
+	}
+
+	id := 1
+	for rID := range dbS.Reports {
+		if rID >= id {
+			id = rID + 1
+		}
+	}
+
+	rep.ID = id
+	rep.Status = ReportOpen
+	rep.CreatedAt = time.Now().Unix()
+	dbS.Reports[id] = rep
+
+	return rep, nil
+}
+
+// returns the report with the given id or ErrNotExist
+func (db *DB) GetReport(id int) (Report, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	dbS, err := db.loadDB()
+	if err != nil {
+		return Report{}, err
+	}
+
+	rep, ok := dbS.Reports[id]
+	if !ok {
+		return Report{}, ErrNotExist
+	}
+
+	return rep, nil
+}
+
+// returns the reports with the given status, oldest first so the queue is worked through in order
+func (db *DB) GetReports(status string) ([]Report, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	dbS, err := db.loadDB()
+	if err != nil {
+		return nil, err
+	}
+
+	reports := make([]Report, 0)
+	for _, rep := range dbS.Reports {
+		if rep.Status == status {
+			reports = append(reports, rep)
+		}
+	}
+
+	sort.Slice(reports, func(i, j int) bool {
+		return reports[i].ID < reports[j].ID
+	})
+
+	return reports, nil
+}
+
+// returns the reports filed by the user, oldest first
+func (db *DB) GetUserReports(reporterID int) ([]Report, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	dbS, err := db.loadDB()
+	if err != nil {
+		return nil, err
+	}
+
+	reports := make([]Report, 0)
+	for _, rep := range dbS.Reports {
+		if rep.ReporterID == reporterID {
+			reports = append(reports, rep)
+		}
+	}
+
+	sort.Slice(reports, func(i, j int) bool {
+		return reports[i].ID < reports[j].ID
+	})
+
+	return reports, nil
+}
+
+// claims the report with the given id for the moderator so others know it's being worked on,
+// claiming it again does nothing
+func (db *DB) ClaimReport(id, modID int) (Report, error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	dbS, err := db.loadDB()
+	if err != nil {
+		return Report{}, err
+	}
+
+	rep, err := claimableReport(dbS, id, modID)
+	if err != nil {
+		return Report{}, err
+	}
+	if rep.Status == ReportClaimed {
+		return rep, nil
+	}
+
+	rep.Status = ReportClaimed
+	rep.ClaimedBy = modID
+	rep.ClaimedAt = time.Now().Unix()
+	dbS.Reports[id] = rep
+
+	err = db.writeDB(dbS)
+	if err != nil {
+		return Report{}, errors.New("couldn't write to db")
+	}
+
+	return rep, nil
+}
+
+// resolves the report with the given id as the moderator by taking action: dismissing it, deleting the
+// reported chirp or suspending the reported user. the other unresolved reports the action settles are
+// resolved along with it, and every reporter is notified. returns the media of the deleted chirp, if any,
+// for the caller to remove from the blob store
+func (db *DB) ResolveReport(id, modID int, action string) (Report, []Media, error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	dbS, err := db.loadDB()
+	if err != nil {
+		return Report{}, nil, err
+	}
+
+	rep, err := claimableReport(dbS, id, modID)
+	if err != nil {
+		return Report{}, nil, err
+	}
+
+	// picks out the reports resolved along with rep
+	settles := func(r Report) bool { return r.ID == id }
+	remove := false
+	switch action {
+	case ReportDeleteChirp:
+		if rep.ChirpID == 0 {
+			return Report{}, nil, ErrInvalidReportAction
+		}
+		// chirp IDs are reused, the chirp there now may not be the one that was reported
+		c, ok := dbS.Chirps[rep.ChirpID]
+		remove = ok && c.DeletedAt == 0 && c.UserID == rep.UserID
+		settles = func(r Report) bool { return r.ChirpID == rep.ChirpID && r.UserID == rep.UserID }
+	case ReportSuspendUser:
+		u, ok := dbS.Users[rep.UserID]
+		if ok && u.DeletedAt == 0 && u.SuspendedAt == 0 {
+			u.SuspendedAt = time.Now().Unix()
+			dbS.Users[rep.UserID] = u
+			// logs the user out everywhere
+			revokeTokens(&dbS, rep.UserID)
+			deleteAccessTokens(&dbS, rep.UserID)
+		}
+		settles = func(r Report) bool { return r.UserID == rep.UserID }
+	}
+
+	now := time.Now().Unix()
+	for rID, r := range dbS.Reports {
+		if r.Status == ReportResolved || !settles(r) {
+			continue
+		}
+
+		if r.ClaimedBy == 0 {
+			r.ClaimedBy = modID
+			r.ClaimedAt = now
+		}
+		r.Status = ReportResolved
+		r.Resolution = action
+		r.ResolvedBy = modID
+		r.ResolvedAt = now
+		dbS.Reports[rID] = r
+
+		// moderators act on behalf of Chirpy rather than as themselves, so the notification has no actor
+		notify(&dbS, Notification{UserID: r.ReporterID, Type: NotificationReport, ReportID: rID, Resolution: action})
+
+		if rID == id {
+			rep = r
+		}
+	}
+
+	// after the reports are settled, so removing the chirp doesn't close them as removed
+	media := make([]Media, 0)
+	if remove {
+		media = removeChirp(&dbS, rep.ChirpID)
+	}
+
+	err = db.writeDB(dbS)
+	if err != nil {
+		return Report{}, nil, errors.New("couldn't write to db")
+	}
+
+	return rep, media, nil
+}
+
+// closes the unresolved reports of the chirp with the given id, which is being removed. reporters aren't
+// notified, only moderators act on reports
+func closeChirpReports(dbS *DBStructure, cID int) {
+	now := time.Now().Unix()
+	for rID, r := range dbS.Reports {
+		if r.ChirpID != cID || r.Status == ReportResolved {
+			continue
+		}
+
+		r.Status = ReportResolved
+		r.Resolution = ReportChirpRemoved
+		r.ResolvedAt = now
+		dbS.Reports[rID] = r
+	}
+}
+
+// returns the report with the given id if the moderator can claim or resolve it
+func claimableReport(dbS DBStructure, id, modID int) (Report, error) {
+	rep, ok := dbS.Reports[id]
+	if !ok {
+		return Report{}, ErrNotExist
+	}
+
+	switch {
+	case rep.Status == ReportResolved:
+		return Report{}, ErrReportResolved
+	case rep.Status == ReportClaimed && rep.ClaimedBy != modID:
+		return Report{}, ErrReportClaimed
+	}
+
+	return rep, nil
+}
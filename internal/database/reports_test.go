@@ -0,0 +1,203 @@
+package database
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestReportQueue(t *testing.T) {
+	db := newTestDB(t)
+	ids := createTestUsers(t, db, 4)
+	author, reporter, other, mod := ids[0], ids[1], ids[2], ids[3]
+	c := createTestChirp(t, db, author, "buy my crypto")
+
+	_, err := db.ReportChirp(author, c.ID, ReportSpam, "")
+	if !errors.Is(err, ErrSelfReport) {
+		t.Fatalf("self report: got %v, want %s", err, ErrSelfReport)
+	}
+
+	rep, err := db.ReportChirp(reporter, c.ID, ReportSpam, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Status != ReportOpen || rep.UserID != author {
+		t.Fatalf("got %+v", rep)
+	}
+
+	_, err = db.ReportChirp(reporter, c.ID, ReportSpam, "")
+	if !errors.Is(err, ErrAlreadyReported) {
+		t.Fatalf("second report: got %v, want %s", err, ErrAlreadyReported)
+	}
+
+	rep2, err := db.ReportChirp(other, c.ID, ReportHate, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rep, err = db.ClaimReport(rep.ID, mod)
+	if err != nil || rep.Status != ReportClaimed || rep.ClaimedBy != mod {
+		t.Fatalf("claim: got %+v, %v", rep, err)
+	}
+
+	_, err = db.ClaimReport(rep.ID, other)
+	if !errors.Is(err, ErrReportClaimed) {
+		t.Fatalf("claim by another moderator: got %v, want %s", err, ErrReportClaimed)
+	}
+	_, _, err = db.ResolveReport(rep.ID, other, ReportDismiss)
+	if !errors.Is(err, ErrReportClaimed) {
+		t.Fatalf("resolve by another moderator: got %v, want %s", err, ErrReportClaimed)
+	}
+
+	open, err := db.GetReports(ReportOpen)
+	if err != nil || len(open) != 1 || open[0].ID != rep2.ID {
+		t.Fatalf("open reports: got %+v, %v", open, err)
+	}
+
+	rep, _, err = db.ResolveReport(rep.ID, mod, ReportDeleteChirp)
+	if err != nil || rep.Status != ReportResolved || rep.Resolution != ReportDeleteChirp {
+		t.Fatalf("resolve: got %+v, %v", rep, err)
+	}
+
+	_, err = db.GetChirp(c.ID)
+	if !errors.Is(err, ErrNotExist) {
+		t.Fatalf("reported chirp: got %v, want it deleted", err)
+	}
+
+	// the other report of the chirp is settled by the same action
+	rep2, err = db.GetReport(rep2.ID)
+	if err != nil || rep2.Status != ReportResolved || rep2.Resolution != ReportDeleteChirp || rep2.ResolvedBy != mod {
+		t.Fatalf("other report: got %+v, %v", rep2, err)
+	}
+
+	_, _, err = db.ResolveReport(rep.ID, mod, ReportDismiss)
+	if !errors.Is(err, ErrReportResolved) {
+		t.Fatalf("resolving again: got %v, want %s", err, ErrReportResolved)
+	}
+
+	for _, uID := range []int{reporter, other} {
+		notifications, err := db.GetNotifications(uID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(notifications) != 1 || notifications[0].Type != NotificationReport || notifications[0].Resolution != ReportDeleteChirp {
+			t.Fatalf("reporter %d notifications: got %+v", uID, notifications)
+		}
+	}
+}
+
+func TestResolveReportReusedChirpID(t *testing.T) {
+	db := newTestDB(t)
+	ids := createTestUsers(t, db, 4)
+	author, reporter, bystander, mod := ids[0], ids[1], ids[2], ids[3]
+
+	c := createTestChirp(t, db, author, "buy my crypto")
+	rep, err := db.ReportChirp(reporter, c.ID, ReportSpam, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the reported chirp disappears from under the report without closing it, and its ID is taken by
+	// someone else's chirp, like it would be in a database written before reports were closed on removal
+	dbS, err := db.loadDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	delete(dbS.Chirps, c.ID)
+	err = db.writeDB(dbS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reused := createTestChirp(t, db, bystander, "just a nice chirp")
+	if reused.ID != c.ID {
+		t.Fatalf("got ID %d, want the reported chirp's %d reused", reused.ID, c.ID)
+	}
+
+	_, _, err = db.ResolveReport(rep.ID, mod, ReportDeleteChirp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.GetChirp(reused.ID)
+	if err != nil {
+		t.Fatalf("someone else's chirp was deleted: %v", err)
+	}
+}
+
+func TestRemovingChirpClosesReports(t *testing.T) {
+	db := newTestDB(t)
+	ids := createTestUsers(t, db, 2)
+	author, reporter := ids[0], ids[1]
+
+	c := createTestChirp(t, db, author, "buy my crypto")
+	rep, err := db.ReportChirp(reporter, c.ID, ReportSpam, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.DeleteChirp(c.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rep, err = db.GetReport(rep.ID)
+	if err != nil || rep.Status != ReportResolved || rep.Resolution != ReportChirpRemoved {
+		t.Fatalf("got %+v, %v", rep, err)
+	}
+
+	// the ID's next chirp starts with a clean slate
+	reused := createTestChirp(t, db, author, "a new chirp")
+	if reused.ID != c.ID {
+		t.Fatalf("got ID %d, want %d reused", reused.ID, c.ID)
+	}
+	open, err := db.GetReports(ReportOpen)
+	if err != nil || len(open) != 0 {
+		t.Fatalf("open reports: got %+v, %v", open, err)
+	}
+}
+
+func TestResolveReportSuspendUser(t *testing.T) {
+	db := newTestDB(t)
+	ids := createTestUsers(t, db, 3)
+	troll, reporter, mod := ids[0], ids[1], ids[2]
+
+	rep, err := db.ReportUser(reporter, troll, ReportHarassment, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	before, err := db.GetUser(troll)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.CreatePersonalAccessToken(PersonalAccessToken{UserID: troll, Name: "bot", Hash: "hash"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err = db.ResolveReport(rep.ID, mod, ReportDeleteChirp)
+	if !errors.Is(err, ErrInvalidReportAction) {
+		t.Fatalf("deleting a user report's chirp: got %v, want %s", err, ErrInvalidReportAction)
+	}
+
+	_, _, err = db.ResolveReport(rep.ID, mod, ReportSuspendUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	after, err := db.GetUser(troll)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.SuspendedAt == 0 || after.TokenGeneration != before.TokenGeneration+1 {
+		t.Fatalf("got %+v, want them suspended and logged out", after)
+	}
+
+	pats, err := db.GetPersonalAccessTokens(troll)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pats) != 0 {
+		t.Errorf("got personal access tokens %+v of the suspended user, want none", pats)
+	}
+}
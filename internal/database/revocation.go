@@ -0,0 +1,87 @@
+package database
+
+import (
+	"errors"
+	"time"
+)
+
+// bumps the user's token generation, which revokes every AJWT and RJWT issued to them so far, and returns the
+// new one. personal access tokens don't carry a generation, they're left alone
+func (db *DB) BumpTokenGeneration(uID int) (int, error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	dbS, err := db.loadDB()
+	if err != nil {
+		return 0, err
+	}
+
+	_, ok := dbS.Users[uID]
+	if !ok {
+		return 0, ErrNotExist
+	}
+
+	gen := revokeTokens(&dbS, uID)
+
+	err = db.writeDB(dbS)
+	if err != nil {
+		return 0, errors.New("couldn't write to db")
+	}
+
+	return gen, nil
+}
+
+// bumps the token generation of the user with the given id, who must be in dbS, and returns the new one
+func revokeTokens(dbS *DBStructure, uID int) int {
+	u := dbS.Users[uID]
+	u.TokenGeneration++
+	dbS.Users[uID] = u
+
+	return u.TokenGeneration
+}
+
+// denies the token with ID jti until it expires at expiresAt
+func (db *DB) DenyTokenID(jti string, expiresAt int64) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	dbS, err := db.loadDB()
+	if err != nil {
+		return err
+	}
+
+	if dbS.DeniedTokens == nil {
+		dbS.DeniedTokens = make(map[string]int64)
+	}
+
+	// expired tokens are rejected anyway
+	now := time.Now().Unix()
+	for id, exp := range dbS.DeniedTokens {
+		if exp < now {
+			delete(dbS.DeniedTokens, id)
+		}
+	}
+
+	dbS.DeniedTokens[jti] = expiresAt
+
+	err = db.writeDB(dbS)
+	if err != nil {
+		return errors.New("couldn't write to db")
+	}
+
+	return nil
+}
+
+// reports whether the token with ID jti is denied
+func (db *DB) TokenIDDenied(jti string) (bool, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	dbS, err := db.loadDB()
+	if err != nil {
+		return false, err
+	}
+
+	_, ok := dbS.DeniedTokens[jti]
+	return ok, nil
+}
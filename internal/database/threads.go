@@ -0,0 +1,111 @@
+package database
+
+import (
+	"sort"
+	"time"
+)
+
+// returns every chirp in the conversation the chirp with the given id belongs to, tombstones included,
+// sorted by ID. returns ErrNotExist if there's no such chirp
+func (db *DB) GetConversation(id int) ([]Chirp, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	dbS, err := db.loadDB()
+	if err != nil {
+		return nil, err
+	}
+
+	c, ok := dbS.Chirps[id]
+	if !ok {
+		return nil, ErrNotExist
+	}
+	convID := conversationID(c)
+
+	chirps := make([]Chirp, 0)
+	for _, chirp := range dbS.Chirps {
+		if conversationID(chirp) == convID {
+			chirps = append(chirps, chirp)
+		}
+	}
+
+	sort.Slice(chirps, func(i, j int) bool {
+		return chirps[i].ID < chirps[j].ID
+	})
+
+	return chirps, nil
+}
+
+// chirps stored before replies existed have no conversation ID, they each start their own
+func conversationID(c Chirp) int {
+	if c.ConversationID == 0 {
+		return c.ID
+	}
+
+	return c.ConversationID
+}
+
+// removes the chirp with the given id from dbS. a chirp that has replies is replaced by a tombstone so
+// they aren't orphaned, and tombstones left without replies are removed along with it. returns the
+// media that were attached to it, which are removed too. reports of it that are still open are closed
+// and it's taken out of the home timelines it's on
+func removeChirp(dbS *DBStructure, id int) []Media {
+	media := make([]Media, 0)
+	for id != 0 {
+		c, ok := dbS.Chirps[id]
+		if !ok {
+			return media
+		}
+
+		for _, mID := range c.MediaIDs {
+			if m, ok := dbS.Media[mID]; ok {
+				media = append(media, m)
+				delete(dbS.Media, mID)
+			}
+		}
+
+		for key, l := range dbS.Likes {
+			if l.ChirpID == id {
+				delete(dbS.Likes, key)
+			}
+		}
+		unshareChirp(dbS, c)
+		unnotify(dbS, func(n Notification) bool { return n.ChirpID == id })
+		closeChirpReports(dbS, id)
+		removeFromTimelines(dbS, c)
+
+		if hasReplies(dbS, id) {
+			if c.DeletedAt != 0 {
+				return media
+			}
+			dbS.Chirps[id] = Chirp{
+				ID:             c.ID,
+				InReplyToID:    c.InReplyToID,
+				ConversationID: c.ConversationID,
+				DeletedAt:      time.Now().Unix(),
+			}
+			return media
+		}
+
+		delete(dbS.Chirps, id)
+
+		// the parent may have been a tombstone kept only for this reply
+		parent, ok := dbS.Chirps[c.InReplyToID]
+		if !ok || parent.DeletedAt == 0 {
+			return media
+		}
+		id = parent.ID
+	}
+
+	return media
+}
+
+func hasReplies(dbS *DBStructure, id int) bool {
+	for _, c := range dbS.Chirps {
+		if c.InReplyToID == id {
+			return true
+		}
+	}
+
+	return false
+}
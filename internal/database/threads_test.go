@@ -0,0 +1,105 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDeleteChirpTombstones(t *testing.T) {
+	db := newTestDB(t)
+	ids := createTestUsers(t, db, 2)
+
+	root := createTestChirp(t, db, ids[0], "root")
+	reply, err := db.CreateChirp(Chirp{Body: "reply", InReplyToID: root.ID}, ids[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	nested, err := db.CreateChirp(Chirp{Body: "nested reply", InReplyToID: reply.ID}, ids[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err = db.LikeChirp(root.ID, ids[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stored := func() map[int]Chirp {
+		t.Helper()
+		dbS, err := db.loadDB()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return dbS.Chirps
+	}
+
+	// chirps with replies are only emptied out
+	for _, id := range []int{root.ID, reply.ID} {
+		_, err = db.DeleteChirp(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		c, ok := stored()[id]
+		if !ok {
+			t.Fatalf("chirp %d with a reply was removed instead of tombstoned", id)
+		}
+		if c.DeletedAt == 0 || c.Body != "" || c.UserID != 0 || c.LikeCount != 0 {
+			t.Errorf("got tombstone %+v, want one without body, author or likes", c)
+		}
+	}
+
+	dbS, err := db.loadDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dbS.Likes) != 0 {
+		t.Errorf("got likes %+v of the tombstone, want none", dbS.Likes)
+	}
+
+	// deleting a tombstone again leaves it as it is
+	before := stored()[root.ID]
+	_, err = db.DeleteChirp(root.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after := stored()[root.ID]; !reflect.DeepEqual(after, before) {
+		t.Errorf("got tombstone %+v after deleting it again, want %+v", after, before)
+	}
+
+	// the last reply takes the tombstones kept for it along
+	_, err = db.DeleteChirp(nested.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if chirps := stored(); len(chirps) != 0 {
+		t.Errorf("got chirps %+v, want the whole thread removed", chirps)
+	}
+}
+
+func TestDeleteChirpKeepsLiveParent(t *testing.T) {
+	db := newTestDB(t)
+	ids := createTestUsers(t, db, 1)
+
+	root := createTestChirp(t, db, ids[0], "root")
+	reply, err := db.CreateChirp(Chirp{Body: "reply", InReplyToID: root.ID}, ids[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.DeleteChirp(reply.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dbS, err := db.loadDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := dbS.Chirps[reply.ID]; ok {
+		t.Error("reply without replies of its own was tombstoned instead of removed")
+	}
+	if c, ok := dbS.Chirps[root.ID]; !ok || c.DeletedAt != 0 || c.Body != "root" {
+		t.Errorf("got parent %+v, want it untouched", c)
+	}
+}
@@ -0,0 +1,181 @@
+package database
+
+import "sort"
+
+const (
+	// DefaultFanOutLimit is how many followers an author can have before their chirps stop being fanned out
+	DefaultFanOutLimit = 5000
+	// inboxes only keep the IDs of this many of the most recent chirps
+	maxInboxSize = 800
+	// how many of a user's chirps are added to the inbox of someone who starts following them
+	backfillSize = 50
+)
+
+// writes chp to the inboxes of its author and their followers, unless the author has more than limit
+// followers. then it's indexed to be looked up when their followers' home timelines are read
+func fanOut(dbS *DBStructure, chp *Chirp, limit int) {
+	// databases without the index get it with their first new chirp, rather than scanning on every read
+	unfanned := unfannedChirps(dbS)
+
+	followers := make([]int, 0)
+	for _, f := range dbS.Follows {
+		if f.FolloweeID == chp.UserID {
+			followers = append(followers, f.FollowerID)
+		}
+	}
+
+	if len(followers) > limit {
+		unfanned[chp.UserID] = append(unfanned[chp.UserID], chp.ID)
+		return
+	}
+
+	if dbS.Inboxes == nil {
+		dbS.Inboxes = make(map[int][]int)
+	}
+
+	for _, uID := range append(followers, chp.UserID) {
+		addToInbox(dbS, uID, chp.ID)
+	}
+	chp.FannedOut = true
+}
+
+// adds the followee's most recent fanned out chirps to the follower's inbox, the rest of their chirps
+// are looked up when it's read anyway
+func backfillInbox(dbS *DBStructure, followerID, followeeID int) {
+	ids := make([]int, 0)
+	for _, c := range dbS.Chirps {
+		if c.UserID == followeeID && c.FannedOut && c.DeletedAt == 0 {
+			ids = append(ids, c.ID)
+		}
+	}
+
+	sort.Ints(ids)
+	if len(ids) > backfillSize {
+		ids = ids[len(ids)-backfillSize:]
+	}
+
+	if dbS.Inboxes == nil {
+		dbS.Inboxes = make(map[int][]int)
+	}
+
+	for _, id := range ids {
+		addToInbox(dbS, followerID, id)
+	}
+}
+
+// adds the chirp ID to the user's inbox, keeping it sorted and dropping the oldest once it's full
+func addToInbox(dbS *DBStructure, uID, cID int) {
+	inbox := dbS.Inboxes[uID]
+	i := sort.SearchInts(inbox, cID)
+	if i < len(inbox) && inbox[i] == cID {
+		return
+	}
+
+	inbox = append(inbox, 0)
+	copy(inbox[i+1:], inbox[i:])
+	inbox[i] = cID
+
+	if len(inbox) > maxInboxSize {
+		inbox = inbox[len(inbox)-maxInboxSize:]
+	}
+	dbS.Inboxes[uID] = inbox
+}
+
+// returns the IDs of each author's chirps that weren't fanned out, building the index from the chirps
+// if dbS doesn't have it yet
+func unfannedChirps(dbS *DBStructure) map[int][]int {
+	if dbS.Unfanned != nil {
+		return dbS.Unfanned
+	}
+
+	dbS.Unfanned = make(map[int][]int)
+	for _, c := range dbS.Chirps {
+		if !c.FannedOut && c.DeletedAt == 0 {
+			dbS.Unfanned[c.UserID] = append(dbS.Unfanned[c.UserID], c.ID)
+		}
+	}
+	for _, ids := range dbS.Unfanned {
+		sort.Ints(ids)
+	}
+
+	return dbS.Unfanned
+}
+
+// takes c out of every inbox it was added to, or out of its author's unfanned chirps
+func removeFromTimelines(dbS *DBStructure, c Chirp) {
+	if !c.FannedOut {
+		unfanned := unfannedChirps(dbS)
+		unfanned[c.UserID] = removeID(unfanned[c.UserID], c.ID)
+		if len(unfanned[c.UserID]) == 0 {
+			delete(unfanned, c.UserID)
+		}
+		return
+	}
+
+	for uID, inbox := range dbS.Inboxes {
+		dbS.Inboxes[uID] = removeID(inbox, c.ID)
+	}
+}
+
+// removes id from the sorted ids, if it's there
+func removeID(ids []int, id int) []int {
+	i := sort.SearchInts(ids, id)
+	if i == len(ids) || ids[i] != id {
+		return ids
+	}
+
+	return append(ids[:i], ids[i+1:]...)
+}
+
+// returns the user's home timeline, their own chirps and those of who they follow, newest first. it's
+// their inbox merged with the chirps of those authors that weren't fanned out, leaving out what their
+// blocks and mutes hide
+func (db *DB) GetHomeTimeline(uID int) ([]Chirp, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	dbS, err := db.loadDB()
+	if err != nil {
+		return nil, err
+	}
+
+	// the inbox may still hold chirps of users who've been unfollowed since
+	authors := map[int]bool{uID: true}
+	for _, f := range dbS.Follows {
+		if f.FollowerID == uID {
+			authors[f.FolloweeID] = true
+		}
+	}
+
+	f := newViewFilter(&dbS, uID, true)
+	seen := make(map[int]bool)
+	chirps := make([]Chirp, 0)
+	add := func(c Chirp) {
+		if seen[c.ID] || c.DeletedAt != 0 || !authors[c.UserID] || f.hides(c) {
+			return
+		}
+		seen[c.ID] = true
+		chirps = append(chirps, c)
+	}
+
+	for _, id := range dbS.Inboxes[uID] {
+		if c, ok := dbS.Chirps[id]; ok {
+			add(c)
+		}
+	}
+
+	unfanned := unfannedChirps(&dbS)
+	for author := range authors {
+		for _, id := range unfanned[author] {
+			if c, ok := dbS.Chirps[id]; ok {
+				add(c)
+			}
+		}
+	}
+
+	sort.Slice(chirps, func(i, j int) bool {
+		return chirps[i].ID > chirps[j].ID
+	})
+
+	return chirps, nil
+}
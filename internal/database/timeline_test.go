@@ -0,0 +1,182 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+)
+
+// returns the IDs of the user's home timeline chirps, newest first
+func homeTimelineIDs(t *testing.T, db *DB, uID int) []int {
+	t.Helper()
+
+	chirps, err := db.GetHomeTimeline(uID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids := make([]int, 0, len(chirps))
+	for _, c := range chirps {
+		ids = append(ids, c.ID)
+	}
+	return ids
+}
+
+func TestHomeTimelineFanOut(t *testing.T) {
+	db := newTestDB(t)
+	ids := createTestUsers(t, db, 3)
+	author, follower, stranger := ids[0], ids[1], ids[2]
+
+	_, _, err := db.Follow(follower, author)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c1 := createTestChirp(t, db, author, "first")
+	c2 := createTestChirp(t, db, author, "second")
+	own := createTestChirp(t, db, follower, "mine")
+	createTestChirp(t, db, stranger, "not followed")
+
+	if !c1.FannedOut {
+		t.Error("chirp of an author under the limit wasn't fanned out")
+	}
+
+	dbS, err := db.loadDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{c1.ID, c2.ID, own.ID}; !reflect.DeepEqual(dbS.Inboxes[follower], want) {
+		t.Errorf("got inbox %v, want %v", dbS.Inboxes[follower], want)
+	}
+
+	if got, want := homeTimelineIDs(t, db, follower), []int{own.ID, c2.ID, c1.ID}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got home timeline %v, want %v", got, want)
+	}
+
+	// the inbox keeps the unfollowed author's chirps, the timeline doesn't show them
+	err = db.Unfollow(follower, author)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := homeTimelineIDs(t, db, follower), []int{own.ID}; !reflect.DeepEqual(got, want) {
+		t.Errorf("after unfollowing got home timeline %v, want %v", got, want)
+	}
+}
+
+func TestHomeTimelineBackfill(t *testing.T) {
+	db := newTestDB(t)
+	ids := createTestUsers(t, db, 2)
+	author, follower := ids[0], ids[1]
+
+	chirps := make([]int, 0, backfillSize+5)
+	for i := 0; i < backfillSize+5; i++ {
+		chirps = append(chirps, createTestChirp(t, db, author, "chirp").ID)
+	}
+
+	_, err := db.DeleteChirp(chirps[len(chirps)-1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	chirps = chirps[:len(chirps)-1]
+
+	_, _, err = db.Follow(follower, author)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dbS, err := db.loadDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := chirps[len(chirps)-backfillSize:]; !reflect.DeepEqual(dbS.Inboxes[follower], want) {
+		t.Errorf("got inbox %v, want the author's last %d chirps %v", dbS.Inboxes[follower], backfillSize, want)
+	}
+}
+
+func TestHomeTimelineUnfanned(t *testing.T) {
+	db := newTestDB(t)
+	db.SetFanOutLimit(1)
+	ids := createTestUsers(t, db, 3)
+	author, follower := ids[0], ids[1]
+
+	for _, uID := range []int{follower, ids[2]} {
+		_, _, err := db.Follow(uID, author)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c1 := createTestChirp(t, db, author, "too popular")
+	c2 := createTestChirp(t, db, author, "for fan out")
+	if c1.FannedOut {
+		t.Error("chirp of an author over the limit was fanned out")
+	}
+
+	dbS, err := db.loadDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dbS.Inboxes[follower]) != 0 {
+		t.Errorf("got inbox %v, want it empty", dbS.Inboxes[follower])
+	}
+	if want := []int{c1.ID, c2.ID}; !reflect.DeepEqual(dbS.Unfanned[author], want) {
+		t.Errorf("got unfanned chirps %v, want %v", dbS.Unfanned[author], want)
+	}
+
+	if got, want := homeTimelineIDs(t, db, follower), []int{c2.ID, c1.ID}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got home timeline %v, want %v", got, want)
+	}
+
+	_, err = db.DeleteChirp(c1.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dbS, err = db.loadDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{c2.ID}; !reflect.DeepEqual(dbS.Unfanned[author], want) {
+		t.Errorf("after deleting got unfanned chirps %v, want %v", dbS.Unfanned[author], want)
+	}
+}
+
+func TestHomeTimelineUnindexed(t *testing.T) {
+	db := newTestDB(t)
+	ids := createTestUsers(t, db, 2)
+	author, follower := ids[0], ids[1]
+
+	_, _, err := db.Follow(follower, author)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a chirp stored before chirps were fanned out or indexed
+	dbS, err := db.loadDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbS.Chirps[1] = Chirp{ID: 1, Body: "from before", UserID: author, ConversationID: 1}
+	dbS.Unfanned = nil
+	err = db.writeDB(dbS)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := homeTimelineIDs(t, db, follower), []int{1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got home timeline %v, want %v", got, want)
+	}
+
+	// the index is built, and kept, once a chirp is written
+	c := createTestChirp(t, db, author, "from after")
+	if got, want := homeTimelineIDs(t, db, follower), []int{c.ID, 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got home timeline %v, want %v", got, want)
+	}
+
+	dbS, err = db.loadDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{1}; !reflect.DeepEqual(dbS.Unfanned[author], want) {
+		t.Errorf("got unfanned chirps %v, want %v", dbS.Unfanned[author], want)
+	}
+}
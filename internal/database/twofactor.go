@@ -0,0 +1,74 @@
+package database
+
+import "errors"
+
+// records step as the user's last accepted TOTP time step, returns false if a code
+// at or after it was already accepted so that a code can't be replayed
+func (db *DB) UseTOTPStep(uID int, step int64) (bool, error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	dbS, err := db.loadDB()
+	if err != nil {
+		return false, err
+	}
+
+	u, ok := dbS.Users[uID]
+	if !ok {
+		return false, ErrNotExist
+	}
+
+	if step <= u.TOTPLastStep {
+		return false, nil
+	}
+
+	u.TOTPLastStep = step
+	dbS.Users[uID] = u
+
+	err = db.writeDB(dbS)
+	if err != nil {
+		return false, errors.New("couldn't write to db")
+	}
+
+	return true, nil
+}
+
+// removes codeHash from the user's recovery codes, returns false if it isn't one of them
+func (db *DB) UseRecoveryCode(uID int, codeHash string) (bool, error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	dbS, err := db.loadDB()
+	if err != nil {
+		return false, err
+	}
+
+	u, ok := dbS.Users[uID]
+	if !ok {
+		return false, ErrNotExist
+	}
+
+	found := false
+	codes := make([]string, 0, len(u.RecoveryCodes))
+	for _, c := range u.RecoveryCodes {
+		if c == codeHash && !found {
+			found = true
+			continue
+		}
+		codes = append(codes, c)
+	}
+
+	if !found {
+		return false, nil
+	}
+
+	u.RecoveryCodes = codes
+	dbS.Users[uID] = u
+
+	err = db.writeDB(dbS)
+	if err != nil {
+		return false, errors.New("couldn't write to db")
+	}
+
+	return true, nil
+}
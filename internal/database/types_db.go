@@ -2,23 +2,187 @@ package database
 
 import (
 	"sync"
+
+	"github.com/hatrnuhn/chirpy-webserver/internal/entities"
 )
 
 type DB struct {
-	path string
-	mux  *sync.RWMutex
+	path   string
+	mux    *sync.RWMutex
+	hasher PasswordHasher
+	// authors with more followers than this aren't fanned out to their followers' inboxes
+	fanOutLimit int
+}
+
+// PasswordHasher hashes passwords before they're stored
+type PasswordHasher interface {
+	Hash(password string) (string, error)
 }
 
 type DBStructure struct {
 	Chirps map[int]Chirp    `json:"chirps"`
 	Users  map[int]User     `json:"users"`
 	Tokens map[string]int64 `json:"refresh_tokens"`
+	// keyed by the hash of the emailed reset token
+	PasswordResets map[string]PasswordReset    `json:"password_resets"`
+	AccessTokens   map[int]PersonalAccessToken `json:"personal_access_tokens"`
+	OAuthClients   map[string]OAuthClient      `json:"oauth_clients"`
+	// keyed by the hash of the authorization code
+	OAuthCodes map[string]OAuthCode `json:"oauth_codes"`
+	// keyed by the ID of the magic link token
+	MagicLinks map[string]MagicLink `json:"magic_links"`
+	// IDs of revoked tokens mapped to when the tokens expire, they're dropped after that
+	DeniedTokens map[string]int64      `json:"denied_token_ids"`
+	DataExports  map[string]DataExport `json:"data_exports"`
+	// keyed by likeKey of the chirp and the user who liked it
+	Likes map[string]Like `json:"likes"`
+	// keyed by followKey of the follower and the followed user
+	Follows map[string]Follow `json:"follows"`
+	// IDs of the chirps fanned out to each user's home timeline, oldest first
+	Inboxes map[int][]int `json:"timeline_inboxes"`
+	// IDs of each author's chirps that weren't fanned out, oldest first. databases written before it was
+	// kept don't have it, it's built from the chirps when it's first needed
+	Unfanned      map[int][]int        `json:"unfanned_chirps"`
+	Notifications map[int]Notification `json:"notifications"`
+	Media         map[string]Media     `json:"media"`
+	HeldChirps    map[int]HeldChirp    `json:"held_chirps"`
+	Reports       map[int]Report       `json:"reports"`
+	// keyed by blockKey of the blocker and the blocked user
+	Blocks map[string]Block `json:"blocks"`
+	// keyed by muteKey of the muter and the muted user
+	Mutes map[string]Mute `json:"mutes"`
 }
 
 type Chirp struct {
 	ID     int    `json:"id"`
 	Body   string `json:"body"`
 	UserID int    `json:"user_id"`
+	// unix time, chirps stored before it was recorded have none
+	CreatedAt int64 `json:"created_at,omitempty"`
+	// hashtags, mentions and URLs in Body, rechirps and chirps stored before they were parsed have none
+	Entities *entities.Entities `json:"entities,omitempty"`
+	// the chirp this one replies to, 0 if it starts a conversation
+	InReplyToID int `json:"in_reply_to_id,omitempty"`
+	// ID of the chirp that started the conversation, a chirp that starts one has its own ID
+	ConversationID int `json:"conversation_id"`
+	// set when a chirp with replies was deleted, only a tombstone without body or author is kept
+	DeletedAt int64 `json:"deleted_at,omitempty"`
+	// number of Likes of the chirp, kept up to date as it's liked and unliked
+	LikeCount int `json:"like_count"`
+	// the chirp this one re-shares as is, a rechirp has no body of its own
+	RechirpOfID int `json:"rechirp_of_id,omitempty"`
+	// the chirp this one quotes with its own body
+	QuoteOfID int `json:"quote_of_id,omitempty"`
+	// number of rechirps and quotes of the chirp, kept up to date like LikeCount
+	RechirpCount int `json:"rechirp_count"`
+	QuoteCount   int `json:"quote_count"`
+	// IDs of the Media attached to the chirp
+	MediaIDs []string `json:"media_ids,omitempty"`
+	// set if the chirp was written to its author's followers' inboxes, otherwise their home timelines
+	// look it up when they're read
+	FannedOut bool `json:"fanned_out,omitempty"`
+}
+
+// Notification tells a user that another did something involving them
+type Notification struct {
+	ID int `json:"id"`
+	// the notified user
+	UserID int `json:"user_id"`
+	// one of the Notification constants
+	Type string `json:"type"`
+	// the user who did it
+	ActorID int `json:"actor_id"`
+	// the reply, mention or quote itself, or the liked or rechirped chirp. 0 for follows and reports
+	ChirpID int `json:"chirp_id,omitempty"`
+	// the report the notified user filed and how it was resolved, only for report notifications
+	ReportID   int    `json:"report_id,omitempty"`
+	Resolution string `json:"resolution,omitempty"`
+	CreatedAt  int64  `json:"created_at"`
+	ReadAt     int64  `json:"read_at,omitempty"`
+}
+
+// Media is an image a user uploaded, it's stored in a blob store under Key and ThumbnailKey
+type Media struct {
+	ID            string `json:"id"`
+	UserID        int    `json:"user_id"`
+	ContentType   string `json:"content_type"`
+	ThumbnailType string `json:"thumbnail_type"`
+	Width         int    `json:"width"`
+	Height        int    `json:"height"`
+	Key           string `json:"key"`
+	ThumbnailKey  string `json:"thumbnail_key"`
+	CreatedAt     int64  `json:"created_at"`
+	// the chirp it's attached to, 0 until it's attached to one
+	ChirpID int `json:"chirp_id,omitempty"`
+}
+
+// HeldChirp is a chirp moderation kept from being published until a moderator reviews it
+type HeldChirp struct {
+	ID     int `json:"id"`
+	UserID int `json:"user_id"`
+	// the chirp as it'll be created if it's approved
+	Chirp Chirp `json:"chirp"`
+	// why and by which moderation filter it was held
+	Reason string `json:"reason"`
+	Filter string `json:"filter"`
+	// one of pending, approved or rejected
+	Status     string `json:"status"`
+	CreatedAt  int64  `json:"created_at"`
+	ReviewerID int    `json:"reviewer_id,omitempty"`
+	ReviewedAt int64  `json:"reviewed_at,omitempty"`
+	// the chirp that was published once it was approved
+	ChirpID int `json:"chirp_id,omitempty"`
+}
+
+// Report is a user flagging a chirp or another user for moderators to look at
+type Report struct {
+	ID         int `json:"id"`
+	ReporterID int `json:"reporter_id"`
+	// the reported chirp, 0 if a user was reported
+	ChirpID int `json:"chirp_id,omitempty"`
+	// the reported user, or the author of the reported chirp
+	UserID int `json:"user_id"`
+	// one of the Report reason constants
+	Reason  string `json:"reason"`
+	Details string `json:"details,omitempty"`
+	// one of open, claimed or resolved
+	Status    string `json:"status"`
+	CreatedAt int64  `json:"created_at"`
+	// the moderator working on it
+	ClaimedBy int   `json:"claimed_by,omitempty"`
+	ClaimedAt int64 `json:"claimed_at,omitempty"`
+	// one of the Report action constants, the action that settled it
+	Resolution string `json:"resolution,omitempty"`
+	ResolvedBy int    `json:"resolved_by,omitempty"`
+	ResolvedAt int64  `json:"resolved_at,omitempty"`
+}
+
+// Block is a user blocking another, neither sees or interacts with the other's chirps
+type Block struct {
+	BlockerID int   `json:"blocker_id"`
+	BlockedID int   `json:"blocked_id"`
+	BlockedAt int64 `json:"blocked_at"`
+}
+
+// Mute is a user hiding another's chirps from their own timelines and notifications
+type Mute struct {
+	MuterID int   `json:"muter_id"`
+	MutedID int   `json:"muted_id"`
+	MutedAt int64 `json:"muted_at"`
+}
+
+// Follow is a user following another
+type Follow struct {
+	FollowerID int   `json:"follower_id"`
+	FolloweeID int   `json:"followee_id"`
+	FollowedAt int64 `json:"followed_at"`
+}
+
+// Like is a user liking a chirp
+type Like struct {
+	ChirpID int   `json:"chirp_id"`
+	UserID  int   `json:"user_id"`
+	LikedAt int64 `json:"liked_at"`
 }
 
 type User struct {
@@ -26,4 +190,90 @@ type User struct {
 	Email       string `json:"email"`
 	Password    string `json:"password"`
 	IsChirpyRed bool   `json:"is_chirpy_red"`
+	// one of user, moderator or admin, users created before roles existed have none and are plain users
+	Role string `json:"role,omitempty"`
+	// base32 TOTP secret, set on enrollment and only enforced once TOTPEnabled
+	TOTPSecret  string `json:"totp_secret,omitempty"`
+	TOTPEnabled bool   `json:"totp_enabled"`
+	// last accepted TOTP time step, codes at or before it are rejected
+	TOTPLastStep int64 `json:"totp_last_step,omitempty"`
+	// hashes of the unused one-time recovery codes
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
+	// issuer and subject of the external OpenID Connect identity the user signs in with, if any
+	OIDCIssuer  string `json:"oidc_issuer,omitempty"`
+	OIDCSubject string `json:"oidc_subject,omitempty"`
+	// unix time the user last signed in through the OpenID Connect provider
+	OIDCAuthAt int64 `json:"oidc_auth_at,omitempty"`
+	// bumped to revoke every token issued to the user so far
+	TokenGeneration int `json:"token_generation,omitempty"`
+	// set when the user deleted their account, what's left of it is kept so its ID isn't reused
+	DeletedAt int64 `json:"deleted_at,omitempty"`
+	// set when a moderator suspended the user, suspended users can't get or use tokens
+	SuspendedAt int64 `json:"suspended_at,omitempty"`
+	// notification types the user turned on or off, types that aren't in it are on
+	NotificationSettings map[string]bool `json:"notification_settings,omitempty"`
+	// lowercased words and phrases hidden from the user's timelines and notifications
+	MutedKeywords []string `json:"muted_keywords,omitempty"`
+}
+
+type PasswordReset struct {
+	UserID    int   `json:"user_id"`
+	ExpiresAt int64 `json:"expires_at"`
+	UsedAt    int64 `json:"used_at"`
+}
+
+// MagicLink is a passwordless login link that was emailed to a user
+type MagicLink struct {
+	UserID    int   `json:"user_id"`
+	ExpiresAt int64 `json:"expires_at"`
+	UsedAt    int64 `json:"used_at"`
+}
+
+// DataExport is an archive of everything stored about a user, generated in the background
+type DataExport struct {
+	ID     string `json:"id"`
+	UserID int    `json:"user_id"`
+	// one of pending, ready or failed
+	Status      string `json:"status"`
+	CreatedAt   int64  `json:"created_at"`
+	CompletedAt int64  `json:"completed_at,omitempty"`
+	ExpiresAt   int64  `json:"expires_at,omitempty"`
+	// where the archive is stored once it's ready
+	Path string `json:"path,omitempty"`
+}
+
+type PersonalAccessToken struct {
+	ID     int      `json:"id"`
+	UserID int      `json:"user_id"`
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+	// hash of the token, the token itself is only shown to the user once
+	Hash      string `json:"hash"`
+	CreatedAt int64  `json:"created_at"`
+	// 0 if the token never expires
+	ExpiresAt int64 `json:"expires_at"`
+}
+
+// OAuthClient is a third-party app registered to act on behalf of users through OAuth 2.0
+type OAuthClient struct {
+	ID      string `json:"id"`
+	OwnerID int    `json:"owner_id"`
+	Name    string `json:"name"`
+	// hash of the client secret, empty for public clients
+	SecretHash   string   `json:"secret_hash"`
+	RedirectURIs []string `json:"redirect_uris"`
+	CreatedAt    int64    `json:"created_at"`
+}
+
+// OAuthCode is an authorization code waiting to be exchanged for tokens
+type OAuthCode struct {
+	ClientID string `json:"client_id"`
+	UserID   int    `json:"user_id"`
+	// the redirect uri the authorization request had, empty if it relied on the client's only registered one
+	RedirectURI string   `json:"redirect_uri"`
+	Scopes      []string `json:"scopes"`
+	// PKCE S256 challenge, empty if the client didn't send one
+	CodeChallenge string `json:"code_challenge"`
+	ExpiresAt     int64  `json:"expires_at"`
+	UsedAt        int64  `json:"used_at"`
 }
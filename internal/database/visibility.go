@@ -0,0 +1,101 @@
+package database
+
+// viewFilter picks out what a user shouldn't be shown: chirps of users they blocked or who blocked
+// them and, where mutes apply, chirps of users they muted or containing a keyword they muted
+type viewFilter struct {
+	dbS      *DBStructure
+	uID      int
+	hidden   map[int]bool
+	keywords []string
+}
+
+func newViewFilter(dbS *DBStructure, uID int, withMutes bool) viewFilter {
+	f := viewFilter{dbS: dbS, uID: uID, hidden: make(map[int]bool)}
+	for _, b := range dbS.Blocks {
+		if b.BlockerID == uID {
+			f.hidden[b.BlockedID] = true
+		}
+		if b.BlockedID == uID {
+			f.hidden[b.BlockerID] = true
+		}
+	}
+
+	if !withMutes {
+		return f
+	}
+
+	for _, m := range dbS.Mutes {
+		if m.MuterID == uID {
+			f.hidden[m.MutedID] = true
+		}
+	}
+	f.keywords = dbS.Users[uID].MutedKeywords
+
+	return f
+}
+
+// reports whether the chirp, or the chirp it rechirps or quotes, is by a hidden user or contains
+// a muted keyword
+func (f viewFilter) hides(c Chirp) bool {
+	if f.hidesChirp(c) {
+		return true
+	}
+
+	for _, ref := range []int{c.RechirpOfID, c.QuoteOfID} {
+		if orig, ok := f.dbS.Chirps[ref]; ok && ref != 0 && f.hidesChirp(orig) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (f viewFilter) hidesChirp(c Chirp) bool {
+	if c.UserID == f.uID {
+		return false
+	}
+	if f.hidden[c.UserID] {
+		return true
+	}
+
+	for _, kw := range f.keywords {
+		if containsKeyword(c.Body, kw) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// reports whether the notification's actor is hidden or the chirp they wrote it about contains a muted
+// keyword. a like or rechirp is about the notified user's own chirp, which their mutes don't apply to
+func (f viewFilter) hidesNotification(n Notification) bool {
+	if f.hidden[n.ActorID] {
+		return true
+	}
+
+	c, ok := f.dbS.Chirps[n.ChirpID]
+	return ok && n.ChirpID != 0 && c.UserID == n.ActorID && f.hidesChirp(c)
+}
+
+// returns the IDs of the chirps the user shouldn't be shown because of blocks or, if withMutes, mutes.
+// users are never hidden from themselves
+func (db *DB) HiddenChirps(uID int, chirps []Chirp, withMutes bool) (map[int]bool, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	dbS, err := db.loadDB()
+	if err != nil {
+		return nil, err
+	}
+
+	f := newViewFilter(&dbS, uID, withMutes)
+	hidden := make(map[int]bool)
+	for _, c := range chirps {
+		if f.hides(c) {
+			hidden[c.ID] = true
+		}
+	}
+
+	return hidden, nil
+}
@@ -0,0 +1,113 @@
+// Package entities finds the hashtags, mentions and URLs in chirp bodies
+package entities
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Entities are what Parse found in a body. offsets count characters rather than bytes, Start is
+// inclusive and End exclusive
+type Entities struct {
+	Hashtags []Hashtag `json:"hashtags"`
+	Mentions []Mention `json:"mentions"`
+	URLs     []URL     `json:"urls"`
+}
+
+type Hashtag struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+	// lowercased and without the #
+	Tag string `json:"tag"`
+}
+
+type Mention struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+	// without the @
+	Username string `json:"username"`
+	// the mentioned user, 0 if no user goes by Username. it's only used to notify them when the chirp is
+	// created and never stored or shown, since it'd tell anyone which user the email behind Username belongs to
+	UserID int `json:"-"`
+}
+
+type URL struct {
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+	URL   string `json:"url"`
+}
+
+var (
+	urlRe = regexp.MustCompile(`https?://[^\s]+`)
+	// hashtags and mentions have to start a word, so neither foo#bar nor an email address is one
+	hashtagRe = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_&/])(#[\p{L}\p{N}_]*\p{L}[\p{L}\p{N}_]*)`)
+	mentionRe = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_.+\-/])(@[A-Za-z0-9_.+\-]*[A-Za-z0-9_+\-])`)
+)
+
+// finds the entities in body, hashtags and mentions within URLs are left out
+func Parse(body string) Entities {
+	e := Entities{
+		Hashtags: make([]Hashtag, 0),
+		Mentions: make([]Mention, 0),
+		URLs:     make([]URL, 0),
+	}
+
+	urlSpans := make([][2]int, 0)
+	for _, loc := range urlRe.FindAllStringIndex(body, -1) {
+		// punctuation right after a URL is more likely to end the sentence than to be part of it
+		end := loc[0] + len(strings.TrimRight(body[loc[0]:loc[1]], `.,!?;:'")]`))
+		urlSpans = append(urlSpans, [2]int{loc[0], end})
+		e.URLs = append(e.URLs, URL{
+			Start: runeOffset(body, loc[0]),
+			End:   runeOffset(body, end),
+			URL:   body[loc[0]:end],
+		})
+	}
+
+	inURL := func(i int) bool {
+		for _, s := range urlSpans {
+			if i >= s[0] && i < s[1] {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, loc := range hashtagRe.FindAllStringSubmatchIndex(body, -1) {
+		if inURL(loc[2]) {
+			continue
+		}
+		e.Hashtags = append(e.Hashtags, Hashtag{
+			Start: runeOffset(body, loc[2]),
+			End:   runeOffset(body, loc[3]),
+			Tag:   strings.ToLower(body[loc[2]+1 : loc[3]]),
+		})
+	}
+
+	for _, loc := range mentionRe.FindAllStringSubmatchIndex(body, -1) {
+		if inURL(loc[2]) {
+			continue
+		}
+		e.Mentions = append(e.Mentions, Mention{
+			Start:    runeOffset(body, loc[2]),
+			End:      runeOffset(body, loc[3]),
+			Username: body[loc[2]+1 : loc[3]],
+		})
+	}
+
+	return e
+}
+
+// returns the set of the hashtags' tags
+func (e Entities) Tags() map[string]bool {
+	tags := make(map[string]bool, len(e.Hashtags))
+	for _, h := range e.Hashtags {
+		tags[h.Tag] = true
+	}
+	return tags
+}
+
+func runeOffset(s string, byteOffset int) int {
+	return utf8.RuneCountInString(s[:byteOffset])
+}
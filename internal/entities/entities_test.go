@@ -0,0 +1,53 @@
+package entities
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		body     string
+		hashtags []Hashtag
+		mentions []Mention
+		urls     []URL
+	}{
+		{
+			body:     "#Go is fun, ask @bob.",
+			hashtags: []Hashtag{{Start: 0, End: 3, Tag: "go"}},
+			mentions: []Mention{{Start: 16, End: 20, Username: "bob"}},
+			urls:     []URL{},
+		},
+		{
+			body:     "mail me at bob@example.com #1 foo#bar",
+			hashtags: []Hashtag{},
+			mentions: []Mention{},
+			urls:     []URL{},
+		},
+		{
+			body:     "see https://example.com/a#frag, @alice #café!",
+			hashtags: []Hashtag{{Start: 39, End: 44, Tag: "café"}},
+			mentions: []Mention{{Start: 32, End: 38, Username: "alice"}},
+			urls:     []URL{{Start: 4, End: 30, URL: "https://example.com/a#frag"}},
+		},
+		{
+			body:     "héllo #ÜBER",
+			hashtags: []Hashtag{{Start: 6, End: 11, Tag: "über"}},
+			mentions: []Mention{},
+			urls:     []URL{},
+		},
+	}
+
+	for _, cs := range cases {
+		e := Parse(cs.body)
+		if !reflect.DeepEqual(e.Hashtags, cs.hashtags) {
+			t.Errorf("%q: expected hashtags %v, got %v", cs.body, cs.hashtags, e.Hashtags)
+		}
+		if !reflect.DeepEqual(e.Mentions, cs.mentions) {
+			t.Errorf("%q: expected mentions %v, got %v", cs.body, cs.mentions, e.Mentions)
+		}
+		if !reflect.DeepEqual(e.URLs, cs.urls) {
+			t.Errorf("%q: expected urls %v, got %v", cs.body, cs.urls, e.URLs)
+		}
+	}
+}
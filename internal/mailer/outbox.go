@@ -0,0 +1,60 @@
+package mailer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Outbox is a Mailer that never leaves the machine, it keeps every message in memory
+// and, if dir is set, drops a copy of it as a .eml file in dir. meant for local development and tests
+type Outbox struct {
+	dir  string
+	mux  *sync.Mutex
+	sent []Message
+}
+
+// NewOutbox creates an Outbox and its directory if it doesn't exist, an empty dir keeps messages in memory only
+func NewOutbox(dir string) (*Outbox, error) {
+	if dir != "" {
+		err := os.MkdirAll(dir, 0755)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &Outbox{
+		dir: dir,
+		mux: &sync.Mutex{},
+	}, nil
+}
+
+func (o *Outbox) Send(msg Message) error {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+
+	o.sent = append(o.sent, msg)
+
+	if o.dir == "" {
+		return nil
+	}
+
+	name := fmt.Sprintf("%d-%s.eml", time.Now().UnixNano(), strings.ReplaceAll(msg.To, "@", "_at_"))
+	dat := fmt.Sprintf("To: %s\r\nSubject: %s\r\n\r\n%s\r\n", msg.To, msg.Subject, msg.Body)
+
+	return os.WriteFile(filepath.Join(o.dir, filepath.Base(name)), []byte(dat), 0644)
+}
+
+// Messages returns a copy of every message sent so far, oldest first
+func (o *Outbox) Messages() []Message {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+
+	msgs := make([]Message, len(o.sent))
+	copy(msgs, o.sent)
+
+	return msgs
+}
@@ -0,0 +1,39 @@
+package mailer
+
+import (
+	"fmt"
+	"net"
+	"net/smtp"
+)
+
+// SMTP is a Mailer that relays messages through an SMTP server
+type SMTP struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+// NewSMTP creates an SMTP mailer for addr (host:port), username may be empty for unauthenticated relays
+func NewSMTP(addr, from, username, password string) (*SMTP, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	var a smtp.Auth
+	if username != "" {
+		a = smtp.PlainAuth("", username, password, host)
+	}
+
+	return &SMTP{
+		addr: addr,
+		from: from,
+		auth: a,
+	}, nil
+}
+
+func (s *SMTP) Send(msg Message) error {
+	dat := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\n\r\n%s\r\n", s.from, msg.To, msg.Subject, msg.Body)
+
+	return smtp.SendMail(s.addr, s.auth, s.from, []string{msg.To}, []byte(dat))
+}
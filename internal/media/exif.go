@@ -0,0 +1,114 @@
+package media
+
+import (
+	"encoding/binary"
+	"image"
+	"image/draw"
+)
+
+// returns the EXIF orientation of the JPEG in data, from 1 to 8, or 1 if it has none
+func jpegOrientation(data []byte) int {
+	// segments follow the start of image marker until the image data starts
+	for i := 2; i+4 <= len(data) && data[i] == 0xff; {
+		marker := data[i+1]
+		if marker == 0xda || marker == 0xd9 {
+			break
+		}
+
+		n := int(binary.BigEndian.Uint16(data[i+2:]))
+		if n < 2 || i+2+n > len(data) {
+			break
+		}
+		seg := data[i+4 : i+2+n]
+		if marker == 0xe1 && len(seg) > 6 && string(seg[:6]) == "Exif\x00\x00" {
+			return tiffOrientation(seg[6:])
+		}
+		i += 2 + n
+	}
+
+	return 1
+}
+
+// reads the orientation tag from the first IFD of the TIFF structure EXIF data is stored in
+func tiffOrientation(t []byte) int {
+	if len(t) < 8 {
+		return 1
+	}
+
+	var order binary.ByteOrder
+	switch string(t[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	ifd := int(order.Uint32(t[4:]))
+	if ifd < 8 || ifd+2 > len(t) {
+		return 1
+	}
+
+	entries := int(order.Uint16(t[ifd:]))
+	for e := 0; e < entries; e++ {
+		off := ifd + 2 + e*12
+		if off+12 > len(t) {
+			break
+		}
+		// a SHORT, stored in the first two bytes of the value
+		if order.Uint16(t[off:]) == 0x0112 && order.Uint16(t[off+2:]) == 3 {
+			o := int(order.Uint16(t[off+8:]))
+			if o >= 1 && o <= 8 {
+				return o
+			}
+			break
+		}
+	}
+
+	return 1
+}
+
+// returns m transformed the way EXIF orientation o says it has to be to be upright
+func orient(m image.Image, o int) image.Image {
+	if o <= 1 || o > 8 {
+		return m
+	}
+
+	b := m.Bounds()
+	src := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(src, src.Bounds(), m, b.Min, draw.Src)
+	w, h := b.Dx(), b.Dy()
+
+	// orientations 5 to 8 swap width and height
+	dw, dh := w, h
+	if o >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch o {
+			case 2:
+				dx, dy = w-1-x, y
+			case 3:
+				dx, dy = w-1-x, h-1-y
+			case 4:
+				dx, dy = x, h-1-y
+			case 5:
+				dx, dy = y, x
+			case 6:
+				dx, dy = h-1-y, x
+			case 7:
+				dx, dy = h-1-y, w-1-x
+			case 8:
+				dx, dy = y, w-1-x
+			}
+			dst.SetNRGBA(dx, dy, src.NRGBAAt(x, y))
+		}
+	}
+
+	return dst
+}
@@ -0,0 +1,74 @@
+package media
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+var errBadGIF = errors.New("gif: malformed block structure")
+
+// counts the frames of the GIF in data and the pixels they have altogether by walking its blocks,
+// without decompressing any of them
+func gifFrames(data []byte) (frames, pixels int, err error) {
+	// header and logical screen descriptor
+	if len(data) < 13 {
+		return 0, 0, errBadGIF
+	}
+	i := 13
+	if data[10]&0x80 != 0 {
+		i += 3 << (data[10]&0x07 + 1)
+	}
+
+	for i < len(data) {
+		switch data[i] {
+		case 0x3b:
+			// trailer
+			return frames, pixels, nil
+		case 0x21:
+			// extension, its label is followed by data sub-blocks
+			i, err = skipSubBlocks(data, i+2)
+			if err != nil {
+				return 0, 0, err
+			}
+		case 0x2c:
+			// image descriptor, then a local color table if it has one, the LZW code size and the image data
+			if i+10 > len(data) {
+				return 0, 0, errBadGIF
+			}
+			w := int(binary.LittleEndian.Uint16(data[i+5:]))
+			h := int(binary.LittleEndian.Uint16(data[i+7:]))
+			frames++
+			pixels += w * h
+
+			flags := data[i+9]
+			i += 10
+			if flags&0x80 != 0 {
+				i += 3 << (flags&0x07 + 1)
+			}
+			i, err = skipSubBlocks(data, i+1)
+			if err != nil {
+				return 0, 0, err
+			}
+		default:
+			return 0, 0, errBadGIF
+		}
+	}
+
+	// decoders put up with a missing trailer
+	return frames, pixels, nil
+}
+
+// returns the index after the data sub-blocks starting at i, the last of which is empty
+func skipSubBlocks(data []byte, i int) (int, error) {
+	for {
+		if i >= len(data) {
+			return 0, errBadGIF
+		}
+		n := int(data[i])
+		i++
+		if n == 0 {
+			return i, nil
+		}
+		i += n
+	}
+}
@@ -0,0 +1,185 @@
+// Package media validates uploaded images, strips their metadata and makes thumbnails of them
+package media
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+const (
+	// images with more pixels than this are rejected before they're decoded, for GIFs it's all their frames' pixels
+	MaxPixels = 40_000_000
+	// GIFs with more frames than this are rejected before they're decoded
+	MaxGIFFrames = 500
+	// thumbnails fit in a square this many pixels wide
+	ThumbnailSize = 320
+)
+
+// ErrUnsupported is returned for uploads that aren't JPEG, PNG or GIF images
+var ErrUnsupported = errors.New("only JPEG, PNG and GIF images are supported")
+
+// Image is an uploaded image re-encoded without its metadata, along with a thumbnail of it
+type Image struct {
+	ContentType string
+	Data        []byte
+	Width       int
+	Height      int
+	// a JPEG, unless the image may be transparent, then it's a PNG
+	ThumbnailType string
+	Thumbnail     []byte
+}
+
+// sniffs the type of data rather than trusting what the upload claims, then decodes and re-encodes it.
+// re-encoding drops EXIF and any other metadata, JPEGs are rotated upright first as their EXIF
+// orientation would be lost otherwise
+func Process(data []byte) (Image, error) {
+	ct := http.DetectContentType(data)
+	switch ct {
+	case "image/jpeg", "image/png", "image/gif":
+	default:
+		return Image{}, ErrUnsupported
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return Image{}, fmt.Errorf("couldn't decode image: %w", err)
+	}
+	if cfg.Width*cfg.Height > MaxPixels {
+		return Image{}, fmt.Errorf("image can't have more than %d pixels", MaxPixels)
+	}
+
+	img := Image{ContentType: ct}
+	var first image.Image
+	buf := &bytes.Buffer{}
+	switch ct {
+	case "image/jpeg":
+		m, err := jpeg.Decode(bytes.NewReader(data))
+		if err != nil {
+			return Image{}, fmt.Errorf("couldn't decode image: %w", err)
+		}
+		first = orient(m, jpegOrientation(data))
+		err = jpeg.Encode(buf, first, &jpeg.Options{Quality: 90})
+		if err != nil {
+			return Image{}, err
+		}
+	case "image/png":
+		m, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			return Image{}, fmt.Errorf("couldn't decode image: %w", err)
+		}
+		first = m
+		err = png.Encode(buf, m)
+		if err != nil {
+			return Image{}, err
+		}
+	case "image/gif":
+		// the logical screen says nothing about how many frames there are to decode
+		frames, pixels, err := gifFrames(data)
+		if err != nil {
+			return Image{}, fmt.Errorf("couldn't decode image: %w", err)
+		}
+		if frames > MaxGIFFrames {
+			return Image{}, fmt.Errorf("GIFs can't have more than %d frames", MaxGIFFrames)
+		}
+		if pixels > MaxPixels {
+			return Image{}, fmt.Errorf("image can't have more than %d pixels", MaxPixels)
+		}
+
+		// every frame is kept, but not the comments and application extensions
+		g, err := gif.DecodeAll(bytes.NewReader(data))
+		if err != nil {
+			return Image{}, fmt.Errorf("couldn't decode image: %w", err)
+		}
+		first = g.Image[0]
+		err = gif.EncodeAll(buf, &gif.GIF{
+			Image:     g.Image,
+			Delay:     g.Delay,
+			LoopCount: g.LoopCount,
+			Disposal:  g.Disposal,
+			Config:    g.Config,
+		})
+		if err != nil {
+			return Image{}, err
+		}
+	}
+	img.Data = buf.Bytes()
+	img.Width = first.Bounds().Dx()
+	img.Height = first.Bounds().Dy()
+
+	thumb := &bytes.Buffer{}
+	if ct == "image/jpeg" {
+		img.ThumbnailType = "image/jpeg"
+		err = jpeg.Encode(thumb, Thumbnail(first, ThumbnailSize), &jpeg.Options{Quality: 80})
+	} else {
+		img.ThumbnailType = "image/png"
+		err = png.Encode(thumb, Thumbnail(first, ThumbnailSize))
+	}
+	if err != nil {
+		return Image{}, err
+	}
+	img.Thumbnail = thumb.Bytes()
+
+	return img, nil
+}
+
+// scales m down to fit in a size by size square, keeping its aspect ratio. every pixel of the thumbnail
+// averages the pixels of m it covers. images that already fit are only copied
+func Thumbnail(m image.Image, size int) *image.NRGBA {
+	b := m.Bounds()
+	w, h := b.Dx(), b.Dy()
+	tw, th := w, h
+	if w > size || h > size {
+		if w >= h {
+			tw, th = size, max(1, h*size/w)
+		} else {
+			tw, th = max(1, w*size/h), size
+		}
+	}
+
+	src := image.NewNRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(src, src.Bounds(), m, b.Min, draw.Src)
+	if tw == w && th == h {
+		return src
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, tw, th))
+	for y := 0; y < th; y++ {
+		y0, y1 := y*h/th, max((y+1)*h/th, y*h/th+1)
+		for x := 0; x < tw; x++ {
+			x0, x1 := x*w/tw, max((x+1)*w/tw, x*w/tw+1)
+
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					c := src.NRGBAAt(sx, sy)
+					// weighted by alpha so transparent pixels don't darken the edges
+					r += uint64(c.R) * uint64(c.A)
+					g += uint64(c.G) * uint64(c.A)
+					bl += uint64(c.B) * uint64(c.A)
+					a += uint64(c.A)
+					n++
+				}
+			}
+
+			if a == 0 {
+				continue
+			}
+			dst.SetNRGBA(x, y, color.NRGBA{
+				R: uint8(r / a),
+				G: uint8(g / a),
+				B: uint8(bl / a),
+				A: uint8(a / n),
+			})
+		}
+	}
+
+	return dst
+}
@@ -0,0 +1,161 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+// returns a JPEG of m with an EXIF segment holding orientation o
+func jpegWithOrientation(t *testing.T, m image.Image, o uint16) []byte {
+	buf := &bytes.Buffer{}
+	err := jpeg.Encode(buf, m, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// little endian TIFF header, one IFD at offset 8 with a single orientation entry
+	tiff := []byte("II*\x00\x08\x00\x00\x00\x01\x00")
+	entry := make([]byte, 12)
+	binary.LittleEndian.PutUint16(entry[0:], 0x0112)
+	binary.LittleEndian.PutUint16(entry[2:], 3)
+	binary.LittleEndian.PutUint32(entry[4:], 1)
+	binary.LittleEndian.PutUint16(entry[8:], o)
+	tiff = append(tiff, entry...)
+	tiff = append(tiff, 0, 0, 0, 0)
+
+	seg := append([]byte("Exif\x00\x00"), tiff...)
+	app1 := []byte{0xff, 0xe1, 0, 0}
+	binary.BigEndian.PutUint16(app1[2:], uint16(len(seg)+2))
+	app1 = append(app1, seg...)
+
+	data := buf.Bytes()
+	return append(append(append([]byte{}, data[:2]...), app1...), data[2:]...)
+}
+
+func TestProcessJPEG(t *testing.T) {
+	m := image.NewRGBA(image.Rect(0, 0, 400, 200))
+	data := jpegWithOrientation(t, m, 6)
+	if jpegOrientation(data) != 6 {
+		t.Fatalf("expected the test JPEG to have orientation 6, got %d", jpegOrientation(data))
+	}
+
+	img, err := Process(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Contains(img.Data, []byte("Exif")) {
+		t.Error("expected EXIF to be stripped")
+	}
+	if img.ContentType != "image/jpeg" || img.Width != 200 || img.Height != 400 {
+		t.Errorf("expected an upright 200x400 JPEG, got a %dx%d %s", img.Width, img.Height, img.ContentType)
+	}
+
+	thumb, err := jpeg.DecodeConfig(bytes.NewReader(img.Thumbnail))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if thumb.Width != 160 || thumb.Height != ThumbnailSize {
+		t.Errorf("expected a 160x%d thumbnail, got %dx%d", ThumbnailSize, thumb.Width, thumb.Height)
+	}
+}
+
+func TestProcessPNG(t *testing.T) {
+	m := image.NewNRGBA(image.Rect(0, 0, 20, 10))
+	m.SetNRGBA(0, 0, color.NRGBA{R: 255, A: 255})
+	buf := &bytes.Buffer{}
+	png.Encode(buf, m)
+
+	img, err := Process(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// small images aren't scaled up
+	thumb, err := png.Decode(bytes.NewReader(img.Thumbnail))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.ThumbnailType != "image/png" || thumb.Bounds().Dx() != 20 || thumb.Bounds().Dy() != 10 {
+		t.Errorf("expected a 20x10 PNG thumbnail, got a %v %s", thumb.Bounds(), img.ThumbnailType)
+	}
+}
+
+func TestProcessRejects(t *testing.T) {
+	if _, err := Process([]byte("<html>not an image</html>")); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported, got %v", err)
+	}
+
+	// a PNG header claiming to be huge is rejected without decoding the rest
+	m := image.NewGray(image.Rect(0, 0, 1, 1))
+	buf := &bytes.Buffer{}
+	png.Encode(buf, m)
+	data := buf.Bytes()
+	binary.BigEndian.PutUint32(data[16:], 100000)
+	binary.BigEndian.PutUint32(data[20:], 100000)
+	if _, err := Process(data); err == nil {
+		t.Error("expected an image with too many pixels to be rejected")
+	}
+}
+
+// returns a GIF with n frames of size by size pixels
+func animatedGIF(t *testing.T, n, size int) []byte {
+	g := &gif.GIF{}
+	for i := 0; i < n; i++ {
+		frame := image.NewPaletted(image.Rect(0, 0, size, size), color.Palette{color.Black, color.White})
+		frame.SetColorIndex(0, 0, uint8(i%2))
+		g.Image = append(g.Image, frame)
+		g.Delay = append(g.Delay, 10)
+	}
+
+	buf := &bytes.Buffer{}
+	err := gif.EncodeAll(buf, g)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestProcessGIF(t *testing.T) {
+	img, err := Process(animatedGIF(t, 3, 16))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g, err := gif.DecodeAll(bytes.NewReader(img.Data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(g.Image) != 3 || img.Width != 16 || img.Height != 16 {
+		t.Errorf("expected the 3 frames of 16x16 to be kept, got %d of %dx%d", len(g.Image), img.Width, img.Height)
+	}
+
+	if _, err := Process(animatedGIF(t, MaxGIFFrames+1, 1)); err == nil {
+		t.Error("expected a GIF with too many frames to be rejected")
+	}
+
+	// frames claiming to be huge are rejected without decoding them, even if the logical screen is tiny
+	data := animatedGIF(t, 2, 1)
+	descriptor := []byte{0x2c, 0, 0, 0, 0, 1, 0, 1, 0}
+	for i := bytes.Index(data, descriptor); i >= 0; {
+		binary.LittleEndian.PutUint16(data[i+5:], 6000)
+		binary.LittleEndian.PutUint16(data[i+7:], 6000)
+		j := bytes.Index(data[i+1:], descriptor)
+		if j < 0 {
+			break
+		}
+		i += 1 + j
+	}
+	if _, err := Process(data); err == nil || !strings.Contains(err.Error(), "pixels") {
+		t.Errorf("expected a GIF with too many frame pixels to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,165 @@
+package moderation
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/hatrnuhn/chirpy-webserver/internal/entities"
+)
+
+// BannedWords replaces banned words with a replacement, wherever they appear as whole words in any case
+type BannedWords struct {
+	re          *regexp.Regexp
+	replacement string
+}
+
+func NewBannedWords(words []string, replacement string) *BannedWords {
+	quoted := make([]string, 0, len(words))
+	for _, w := range words {
+		if w = strings.TrimSpace(w); w != "" {
+			quoted = append(quoted, regexp.QuoteMeta(w))
+		}
+	}
+
+	bw := &BannedWords{replacement: replacement}
+	if len(quoted) > 0 {
+		bw.re = regexp.MustCompile(`(?i)(^|[^\p{L}\p{N}_])(` + strings.Join(quoted, "|") + `)($|[^\p{L}\p{N}_])`)
+	}
+	return bw
+}
+
+func (bw *BannedWords) Name() string { return "banned_words" }
+
+func (bw *BannedWords) Check(c Chirp) (Verdict, error) {
+	if bw.re == nil {
+		return Verdict{Action: Allow}, nil
+	}
+
+	// matches can share the character between them, so replacing once could skip the second of two
+	// banned words in a row
+	body := c.Body
+	for {
+		next := bw.re.ReplaceAllString(body, "${1}"+strings.ReplaceAll(bw.replacement, "$", "$$")+"${3}")
+		if next == body {
+			break
+		}
+		body = next
+	}
+
+	if body == c.Body {
+		return Verdict{Action: Allow}, nil
+	}
+	return Verdict{Action: Rewrite, Body: body, Reason: "banned words were replaced"}, nil
+}
+
+// LinkBlocklist flags chirps linking to blocked domains or their subdomains
+type LinkBlocklist struct {
+	Domains []string
+	Action  Action
+}
+
+func (lb *LinkBlocklist) Name() string { return "link_blocklist" }
+
+func (lb *LinkBlocklist) Check(c Chirp) (Verdict, error) {
+	for _, u := range entities.Parse(c.Body).URLs {
+		parsed, err := url.Parse(u.URL)
+		if err != nil {
+			continue
+		}
+		host := strings.ToLower(parsed.Hostname())
+
+		for _, d := range lb.Domains {
+			d = strings.ToLower(d)
+			if host == d || strings.HasSuffix(host, "."+d) {
+				return Verdict{Action: lb.Action, Reason: fmt.Sprintf("links to %s aren't allowed", d)}, nil
+			}
+		}
+	}
+
+	return Verdict{Action: Allow}, nil
+}
+
+// Spam flags chirps with too many links, mentions or hashtags, or a character repeated too many times
+// in a row. limits that are 0 aren't checked
+type Spam struct {
+	MaxURLs          int
+	MaxMentions      int
+	MaxHashtags      int
+	MaxRepeatedChars int
+	Action           Action
+}
+
+func (s *Spam) Name() string { return "spam" }
+
+func (s *Spam) Check(c Chirp) (Verdict, error) {
+	e := entities.Parse(c.Body)
+	flag := func(reason string) (Verdict, error) {
+		return Verdict{Action: s.Action, Reason: reason}, nil
+	}
+
+	if s.MaxURLs > 0 && len(e.URLs) > s.MaxURLs {
+		return flag("too many links")
+	}
+	if s.MaxMentions > 0 && len(e.Mentions) > s.MaxMentions {
+		return flag("too many mentions")
+	}
+	if s.MaxHashtags > 0 && len(e.Hashtags) > s.MaxHashtags {
+		return flag("too many hashtags")
+	}
+
+	if s.MaxRepeatedChars > 0 {
+		run, last := 0, rune(-1)
+		for _, r := range c.Body {
+			if r == last {
+				run++
+			} else {
+				run, last = 1, r
+			}
+			if run > s.MaxRepeatedChars {
+				return flag("too many repeated characters")
+			}
+		}
+	}
+
+	return Verdict{Action: Allow}, nil
+}
+
+// RecentFunc returns the bodies of the chirps the user published since the given time
+type RecentFunc func(uID int, since time.Time) ([]string, error)
+
+// Duplicates flags chirps with the same body as one their author published within Window, ignoring
+// case and whitespace
+type Duplicates struct {
+	Window time.Duration
+	Recent RecentFunc
+	Action Action
+}
+
+func (d *Duplicates) Name() string { return "duplicates" }
+
+func (d *Duplicates) Check(c Chirp) (Verdict, error) {
+	body := normalize(c.Body)
+	if body == "" {
+		return Verdict{Action: Allow}, nil
+	}
+
+	recent, err := d.Recent(c.UserID, time.Now().Add(-d.Window))
+	if err != nil {
+		return Verdict{}, err
+	}
+
+	for _, r := range recent {
+		if normalize(r) == body {
+			return Verdict{Action: d.Action, Reason: "you recently chirped the same thing"}, nil
+		}
+	}
+
+	return Verdict{Action: Allow}, nil
+}
+
+func normalize(body string) string {
+	return strings.ToLower(strings.Join(strings.Fields(body), " "))
+}
@@ -0,0 +1,204 @@
+// Package moderation runs new chirps through filters that decide whether they're published
+package moderation
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// what a filter decides to do with a chirp
+type Action string
+
+const (
+	Allow Action = "allow"
+	// publish the chirp with the body the filter rewrote it to
+	Rewrite Action = "rewrite"
+	// keep the chirp from being published until a moderator approves it
+	Hold   Action = "hold"
+	Reject Action = "reject"
+)
+
+// how strict an action is, the strictest one any filter decides on wins
+var actionRanks = map[Action]int{
+	Allow:   0,
+	Rewrite: 1,
+	Hold:    2,
+	Reject:  3,
+}
+
+// Chirp is a chirp about to be published
+type Chirp struct {
+	UserID int
+	Body   string
+}
+
+// Verdict is what a filter decided, Reason and Filter are set unless it's Allow
+type Verdict struct {
+	Action Action `json:"action"`
+	// the rewritten body, only set for Rewrite
+	Body   string `json:"-"`
+	Reason string `json:"reason,omitempty"`
+	Filter string `json:"filter,omitempty"`
+}
+
+type Filter interface {
+	Name() string
+	Check(c Chirp) (Verdict, error)
+}
+
+// Pipeline runs chirps through its filters in order
+type Pipeline struct {
+	filters []Filter
+}
+
+func NewPipeline(filters ...Filter) *Pipeline {
+	return &Pipeline{filters: filters}
+}
+
+// runs c through every filter, the filters after one that rewrote it see the rewritten body. stops at
+// the first filter that rejects it. the returned verdict's Body is the body to publish, rewritten or not
+func (p *Pipeline) Run(c Chirp) (Verdict, error) {
+	final := Verdict{Action: Allow, Body: c.Body}
+	for _, f := range p.filters {
+		v, err := f.Check(c)
+		if err != nil {
+			return Verdict{}, fmt.Errorf("moderation filter %s: %w", f.Name(), err)
+		}
+		if v.Action == Allow {
+			continue
+		}
+		v.Filter = f.Name()
+
+		if v.Action == Rewrite {
+			c.Body = v.Body
+			final.Body = v.Body
+		}
+		if actionRanks[v.Action] > actionRanks[final.Action] {
+			body := final.Body
+			final = v
+			final.Body = body
+		}
+		if v.Action == Reject {
+			break
+		}
+	}
+
+	return final, nil
+}
+
+// Config is the moderation config file, filters without a section are off
+type Config struct {
+	BannedWords   *BannedWordsConfig   `json:"banned_words"`
+	LinkBlocklist *LinkBlocklistConfig `json:"link_blocklist"`
+	Spam          *SpamConfig          `json:"spam"`
+	Duplicates    *DuplicatesConfig    `json:"duplicates"`
+}
+
+type BannedWordsConfig struct {
+	Words []string `json:"words"`
+	// **** by default
+	Replacement string `json:"replacement"`
+}
+
+type LinkBlocklistConfig struct {
+	Domains []string `json:"domains"`
+	// reject by default
+	Action Action `json:"action"`
+}
+
+type SpamConfig struct {
+	MaxURLs          int `json:"max_urls"`
+	MaxMentions      int `json:"max_mentions"`
+	MaxHashtags      int `json:"max_hashtags"`
+	MaxRepeatedChars int `json:"max_repeated_chars"`
+	// hold by default
+	Action Action `json:"action"`
+}
+
+type DuplicatesConfig struct {
+	WindowSeconds int64 `json:"window_seconds"`
+	// reject by default
+	Action Action `json:"action"`
+}
+
+// reads the JSON config file at path
+func LoadConfig(path string) (Config, error) {
+	dat, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+
+	cfg := Config{}
+	err = json.Unmarshal(dat, &cfg)
+	if err != nil {
+		return Config{}, fmt.Errorf("couldn't parse moderation config: %w", err)
+	}
+
+	return cfg, nil
+}
+
+// builds the pipeline cfg describes. recent looks up the bodies of the chirps a user published since
+// a point in time, for finding duplicates
+func NewPipelineFromConfig(cfg Config, recent RecentFunc) (*Pipeline, error) {
+	filters := make([]Filter, 0)
+
+	if c := cfg.BannedWords; c != nil {
+		replacement := c.Replacement
+		if replacement == "" {
+			replacement = "****"
+		}
+		filters = append(filters, NewBannedWords(c.Words, replacement))
+	}
+
+	if c := cfg.LinkBlocklist; c != nil {
+		action, err := configAction("link_blocklist", c.Action, Reject)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, &LinkBlocklist{Domains: c.Domains, Action: action})
+	}
+
+	if c := cfg.Spam; c != nil {
+		action, err := configAction("spam", c.Action, Hold)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, &Spam{
+			MaxURLs:          c.MaxURLs,
+			MaxMentions:      c.MaxMentions,
+			MaxHashtags:      c.MaxHashtags,
+			MaxRepeatedChars: c.MaxRepeatedChars,
+			Action:           action,
+		})
+	}
+
+	if c := cfg.Duplicates; c != nil {
+		action, err := configAction("duplicates", c.Action, Reject)
+		if err != nil {
+			return nil, err
+		}
+		if c.WindowSeconds <= 0 {
+			return nil, fmt.Errorf("duplicates: window_seconds must be positive")
+		}
+		filters = append(filters, &Duplicates{
+			Window: time.Duration(c.WindowSeconds) * time.Second,
+			Recent: recent,
+			Action: action,
+		})
+	}
+
+	return NewPipeline(filters...), nil
+}
+
+// filters that flag chirps can only hold or reject them
+func configAction(filter string, a Action, def Action) (Action, error) {
+	if a == "" {
+		return def, nil
+	}
+	if a != Hold && a != Reject {
+		return "", fmt.Errorf("%s: action must be hold or reject, not %q", filter, a)
+	}
+	return a, nil
+}
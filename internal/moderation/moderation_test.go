@@ -0,0 +1,70 @@
+package moderation
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPipeline(t *testing.T) {
+	recent := func(uID int, since time.Time) ([]string, error) {
+		if uID == 1 {
+			return []string{"Buy  my **** now"}, nil
+		}
+		return nil, nil
+	}
+
+	p := NewPipeline(
+		NewBannedWords([]string{"kerfuffle", "sharbert"}, "****"),
+		&LinkBlocklist{Domains: []string{"evil.example"}, Action: Reject},
+		&Spam{MaxMentions: 2, MaxRepeatedChars: 5, Action: Hold},
+		&Duplicates{Window: time.Hour, Recent: recent, Action: Reject},
+	)
+
+	cases := []struct {
+		chirp  Chirp
+		action Action
+		body   string
+		filter string
+	}{
+		{chirp: Chirp{UserID: 2, Body: "what a nice day"}, action: Allow, body: "what a nice day"},
+		{chirp: Chirp{UserID: 2, Body: "Kerfuffle sharbert! kerfuffles"}, action: Rewrite, body: "**** ****! kerfuffles", filter: "banned_words"},
+		{chirp: Chirp{UserID: 2, Body: "see https://www.evil.example/x"}, action: Reject, filter: "link_blocklist"},
+		{chirp: Chirp{UserID: 2, Body: "see https://notevil.example/x"}, action: Allow, body: "see https://notevil.example/x"},
+		{chirp: Chirp{UserID: 2, Body: "@a @b @c hi"}, action: Hold, body: "@a @b @c hi", filter: "spam"},
+		{chirp: Chirp{UserID: 2, Body: "sharbert nooooooo"}, action: Hold, body: "**** nooooooo", filter: "spam"},
+		// the duplicate is only found once the banned word is replaced
+		{chirp: Chirp{UserID: 1, Body: "buy my sharbert now"}, action: Reject, filter: "duplicates"},
+		{chirp: Chirp{UserID: 2, Body: "buy my sharbert now"}, action: Rewrite, body: "buy my **** now", filter: "banned_words"},
+	}
+
+	for _, cs := range cases {
+		v, err := p.Run(cs.chirp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v.Action != cs.action || v.Filter != cs.filter {
+			t.Errorf("%q: expected %s by %q, got %s by %q", cs.chirp.Body, cs.action, cs.filter, v.Action, v.Filter)
+		}
+		if cs.action != Reject && v.Body != cs.body {
+			t.Errorf("%q: expected body %q, got %q", cs.chirp.Body, cs.body, v.Body)
+		}
+	}
+}
+
+func TestNewPipelineFromConfig(t *testing.T) {
+	cfg := Config{Spam: &SpamConfig{MaxURLs: 1, Action: Rewrite}}
+
+	if _, err := NewPipelineFromConfig(cfg, nil); err == nil {
+		t.Error("expected spam filters to only be able to hold or reject")
+	}
+
+	cfg.Spam.Action = ""
+	p, err := NewPipelineFromConfig(cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, _ := p.Run(Chirp{Body: "http://a.example http://b.example"})
+	if v.Action != Hold {
+		t.Errorf("expected spam to be held by default, got %s", v.Action)
+	}
+}
@@ -0,0 +1,157 @@
+package oidc
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/hatrnuhn/chirpy-webserver/internal/auth"
+	"github.com/hatrnuhn/chirpy-webserver/internal/oidc/oidctest"
+)
+
+const redirectURL = "http://chirpy.test/api/oidc/callback"
+
+// follows the stub's authorization endpoint and returns the code and state it redirects back with
+func authorize(t *testing.T, authURL string) (string, string) {
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("expected redirect, got %d", resp.StatusCode)
+	}
+
+	loc, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return loc.Query().Get("code"), loc.Query().Get("state")
+}
+
+func TestLoginFlow(t *testing.T) {
+	idp := oidctest.NewProvider("chirpy", "s3cret", oidctest.Identity{Subject: "42", Email: "staff@corp.test", EmailVerified: true})
+	defer idp.Close()
+
+	p := NewProvider(Config{Issuer: idp.URL, ClientID: "chirpy", ClientSecret: "s3cret", RedirectURL: redirectURL})
+	ctx := context.Background()
+
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	authURL, err := p.AuthCodeURL(ctx, "state-1", "nonce-1", auth.PKCEChallenge(verifier))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, state := authorize(t, authURL)
+	if state != "state-1" {
+		t.Fatalf("expected state state-1, got %s", state)
+	}
+
+	claims, err := p.Exchange(ctx, code, verifier, "nonce-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if claims.Subject != "42" || claims.Email != "staff@corp.test" || !claims.EmailVerified {
+		t.Errorf("unexpected claims: %+v", claims)
+	}
+
+	// codes are single use
+	_, err = p.Exchange(ctx, code, verifier, "nonce-1")
+	if err == nil {
+		t.Error("expected replayed code to fail")
+	}
+}
+
+func TestExchangeRejectsWrongVerifierAndNonce(t *testing.T) {
+	idp := oidctest.NewProvider("chirpy", "s3cret", oidctest.Identity{Subject: "42", Email: "staff@corp.test", EmailVerified: true})
+	defer idp.Close()
+
+	p := NewProvider(Config{Issuer: idp.URL, ClientID: "chirpy", ClientSecret: "s3cret", RedirectURL: redirectURL})
+	ctx := context.Background()
+
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+
+	authURL, err := p.AuthCodeURL(ctx, "s", "nonce-1", auth.PKCEChallenge(verifier))
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, _ := authorize(t, authURL)
+	_, err = p.Exchange(ctx, code, verifier+"x", "nonce-1")
+	if err == nil {
+		t.Error("expected wrong PKCE verifier to fail")
+	}
+
+	code, _ = authorize(t, authURL)
+	_, err = p.Exchange(ctx, code, verifier, "nonce-2")
+	if err == nil {
+		t.Error("expected wrong nonce to fail")
+	}
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	idp := oidctest.NewProvider("chirpy", "s3cret", oidctest.Identity{})
+	defer idp.Close()
+
+	p := NewProvider(Config{Issuer: idp.URL, ClientID: "chirpy", ClientSecret: "s3cret", RedirectURL: redirectURL})
+	ctx := context.Background()
+	now := time.Now()
+
+	valid := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss":   idp.URL,
+			"sub":   "42",
+			"aud":   "chirpy",
+			"exp":   now.Add(time.Minute).Unix(),
+			"nonce": "n",
+		}
+	}
+
+	cases := []struct {
+		name   string
+		modify func(jwt.MapClaims)
+		ok     bool
+	}{
+		{name: "valid", modify: func(c jwt.MapClaims) {}, ok: true},
+		{name: "wrong issuer", modify: func(c jwt.MapClaims) { c["iss"] = "https://evil.test" }},
+		{name: "wrong audience", modify: func(c jwt.MapClaims) { c["aud"] = "someone-else" }},
+		{name: "expired", modify: func(c jwt.MapClaims) { c["exp"] = now.Add(-time.Hour).Unix() }},
+		{name: "no expiry", modify: func(c jwt.MapClaims) { delete(c, "exp") }},
+		{name: "wrong nonce", modify: func(c jwt.MapClaims) { c["nonce"] = "other" }},
+	}
+
+	for _, cs := range cases {
+		claims := valid()
+		cs.modify(claims)
+
+		raw, err := idp.SignIDToken(claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = p.VerifyIDToken(ctx, raw, "n")
+		if (err == nil) != cs.ok {
+			t.Errorf("%s: expected ok %v, got error %v", cs.name, cs.ok, err)
+		}
+	}
+
+	// tokens signed with any other key are rejected
+	hs := jwt.NewWithClaims(jwt.SigningMethodHS256, valid())
+	hs.Header["kid"] = "stub-key"
+	raw, _ := hs.SignedString([]byte("secret"))
+	_, err := p.VerifyIDToken(ctx, raw, "n")
+	if err == nil {
+		t.Error("expected HS256 token to be rejected")
+	}
+}
@@ -0,0 +1,81 @@
+// Package trends ranks hashtags by how much they've been used lately
+package trends
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Use is a user using a hashtag at some point
+type Use struct {
+	Tag    string
+	UserID int
+	At     time.Time
+}
+
+type Trend struct {
+	Tag string `json:"tag"`
+	// decayed use of the tag, only meaningful compared to other trends' scores
+	Score float64 `json:"score"`
+	// number of users who used the tag within the window
+	Users int `json:"users"`
+}
+
+type Config struct {
+	// uses older than Window are ignored
+	Window time.Duration
+	// a use counts half as much once it's HalfLife old
+	HalfLife time.Duration
+}
+
+// returns up to limit of the highest scoring tags as of now. a user counts once per tag, with their
+// most recent use, so nobody can push a tag up on their own
+func Compute(uses []Use, now time.Time, cfg Config, limit int) []Trend {
+	type userTag struct {
+		tag string
+		uID int
+	}
+	latest := make(map[userTag]time.Time)
+	for _, u := range uses {
+		age := now.Sub(u.At)
+		if age < 0 || age > cfg.Window {
+			continue
+		}
+
+		key := userTag{tag: u.Tag, uID: u.UserID}
+		if u.At.After(latest[key]) {
+			latest[key] = u.At
+		}
+	}
+
+	byTag := make(map[string]*Trend)
+	for key, at := range latest {
+		t, ok := byTag[key.tag]
+		if !ok {
+			t = &Trend{Tag: key.tag}
+			byTag[key.tag] = t
+		}
+
+		t.Score += math.Pow(0.5, float64(now.Sub(at))/float64(cfg.HalfLife))
+		t.Users++
+	}
+
+	trends := make([]Trend, 0, len(byTag))
+	for _, t := range byTag {
+		trends = append(trends, *t)
+	}
+
+	sort.Slice(trends, func(i, j int) bool {
+		if trends[i].Score != trends[j].Score {
+			return trends[i].Score > trends[j].Score
+		}
+		return trends[i].Tag < trends[j].Tag
+	})
+
+	if len(trends) > limit {
+		trends = trends[:limit]
+	}
+
+	return trends
+}
@@ -0,0 +1,41 @@
+package trends
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCompute(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	cfg := Config{Window: 24 * time.Hour, HalfLife: time.Hour}
+	uses := []Use{
+		// fresh but used by a single user over and over
+		{Tag: "spam", UserID: 1, At: now},
+		{Tag: "spam", UserID: 1, At: now.Add(-time.Minute)},
+		{Tag: "spam", UserID: 1, At: now.Add(-2 * time.Minute)},
+		// three users an hour ago, each counting for half
+		{Tag: "go", UserID: 2, At: now.Add(-time.Hour)},
+		{Tag: "go", UserID: 3, At: now.Add(-time.Hour)},
+		{Tag: "go", UserID: 7, At: now.Add(-time.Hour)},
+		// lots of users, but too long ago
+		{Tag: "old", UserID: 4, At: now.Add(-25 * time.Hour)},
+		{Tag: "old", UserID: 5, At: now.Add(-25 * time.Hour)},
+		{Tag: "old", UserID: 6, At: now.Add(-25 * time.Hour)},
+	}
+
+	trends := Compute(uses, now, cfg, 10)
+	if len(trends) != 2 {
+		t.Fatalf("expected 2 trends, got %v", trends)
+	}
+
+	if trends[0].Tag != "go" || trends[0].Users != 3 || trends[0].Score != 1.5 {
+		t.Errorf("expected go first with a score of 1.5, got %+v", trends[0])
+	}
+	if trends[1].Tag != "spam" || trends[1].Users != 1 || trends[1].Score != 1 {
+		t.Errorf("expected spam second with a score of 1, got %+v", trends[1])
+	}
+
+	if trends := Compute(uses, now, cfg, 1); len(trends) != 1 {
+		t.Errorf("expected the trends to be limited to 1, got %v", trends)
+	}
+}
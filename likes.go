@@ -0,0 +1,258 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+	"github.com/hatrnuhn/chirpy-webserver/internal/auth"
+	"github.com/hatrnuhn/chirpy-webserver/internal/database"
+)
+
+// likes the chirp as the authenticated user, liking it again does nothing
+func (cfg *apiConfig) handlePostChirpLikes(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "bad url")
+		return
+	}
+
+	p, err := cfg.authenticate(r, auth.ScopeChirpsWrite)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	c, created, err := cfg.db.LikeChirp(id, p.UserID)
+	if errors.Is(err, database.ErrNotExist) {
+		respondWithError(w, http.StatusNotFound, fmt.Sprintf("chirp with id: %v is not found", id))
+		return
+	}
+	if errors.Is(err, database.ErrBlocked) {
+		respondWithError(w, http.StatusForbidden, fmt.Sprintf("can't like chirp with id: %v", id))
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't like chirp")
+		return
+	}
+
+	if created {
+		respondWithJSON(w, http.StatusCreated, c)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, c)
+}
+
+// takes the authenticated user's like of the chirp back, if there's one
+func (cfg *apiConfig) handleDelChirpLikes(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "bad url")
+		return
+	}
+
+	p, err := cfg.authenticate(r, auth.ScopeChirpsWrite)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	c, err := cfg.db.UnlikeChirp(id, p.UserID)
+	if errors.Is(err, database.ErrNotExist) {
+		respondWithError(w, http.StatusNotFound, fmt.Sprintf("chirp with id: %v is not found", id))
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't unlike chirp")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, c)
+}
+
+// responds with a page of the users who liked the chirp, most recent first. users the viewer blocked
+// or who blocked them are left out
+func (cfg *apiConfig) handleGetChirpLikes(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "bad url")
+		return
+	}
+
+	p, err := cfg.authenticateOptional(r, auth.ScopeChirpsRead)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	limit, offset, err := parsePage(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	likes, err := cfg.db.GetChirpLikes(id)
+	if errors.Is(err, database.ErrNotExist) {
+		respondWithError(w, http.StatusNotFound, fmt.Sprintf("chirp with id: %v is not found", id))
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't get likes")
+		return
+	}
+
+	if p.UserID != 0 {
+		c, err := cfg.db.GetChirp(id)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "couldn't get chirps")
+			return
+		}
+
+		// a chirp hidden by a block is as good as gone
+		visible, err := cfg.visibleChirps(p.UserID, []database.Chirp{c}, false)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "couldn't get chirps")
+			return
+		}
+		if len(visible) == 0 {
+			respondWithError(w, http.StatusNotFound, fmt.Sprintf("chirp with id: %v is not found", id))
+			return
+		}
+
+		blocked, err := cfg.db.BlockedUsers(p.UserID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "couldn't get blocks")
+			return
+		}
+
+		unblocked := make([]database.Like, 0, len(likes))
+		for _, l := range likes {
+			if !blocked[l.UserID] {
+				unblocked = append(unblocked, l)
+			}
+		}
+		likes = unblocked
+	}
+
+	type liker struct {
+		UserID  int   `json:"user_id"`
+		LikedAt int64 `json:"liked_at"`
+	}
+
+	start, end := pageBounds(len(likes), limit, offset)
+	users := make([]liker, 0, end-start)
+	for _, l := range likes[start:end] {
+		users = append(users, liker{UserID: l.UserID, LikedAt: l.LikedAt})
+	}
+
+	respondWithJSON(w, http.StatusOK, struct {
+		Total int     `json:"total"`
+		Users []liker `json:"users"`
+	}{
+		Total: len(likes),
+		Users: users,
+	})
+}
+
+// responds with a page of the chirps the user liked, most recently liked first. chirps hidden from the
+// viewer by blocks are left out, and users who blocked the viewer or were blocked by them aren't found
+func (cfg *apiConfig) handleGetUserLikes(w http.ResponseWriter, r *http.Request) {
+	uID, err := strconv.Atoi(chi.URLParam(r, "userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "bad url")
+		return
+	}
+
+	p, err := cfg.authenticateOptional(r, auth.ScopeChirpsRead)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	limit, offset, err := parsePage(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	user, err := cfg.db.GetUser(uID)
+	if errors.Is(err, database.ErrNotExist) || user.DeletedAt != 0 {
+		respondWithError(w, http.StatusNotFound, fmt.Sprintf("user with id: %v is not found", uID))
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't get user")
+		return
+	}
+
+	if p.UserID != 0 {
+		blocked, err := cfg.db.BlockedUsers(p.UserID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "couldn't get blocks")
+			return
+		}
+		if blocked[uID] {
+			respondWithError(w, http.StatusNotFound, fmt.Sprintf("user with id: %v is not found", uID))
+			return
+		}
+	}
+
+	likes, err := cfg.db.GetUserLikes(uID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't get likes")
+		return
+	}
+
+	// filtered before paging so pages stay full
+	ids := make([]int, 0, len(likes))
+	likedAt := make(map[int]int64, len(likes))
+	for _, l := range likes {
+		ids = append(ids, l.ChirpID)
+		likedAt[l.ChirpID] = l.LikedAt
+	}
+	byID, err := cfg.db.GetChirpsByID(ids)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't get chirps")
+		return
+	}
+	liked := make([]database.Chirp, 0, len(ids))
+	for _, id := range ids {
+		if c, ok := byID[id]; ok {
+			liked = append(liked, c)
+		}
+	}
+
+	liked, err = cfg.visibleChirps(p.UserID, liked, false)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't get chirps")
+		return
+	}
+
+	type likedChirp struct {
+		Chirp   chirpResponse `json:"chirp"`
+		LikedAt int64         `json:"liked_at"`
+	}
+
+	start, end := pageBounds(len(liked), limit, offset)
+	rendered, err := cfg.newChirpResponses(liked[start:end])
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't get chirps")
+		return
+	}
+
+	chirps := make([]likedChirp, 0, len(rendered))
+	for _, c := range rendered {
+		chirps = append(chirps, likedChirp{Chirp: c, LikedAt: likedAt[c.ID]})
+	}
+
+	respondWithJSON(w, http.StatusOK, struct {
+		Total  int          `json:"total"`
+		Chirps []likedChirp `json:"chirps"`
+	}{
+		Total:  len(liked),
+		Chirps: chirps,
+	})
+}
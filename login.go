@@ -2,19 +2,29 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/hatrnuhn/chirpy-webserver/internal/auth"
 	"github.com/hatrnuhn/chirpy-webserver/internal/database"
-	"golang.org/x/crypto/bcrypt"
 )
 
+// how long users have to enter their TOTP code after their password
+const mfaTokenTTL = 5 * 60
+
 type reqBody struct {
 	Password string `json:"password"`
 	Email    string `json:"email"`
 	Exp      int    `json:"expires_in_seconds"`
+	// browser clients get the tokens as HttpOnly cookies instead of in the response
+	UseCookies bool `json:"use_cookies"`
 }
 
 func (cfg *apiConfig) handlePostLogin(w http.ResponseWriter, r *http.Request) {
@@ -34,44 +44,260 @@ func (cfg *apiConfig) handlePostLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	users, err := cfg.db.GetUsers()
+	user, err := cfg.verifyCredentials(r, req.Email, req.Password)
+	if err != nil {
+		respondWithLoginError(w, err)
+		return
+	}
+
+	cfg.respondWithLogin(w, user, req.Exp, req.UseCookies)
+}
+
+// responds with an MFA challenge if the user has 2FA enabled and with an AJWT and RJWT pair otherwise,
+// for users who proved their first factor
+func (cfg *apiConfig) respondWithLogin(w http.ResponseWriter, user database.User, expiresInSecs int, useCookies bool) {
+	// no point asking suspended users for their second factor
+	if user.SuspendedAt != 0 {
+		respondWithError(w, http.StatusForbidden, errSuspended.Error())
+		return
+	}
+
+	if user.TOTPEnabled {
+		mToken, err := auth.CreateMFAToken(user.ID, cfg.jwtSecret, mfaTokenTTL)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("couldn't create MFA token: %s", err.Error()))
+			return
+		}
+
+		respondWithJSON(w, http.StatusOK, struct {
+			MFARequired bool   `json:"mfa_required"`
+			MFAToken    string `json:"mfa_token"`
+		}{
+			MFARequired: true,
+			MFAToken:    mToken,
+		})
+		return
+	}
+
+	cfg.respondWithTokens(w, user, expiresInSecs, useCookies)
+}
+
+// exchanges an MFA challenge token and a TOTP or recovery code for an AJWT and RJWT pair
+func (cfg *apiConfig) handlePostLoginMFA(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	dat, err := io.ReadAll(r.Body)
 	if err != nil {
-		respondWithError(w, 500, "couldn't get users")
+		respondWithError(w, 500, "couldn't read request")
 		return
 	}
 
+	req := struct {
+		MFAToken     string `json:"mfa_token"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+		Exp          int    `json:"expires_in_seconds"`
+		UseCookies   bool   `json:"use_cookies"`
+	}{}
+	err = json.Unmarshal(dat, &req)
+	if err != nil {
+		respondWithError(w, 400, "couldn't unmarshal request")
+		return
+	}
+
+	mToken, err := auth.ParseToken(req.MFAToken, cfg.jwtSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	claims, ok := mToken.Claims.(*auth.Claims)
+	if !ok || claims.Issuer != "chirpy-mfa" {
+		respondWithError(w, http.StatusUnauthorized, "invalid MFA token")
+		return
+	}
+
+	uID, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "couldn't read ID off token")
+		return
+	}
+
+	user, err := cfg.db.GetUser(uID)
+	if err != nil || !user.TOTPEnabled {
+		respondWithError(w, http.StatusUnauthorized, "invalid MFA token")
+		return
+	}
+
+	err = cfg.verifySecondFactor(r, user, req.Code, req.RecoveryCode)
+	if err != nil {
+		respondWithLoginError(w, err)
+		return
+	}
+
+	cfg.respondWithTokens(w, user, req.Exp, req.UseCookies)
+}
+
+func (cfg *apiConfig) rehashPassword(user database.User, password string) (database.User, error) {
+	hash, err := cfg.passwords.Hash(password)
+	if err != nil {
+		return user, err
+	}
+
+	user.Password = hash
+	return cfg.db.UpdateUser(&user, false)
+}
+
+var errInvalidCredentials = errors.New("incorrect email or password")
+var errInvalidSecondFactor = errors.New("invalid TOTP or recovery code")
+
+// returned when the account or the client has failed to log in too often lately
+type throttledError struct {
+	wait time.Duration
+}
+
+func (e throttledError) Error() string {
+	return "too many failed login attempts, try again later"
+}
+
+// checks email and password and returns the user they belong to, failures count against the login throttles
+// and unknown emails fail the same way wrong passwords do. the stored hash is upgraded if it's outdated
+func (cfg *apiConfig) verifyCredentials(r *http.Request, email, password string) (database.User, error) {
+	accountKey, ipKey := loginAccountKey(email), loginIPKey(r)
+	err := cfg.allowLogin(accountKey, ipKey)
+	if err != nil {
+		return database.User{}, err
+	}
+
+	users, err := cfg.db.GetUsers()
+	if err != nil {
+		return database.User{}, err
+	}
+
 	var user database.User
 	found := false
 
 	for _, u := range users {
-		if req.Email == u.Email {
+		if email == u.Email {
 			user = u
 			found = true
 			break
 		}
 	}
 
+	// unknown emails still pay for a hash comparison so they can't be told apart by response time either
+	hash := user.Password
 	if !found {
-		respondWithError(w, 404, "couldn't not find user with such email")
-		return
+		hash = cfg.dummyHash
+	}
+
+	match, needsRehash, err := cfg.passwords.Verify(password, hash)
+	if !found || !match || err != nil {
+		cfg.accountThrottle.Fail(accountKey)
+		cfg.ipThrottle.Fail(ipKey)
+		return database.User{}, errInvalidCredentials
+	}
+
+	cfg.accountThrottle.Reset(accountKey)
+
+	// upgrades the stored hash to the current algorithm and parameters while the password is at hand
+	if needsRehash {
+		user, err = cfg.rehashPassword(user, password)
+		if err != nil {
+			log.Printf("couldn't rehash password of user %d: %s", user.ID, err.Error())
+		}
+	}
+
+	return user, nil
+}
+
+// checks a TOTP code, or a recovery code if code is empty, of a user with 2FA enabled.
+// codes are far easier to guess than passwords, so they count against the same limits
+func (cfg *apiConfig) verifySecondFactor(r *http.Request, user database.User, code, recoveryCode string) error {
+	accountKey, ipKey := loginAccountKey(user.Email), loginIPKey(r)
+	err := cfg.allowLogin(accountKey, ipKey)
+	if err != nil {
+		return err
+	}
+
+	verified := false
+	if code != "" {
+		step, ok := auth.ValidateTOTP(user.TOTPSecret, code, time.Now())
+		if ok {
+			verified, err = cfg.db.UseTOTPStep(user.ID, step)
+		}
+	} else if recoveryCode != "" {
+		verified, err = cfg.db.UseRecoveryCode(user.ID, auth.HashToken(normalizeRecoveryCode(recoveryCode)))
 	}
 
-	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password))
 	if err != nil {
-		respondWithError(w, 401, "password doesn't not match")
+		return err
+	}
+
+	if !verified {
+		cfg.accountThrottle.Fail(accountKey)
+		cfg.ipThrottle.Fail(ipKey)
+		return errInvalidSecondFactor
+	}
+
+	cfg.accountThrottle.Reset(accountKey)
+
+	return nil
+}
+
+// returns a throttledError if either the account or the client has failed to log in too often lately
+func (cfg *apiConfig) allowLogin(accountKey, ipKey string) error {
+	wait, ok := cfg.accountThrottle.Allow(accountKey)
+	if ok {
+		wait, ok = cfg.ipThrottle.Allow(ipKey)
+	}
+
+	if !ok {
+		return throttledError{wait: wait}
+	}
+
+	return nil
+}
+
+// responds with 429 for throttled logins, 401 for wrong credentials and 500 for everything else
+func respondWithLoginError(w http.ResponseWriter, err error) {
+	var tErr throttledError
+	switch {
+	case errors.As(err, &tErr):
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(tErr.wait.Seconds()))))
+		respondWithError(w, http.StatusTooManyRequests, err.Error())
+	case errors.Is(err, errInvalidCredentials), errors.Is(err, errInvalidSecondFactor):
+		respondWithError(w, http.StatusUnauthorized, err.Error())
+	default:
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("couldn't log in: %s", err.Error()))
+	}
+}
+
+func loginAccountKey(email string) string {
+	return "email:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+// keys on the connection's address only, X-Forwarded-For is whatever the client wants it to be
+func loginIPKey(r *http.Request) string {
+	return "ip:" + remoteIP(r)
+}
+
+// creates an AJWT and RJWT pair for an authenticated user and responds with them,
+// or sets them as session cookies and responds with the CSRF token if useCookies
+func (cfg *apiConfig) respondWithTokens(w http.ResponseWriter, user database.User, expiresInSecs int, useCookies bool) {
+	if user.SuspendedAt != 0 {
+		respondWithError(w, http.StatusForbidden, errSuspended.Error())
 		return
 	}
 
-	// If valid, create JWT
-	userID := user.ID        // this would come from your user validation logic
-	expiresInSecs := req.Exp // also can be configurable depending on your security policy
+	userID := user.ID
 	if expiresInSecs == 0 {
 		expiresInSecs = 3600
 	}
 
 	secsInMonth := 24 * 3600 * 30
 
-	aToken, err := auth.CreateAccessToken(userID, cfg.jwtSecret, int64(expiresInSecs))
+	aToken, err := auth.CreateAccessToken(userID, user.Role, auth.PlanOf(user.IsChirpyRed), user.TokenGeneration, cfg.jwtSecret, int64(expiresInSecs))
 	if err != nil {
 		respondWithError(w, 500, fmt.Sprintf("couldn't create access token: %s", err.Error()))
 		return
@@ -85,7 +311,7 @@ func (cfg *apiConfig) handlePostLogin(w http.ResponseWriter, r *http.Request) {
 		}
 	*/
 
-	rToken, err := auth.CreateRefreshToken(userID, cfg.jwtSecret, int64(secsInMonth))
+	rToken, err := auth.CreateRefreshToken(userID, user.TokenGeneration, cfg.jwtSecret, int64(secsInMonth))
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("couldn't create refresh token: %s", err.Error()))
 		return
@@ -97,6 +323,27 @@ func (cfg *apiConfig) handlePostLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if useCookies {
+		csrf, err := cfg.setSessionCookies(w, aToken, expiresInSecs, rToken, secsInMonth)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("couldn't create CSRF token: %s", err.Error()))
+			return
+		}
+
+		respondWithJSON(w, 200, struct {
+			ID          int    `json:"id"`
+			Email       string `json:"email"`
+			CSRFToken   string `json:"csrf_token"`
+			IsChirpyRed bool   `json:"is_chirpy_red"`
+		}{
+			ID:          user.ID,
+			Email:       user.Email,
+			CSRFToken:   csrf,
+			IsChirpyRed: user.IsChirpyRed,
+		})
+		return
+	}
+
 	respondWithJSON(w, 200, struct {
 		ID          int    `json:"id"`
 		Email       string `json:"email"`
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/hatrnuhn/chirpy-webserver/internal/auth"
+)
+
+// revokes the AJWT the request is made with, right away rather than when it expires
+func (cfg *apiConfig) handlePostLogout(w http.ResponseWriter, r *http.Request) {
+	aToken, err := auth.ParseReq(r, cfg.jwtSecret, "Bearer")
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	claims, ok := aToken.Claims.(*auth.Claims)
+	if !ok || claims.Issuer != "chirpy-access" {
+		respondWithError(w, http.StatusUnauthorized, "invalid AJWT")
+		return
+	}
+
+	_, err = cfg.checkTokenRevocation(claims)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	if claims.ID == "" {
+		respondWithError(w, http.StatusBadRequest, "AJWT has no ID, log out everywhere instead")
+		return
+	}
+
+	err = cfg.db.DenyTokenID(claims.ID, claims.ExpiresAt.Unix())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("couldn't revoke AJWT: %s", err.Error()))
+		return
+	}
+
+	// the session's RJWT goes along with it, header clients revoke theirs through /api/revoke
+	if usesSessionCookies(r) {
+		if c, err := r.Cookie(refreshCookie); err == nil {
+			_, err = cfg.db.WriteRefreshToken(c.Value, time.Now().Unix())
+			if err != nil {
+				respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("couldn't revoke RJWT: %s", err.Error()))
+				return
+			}
+		}
+		cfg.clearSessionCookies(w)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("OK"))
+}
+
+// revokes every AJWT, RJWT and personal access token of the user, including the ones issued to OAuth clients
+func (cfg *apiConfig) handlePostLogoutAll(w http.ResponseWriter, r *http.Request) {
+	uID, err := cfg.authUserID(r)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	err = cfg.logOutEverywhere(uID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("couldn't revoke tokens: %s", err.Error()))
+		return
+	}
+
+	err = cfg.db.DeleteUserAccessTokens(uID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("couldn't revoke personal access tokens: %s", err.Error()))
+		return
+	}
+
+	if usesSessionCookies(r) {
+		cfg.clearSessionCookies(w)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("OK"))
+}
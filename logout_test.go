@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/hatrnuhn/chirpy-webserver/internal/auth"
+)
+
+func TestLogoutDeniesToken(t *testing.T) {
+	cfg := newTestConfig(t)
+	h := cfg.routes()
+	u := createTestUser(t, cfg, "walt@example.com")
+	loggedOut := testAccessToken(t, cfg, u.ID)
+	other := testAccessToken(t, cfg, u.ID)
+
+	w := doRequest(t, h, http.MethodPost, "/api/logout", loggedOut, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("logout: got %d %s", w.Code, w.Body.String())
+	}
+
+	w = doRequest(t, h, http.MethodGet, "/api/users/me", loggedOut, nil)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("logged out token: got %d, want 401", w.Code)
+	}
+
+	// only the one token is denied
+	w = doRequest(t, h, http.MethodGet, "/api/users/me", other, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("other token: got %d %s, want 200", w.Code, w.Body.String())
+	}
+}
+
+func TestLogoutAllRevokesEverything(t *testing.T) {
+	cfg := newTestConfig(t)
+	h := cfg.routes()
+	u := createTestUser(t, cfg, "walt@example.com")
+	bystander := createTestUser(t, cfg, "jesse@example.com")
+
+	login := loginTestUser(t, h, u.Email)
+	pat := testPAT(t, cfg, u.ID, auth.ScopeProfileRead)
+	oauth := testOAuthToken(t, cfg, u.ID, auth.ScopeProfileRead)
+	bystanderPAT := testPAT(t, cfg, bystander.ID, auth.ScopeProfileRead)
+
+	w := doRequest(t, h, http.MethodPost, "/api/logout/all", login.AccessToken, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("logout everywhere: got %d %s", w.Code, w.Body.String())
+	}
+
+	for name, token := range map[string]string{"access token": login.AccessToken, "personal access token": pat, "third-party token": oauth} {
+		w = doRequest(t, h, http.MethodGet, "/api/users/me", token, nil)
+		if w.Code != http.StatusUnauthorized {
+			t.Fatalf("%s: got %d, want 401", name, w.Code)
+		}
+	}
+
+	w = doRequest(t, h, http.MethodPost, "/api/refresh", login.RefreshToken, nil)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("refresh token: got %d, want 401", w.Code)
+	}
+
+	// tokens issued since, and other users' tokens, still work
+	for name, token := range map[string]string{"new access token": testAccessToken(t, cfg, u.ID), "other user's personal access token": bystanderPAT} {
+		w = doRequest(t, h, http.MethodGet, "/api/users/me", token, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: got %d %s, want 200", name, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestPATsSurviveCredentialChanges(t *testing.T) {
+	cfg := newTestConfig(t)
+	h := cfg.routes()
+	admin := createTestUser(t, cfg, "gus@example.com")
+	setTestRole(t, cfg, admin.ID, auth.RoleAdmin)
+	u := createTestUser(t, cfg, "walt@example.com")
+	pat := testPAT(t, cfg, u.ID, auth.ScopeProfileRead)
+
+	w := doRequest(t, h, http.MethodPut, fmt.Sprintf("/admin/users/%d/role", u.ID), testAccessToken(t, cfg, admin.ID), map[string]string{"role": auth.RoleModerator})
+	if w.Code != http.StatusOK {
+		t.Fatalf("role change: got %d %s", w.Code, w.Body.String())
+	}
+
+	w = doRequest(t, h, http.MethodPut, "/api/users", testAccessToken(t, cfg, u.ID), map[string]string{"password": "an0ther-Chirp-at-dusk"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("password change: got %d %s", w.Code, w.Body.String())
+	}
+
+	w = doRequest(t, h, http.MethodPost, "/api/password/forgot", "", map[string]string{"email": u.Email})
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("password reset request: got %d %s", w.Code, w.Body.String())
+	}
+	m := resetTokenRe.FindStringSubmatch(waitForMail(t, cfg, 1).Body)
+	if m == nil {
+		t.Fatal("no reset token mailed")
+	}
+	w = doRequest(t, h, http.MethodPost, "/api/password/reset", "", map[string]string{"token": m[1], "password": "yet-An0ther-chirp"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("password reset: got %d %s", w.Code, w.Body.String())
+	}
+
+	w = doRequest(t, h, http.MethodGet, "/api/users/me", pat, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("personal access token: got %d %s, want 200", w.Code, w.Body.String())
+	}
+
+	resp := struct {
+		Role string `json:"role"`
+	}{}
+	decodeBody(t, w, &resp)
+	if resp.Role != auth.RoleModerator {
+		t.Errorf("got role %q through the personal access token, want %q", resp.Role, auth.RoleModerator)
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/hatrnuhn/chirpy-webserver/internal/auth"
+	"github.com/hatrnuhn/chirpy-webserver/internal/database"
+	"github.com/hatrnuhn/chirpy-webserver/internal/mailer"
+)
+
+const magicLinkTTL = 15 * time.Minute
+
+// accepts an email and mails a magic login link if it belongs to a user, responds with 202 either way
+// so it can't be used to find out which emails are registered. requests are throttled per email
+func (cfg *apiConfig) handlePostLoginMagic(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	dat, err := io.ReadAll(r.Body)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "couldn't read request")
+		return
+	}
+
+	req := struct {
+		Email string `json:"email"`
+	}{}
+	err = json.Unmarshal(dat, &req)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "couldn't unmarshal request")
+		return
+	}
+
+	if req.Email == "" {
+		respondWithError(w, http.StatusBadRequest, "email is required")
+		return
+	}
+
+	// registered or not, so the throttle doesn't give them away
+	key := loginAccountKey(req.Email)
+	wait, ok := cfg.magicLinkThrottle.Allow(key)
+	if !ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		respondWithError(w, http.StatusTooManyRequests, "too many magic links requested, try again later")
+		return
+	}
+	cfg.magicLinkThrottle.Fail(key)
+
+	// done in the background so response times don't tell registered emails apart
+	go cfg.sendMagicLink(req.Email)
+
+	w.WriteHeader(http.StatusAccepted)
+	w.Write([]byte("Accepted"))
+}
+
+func (cfg *apiConfig) sendMagicLink(email string) {
+	user, err := cfg.db.GetUserByEmail(email)
+	if errors.Is(err, database.ErrNotExist) {
+		return
+	}
+	if err != nil {
+		log.Printf("magic link: couldn't get user: %s", err.Error())
+		return
+	}
+
+	token, jti, err := auth.CreateMagicLinkToken(user.ID, cfg.jwtSecret, int64(magicLinkTTL.Seconds()))
+	if err != nil {
+		log.Printf("magic link: couldn't create token: %s", err.Error())
+		return
+	}
+
+	err = cfg.db.CreateMagicLink(jti, user.ID, time.Now().Add(magicLinkTTL).Unix())
+	if err != nil {
+		log.Printf("magic link: couldn't write link to db: %s", err.Error())
+		return
+	}
+
+	err = cfg.mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Log in to Chirpy",
+		Body: fmt.Sprintf(
+			"Someone asked to log in to your Chirpy account without a password.\r\n\r\n"+
+				"Open this link within %v to log in, it only works once:\r\n\r\n"+
+				"%s/app/?magic_token=%s\r\n\r\n"+
+				"If it wasn't you, you can ignore this email.",
+			magicLinkTTL, cfg.baseURL, token,
+		),
+	})
+	if err != nil {
+		log.Printf("magic link: couldn't send email: %s", err.Error())
+	}
+}
+
+// redeems a magic link token for what /api/login responds with
+func (cfg *apiConfig) handlePostLoginMagicVerify(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	dat, err := io.ReadAll(r.Body)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "couldn't read request")
+		return
+	}
+
+	req := struct {
+		Token      string `json:"token"`
+		Exp        int    `json:"expires_in_seconds"`
+		UseCookies bool   `json:"use_cookies"`
+	}{}
+	err = json.Unmarshal(dat, &req)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "couldn't unmarshal request")
+		return
+	}
+
+	mToken, err := auth.ParseToken(req.Token, cfg.jwtSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid or expired magic link")
+		return
+	}
+
+	claims, ok := mToken.Claims.(*auth.Claims)
+	if !ok || claims.Issuer != "chirpy-magic-link" || claims.ID == "" {
+		respondWithError(w, http.StatusUnauthorized, "invalid or expired magic link")
+		return
+	}
+
+	uID, err := cfg.db.ConsumeMagicLink(claims.ID)
+	if err != nil || strconv.Itoa(uID) != claims.Subject {
+		respondWithError(w, http.StatusUnauthorized, "invalid or expired magic link")
+		return
+	}
+
+	user, err := cfg.db.GetUser(uID)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid or expired magic link")
+		return
+	}
+
+	cfg.magicLinkThrottle.Reset(loginAccountKey(user.Email))
+
+	// the link stands in for the password, 2FA still applies
+	cfg.respondWithLogin(w, user, req.Exp, req.UseCookies)
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/hatrnuhn/chirpy-webserver/internal/auth"
+	"github.com/hatrnuhn/chirpy-webserver/internal/mailer"
+)
+
+var magicTokenRe = regexp.MustCompile(`magic_token=(\S+)`)
+
+// requests a magic link for email and returns the token mailed as the nth email
+func requestMagicLink(t *testing.T, cfg *apiConfig, h http.Handler, email string, n int) string {
+	t.Helper()
+
+	w := doRequest(t, h, http.MethodPost, "/api/login/magic", "", map[string]string{"email": email})
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("got %d %q, want %d", w.Code, w.Body.String(), http.StatusAccepted)
+	}
+
+	msg := waitForMail(t, cfg, n)
+	if msg.To != email {
+		t.Fatalf("magic link was mailed to %s, want %s", msg.To, email)
+	}
+	m := magicTokenRe.FindStringSubmatch(msg.Body)
+	if m == nil {
+		t.Fatalf("no magic link in %q", msg.Body)
+	}
+
+	return m[1]
+}
+
+func TestMagicLinkLogin(t *testing.T) {
+	cfg := newTestConfig(t)
+	h := cfg.routes()
+	u := createTestUser(t, cfg, "walt@example.com")
+
+	// unknown emails are accepted just the same, nothing is sent for them
+	w := doRequest(t, h, http.MethodPost, "/api/login/magic", "", map[string]string{"email": "nobody@example.com"})
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("for an unknown email got %d %q, want %d", w.Code, w.Body.String(), http.StatusAccepted)
+	}
+
+	token := requestMagicLink(t, cfg, h, u.Email, 1)
+
+	w = doRequest(t, h, http.MethodPost, "/api/login/magic/verify", "", map[string]string{"token": token})
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d %q, want %d", w.Code, w.Body.String(), http.StatusOK)
+	}
+	l := testLogin{}
+	decodeBody(t, w, &l)
+	if l.AccessToken == "" || l.RefreshToken == "" {
+		t.Fatalf("got %q, want an AJWT and RJWT", w.Body.String())
+	}
+
+	w = doRequest(t, h, http.MethodGet, "/api/users/me", l.AccessToken, nil)
+	if w.Code != http.StatusOK {
+		t.Errorf("AJWT from the magic link got %d %q, want %d", w.Code, w.Body.String(), http.StatusOK)
+	}
+
+	w = doRequest(t, h, http.MethodPost, "/api/login/magic/verify", "", map[string]string{"token": token})
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("replaying the link got %d %q, want %d", w.Code, w.Body.String(), http.StatusUnauthorized)
+	}
+
+	if msgs := cfg.mailer.(*mailer.Outbox).Messages(); len(msgs) != 1 {
+		t.Errorf("got %d emails, want only the registered user's", len(msgs))
+	}
+}
+
+func TestMagicLinkMFA(t *testing.T) {
+	cfg := newTestConfig(t)
+	h := cfg.routes()
+	u := createTestUser(t, cfg, "walt@example.com")
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	u.TOTPSecret = secret
+	u.TOTPEnabled = true
+	_, err = cfg.db.UpdateUser(&u, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token := requestMagicLink(t, cfg, h, u.Email, 1)
+
+	w := doRequest(t, h, http.MethodPost, "/api/login/magic/verify", "", map[string]string{"token": token})
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d %q, want %d", w.Code, w.Body.String(), http.StatusOK)
+	}
+	l := testLogin{}
+	decodeBody(t, w, &l)
+	if !l.MFARequired || l.MFAToken == "" || l.AccessToken != "" {
+		t.Errorf("got %q, want an MFA challenge instead of tokens", w.Body.String())
+	}
+}
+
+func TestMagicLinkExpired(t *testing.T) {
+	cfg := newTestConfig(t)
+	h := cfg.routes()
+	u := createTestUser(t, cfg, "walt@example.com")
+
+	// expired as a JWT
+	token, _, err := auth.CreateMagicLinkToken(u.ID, cfg.jwtSecret, -60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := doRequest(t, h, http.MethodPost, "/api/login/magic/verify", "", map[string]string{"token": token})
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expired token got %d %q, want %d", w.Code, w.Body.String(), http.StatusUnauthorized)
+	}
+
+	// expired as it was recorded
+	token, jti, err := auth.CreateMagicLinkToken(u.ID, cfg.jwtSecret, 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cfg.db.CreateMagicLink(jti, u.ID, time.Now().Add(-time.Minute).Unix())
+	if err != nil {
+		t.Fatal(err)
+	}
+	w = doRequest(t, h, http.MethodPost, "/api/login/magic/verify", "", map[string]string{"token": token})
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expired link got %d %q, want %d", w.Code, w.Body.String(), http.StatusUnauthorized)
+	}
+}
+
+func TestMagicLinkThrottle(t *testing.T) {
+	cfg := newTestConfig(t)
+	h := cfg.routes()
+	u := createTestUser(t, cfg, "walt@example.com")
+
+	// newTestConfig backs off after the 3 free requests and the one past them
+	for i := 1; i <= 4; i++ {
+		requestMagicLink(t, cfg, h, u.Email, i)
+	}
+
+	w := doRequest(t, h, http.MethodPost, "/api/login/magic", "", map[string]string{"email": u.Email})
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("got %d %q, want %d", w.Code, w.Body.String(), http.StatusTooManyRequests)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("429 has no Retry-After")
+	}
+}
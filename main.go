@@ -1,15 +1,25 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/go-chi/chi"
+	"github.com/hatrnuhn/chirpy-webserver/internal/audit"
+	"github.com/hatrnuhn/chirpy-webserver/internal/auth"
+	"github.com/hatrnuhn/chirpy-webserver/internal/blobstore"
 	"github.com/hatrnuhn/chirpy-webserver/internal/database"
+	"github.com/hatrnuhn/chirpy-webserver/internal/mailer"
+	"github.com/hatrnuhn/chirpy-webserver/internal/moderation"
+	"github.com/hatrnuhn/chirpy-webserver/internal/oidc"
 	"github.com/joho/godotenv"
+	"golang.org/x/crypto/bcrypt"
 )
 
 type apiConfig struct {
@@ -17,6 +27,29 @@ type apiConfig struct {
 	jwtSecret      string
 	db             *database.DB
 	polka          map[string]any
+	mailer         mailer.Mailer
+	baseURL        string
+	passwords      auth.PasswordHasher
+	passwordPolicy auth.PasswordPolicy
+	// hash of a random password, compared against when a login email isn't registered
+	dummyHash string
+	// failed logins per account and per client IP
+	accountThrottle *auth.LoginThrottle
+	ipThrottle      *auth.LoginThrottle
+	// magic links requested per email
+	magicLinkThrottle *auth.LoginThrottle
+	// password resets requested per email
+	passwordResetThrottle *auth.LoginThrottle
+	// external OpenID Connect provider users can sign in with, nil if there's none
+	oidc       *oidc.Provider
+	oidcLogins *oidcLogins
+	// where data export archives are written
+	exportDir string
+	audit     *audit.Logger
+	// where uploaded media are stored
+	blobs blobstore.BlobStore
+	// decides whether new chirps are published
+	moderation *moderation.Pipeline
 }
 
 func main() {
@@ -29,21 +62,47 @@ func main() {
 		deleteDB()
 	}
 
-	rChi := chi.NewRouter()
-	rAPI := chi.NewRouter()
-	rAdmin := chi.NewRouter()
-
-	corsSrvMux := middlewareCors(rChi)
-
 	s := &http.Server{
-		Addr:    "localhost:8080",
-		Handler: corsSrvMux,
+		Addr: "localhost:8080",
 	}
 
 	apiCfg := apiConfig{
 		fileserverHits: 0,
 		jwtSecret:      jwtSecret,
 		polka:          make(map[string]any),
+		oidcLogins:     newOIDCLogins(),
+		baseURL:        "http://" + s.Addr,
+		accountThrottle: auth.NewLoginThrottle(auth.ThrottleConfig{
+			FreeAttempts:    3,
+			BaseDelay:       time.Second,
+			MaxDelay:        5 * time.Minute,
+			LockoutAfter:    10,
+			LockoutDuration: 15 * time.Minute,
+			ForgetAfter:     24 * time.Hour,
+		}),
+		// many users can share an IP, so it gets more slack before backing off
+		ipThrottle: auth.NewLoginThrottle(auth.ThrottleConfig{
+			FreeAttempts:    20,
+			BaseDelay:       time.Second,
+			MaxDelay:        5 * time.Minute,
+			LockoutAfter:    100,
+			LockoutDuration: 15 * time.Minute,
+			ForgetAfter:     24 * time.Hour,
+		}),
+		// every request counts, a few links go out right away and then they get further apart
+		magicLinkThrottle: auth.NewLoginThrottle(auth.ThrottleConfig{
+			FreeAttempts: 3,
+			BaseDelay:    time.Minute,
+			MaxDelay:     time.Hour,
+			ForgetAfter:  time.Hour,
+		}),
+		// same as magic links, reset emails can't be used to flood an inbox either
+		passwordResetThrottle: auth.NewLoginThrottle(auth.ThrottleConfig{
+			FreeAttempts: 3,
+			BaseDelay:    time.Minute,
+			MaxDelay:     time.Hour,
+			ForgetAfter:  time.Hour,
+		}),
 	}
 	apiCfg.polka["polkakey"] = os.Getenv("POLKA_KEY")
 
@@ -53,33 +112,198 @@ func main() {
 		log.Fatal("couldn't initialize database")
 	}
 
-	fsHandler := apiCfg.middlewareMetricsInc(http.StripPrefix("/app", http.FileServer(http.Dir("."))))
+	apiCfg.passwords, err = newPasswordHasher()
+	if err != nil {
+		log.Fatalf("couldn't configure password hashing: %s", err.Error())
+	}
+	apiCfg.db.SetPasswordHasher(apiCfg.passwords)
+
+	// chirps of authors with more followers are looked up when timelines are read instead
+	if v := os.Getenv("TIMELINE_FANOUT_LIMIT"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			log.Fatalf("invalid TIMELINE_FANOUT_LIMIT: %s", v)
+		}
+		apiCfg.db.SetFanOutLimit(n)
+	}
+
+	apiCfg.passwordPolicy, err = newPasswordPolicy()
+	if err != nil {
+		log.Fatalf("couldn't configure password policy: %s", err.Error())
+	}
+
+	apiCfg.dummyHash, err = apiCfg.passwords.Hash("chirpy-dummy-password")
+	if err != nil {
+		log.Fatalf("couldn't hash dummy password: %s", err.Error())
+	}
+
+	if email := os.Getenv("ADMIN_EMAIL"); email != "" {
+		err = bootstrapAdmin(apiCfg.db, email, os.Getenv("ADMIN_PASSWORD"))
+		if err != nil {
+			log.Fatalf("couldn't bootstrap admin: %s", err.Error())
+		}
+	}
+
+	if baseURL := os.Getenv("BASE_URL"); baseURL != "" {
+		apiCfg.baseURL = baseURL
+	}
+
+	apiCfg.mailer, err = newMailer()
+	if err != nil {
+		log.Fatalf("couldn't initialize mailer: %s", err.Error())
+	}
+
+	auditPath := os.Getenv("AUDIT_LOG")
+	if auditPath == "" {
+		auditPath = "audit.log"
+	}
+	apiCfg.audit, err = audit.NewFileLogger(auditPath)
+	if err != nil {
+		log.Fatalf("couldn't open audit log: %s", err.Error())
+	}
+
+	apiCfg.exportDir = os.Getenv("EXPORT_DIR")
+	if apiCfg.exportDir == "" {
+		apiCfg.exportDir = "exports"
+	}
+	err = os.MkdirAll(apiCfg.exportDir, 0700)
+	if err != nil {
+		log.Fatalf("couldn't create export directory: %s", err.Error())
+	}
+
+	mediaDir := os.Getenv("MEDIA_DIR")
+	if mediaDir == "" {
+		mediaDir = "media"
+	}
+	apiCfg.blobs, err = blobstore.NewDisk(mediaDir)
+	if err != nil {
+		log.Fatalf("couldn't create media directory: %s", err.Error())
+	}
+
+	apiCfg.moderation, err = newModerationPipeline(apiCfg.db)
+	if err != nil {
+		log.Fatalf("couldn't configure moderation: %s", err.Error())
+	}
+
+	apiCfg.oidc, err = newOIDCProvider(apiCfg.baseURL)
+	if err != nil {
+		log.Fatalf("couldn't configure OIDC login: %s", err.Error())
+	}
+
+	s.Handler = middlewareCors(apiCfg.routes())
+
+	fmt.Printf("Starting server at http://%v\n", s.Addr)
+	s.ListenAndServe()
+}
+
+// builds the router serving the app, /api and /admin
+func (cfg *apiConfig) routes() http.Handler {
+	rChi := chi.NewRouter()
+	rAPI := chi.NewRouter()
+	rAdmin := chi.NewRouter()
+
+	rChi.Use(cfg.middlewareSessionCookies)
+	rChi.Use(cfg.middlewareAuditImpersonation)
+
+	fsHandler := cfg.middlewareMetricsInc(http.StripPrefix("/app", http.FileServer(http.Dir("."))))
 	rChi.Handle("/app/*", fsHandler)
 	rChi.Handle("/app", fsHandler)
 
 	rAPI.Get("/healthz", handleHealthz)
 
-	rAPI.HandleFunc("/reset", apiCfg.handleReset)
+	rAPI.With(cfg.middlewareRequireRole(auth.RoleAdmin)).HandleFunc("/reset", cfg.handleReset)
 
-	rAPI.Post("/chirps", apiCfg.handlePostChirps)
-	rAPI.Get("/chirps", apiCfg.handleGetChirps)
-	rAPI.Post("/users", apiCfg.handlePostUsers)
-	rAPI.Put("/users", apiCfg.handlePutUsers)
-	rAPI.Get("/chirps/{chirpID}", apiCfg.handleChirpID)
-	rAPI.Delete("/chirps/{chirpID}", apiCfg.handleDelChirpID)
-	rAPI.Post("/login", apiCfg.handlePostLogin)
-	rAPI.Post("/refresh", apiCfg.handlePostRefresh)
-	rAPI.Post("/revoke", apiCfg.handlePostRevoke)
-	rAPI.Post("/polka/webhooks", apiCfg.handlePostPolkaWebhooks)
+	rAPI.Post("/chirps", cfg.handlePostChirps)
+	rAPI.Get("/chirps", cfg.handleGetChirps)
+	rAPI.Post("/users", cfg.handlePostUsers)
+	rAPI.Put("/users", cfg.handlePutUsers)
+	rAPI.Get("/users/me", cfg.handleGetUsersMe)
+	rAPI.Delete("/users/me", cfg.handleDelUsersMe)
+	rAPI.Post("/users/me/export", cfg.handlePostUsersMeExport)
+	rAPI.Get("/users/me/export/{exportID}", cfg.handleGetUsersMeExport)
+	rAPI.Get("/users/me/export/{exportID}/download", cfg.handleGetUsersMeExportDownload)
+	rAPI.Post("/users/me/2fa", cfg.handlePostUsersMe2FA)
+	rAPI.Post("/users/me/2fa/confirm", cfg.handlePostUsersMe2FAConfirm)
+	rAPI.Post("/users/me/tokens", cfg.handlePostUsersMeTokens)
+	rAPI.Get("/users/me/tokens", cfg.handleGetUsersMeTokens)
+	rAPI.Delete("/users/me/tokens/{tokenID}", cfg.handleDelUsersMeTokenID)
+	rAPI.Get("/chirps/{chirpID}", cfg.handleChirpID)
+	rAPI.Get("/chirps/{chirpID}/thread", cfg.handleGetChirpThread)
+	rAPI.Post("/chirps/{chirpID}/likes", cfg.handlePostChirpLikes)
+	rAPI.Delete("/chirps/{chirpID}/likes", cfg.handleDelChirpLikes)
+	rAPI.Get("/chirps/{chirpID}/likes", cfg.handleGetChirpLikes)
+	rAPI.Post("/chirps/{chirpID}/reports", cfg.handlePostChirpReports)
+	rAPI.Get("/users/{userID}/likes", cfg.handleGetUserLikes)
+	rAPI.Post("/users/{userID}/follow", cfg.handlePostUserFollow)
+	rAPI.Delete("/users/{userID}/follow", cfg.handleDelUserFollow)
+	rAPI.Get("/users/{userID}/followers", cfg.handleGetUserFollowers)
+	rAPI.Get("/users/{userID}/following", cfg.handleGetUserFollowing)
+	rAPI.Post("/users/{userID}/reports", cfg.handlePostUserReports)
+	rAPI.Post("/users/{userID}/block", cfg.handlePostUserBlock)
+	rAPI.Delete("/users/{userID}/block", cfg.handleDelUserBlock)
+	rAPI.Post("/users/{userID}/mute", cfg.handlePostUserMute)
+	rAPI.Delete("/users/{userID}/mute", cfg.handleDelUserMute)
+	rAPI.Get("/users/me/blocks", cfg.handleGetUsersMeBlocks)
+	rAPI.Get("/users/me/mutes", cfg.handleGetUsersMeMutes)
+	rAPI.Get("/users/me/mutes/keywords", cfg.handleGetUsersMeMutedKeywords)
+	rAPI.Put("/users/me/mutes/keywords", cfg.handlePutUsersMeMutedKeywords)
+	rAPI.Get("/timeline/home", cfg.handleGetTimelineHome)
+	rAPI.Get("/notifications", cfg.handleGetNotifications)
+	rAPI.Post("/notifications/read", cfg.handlePostNotificationsRead)
+	rAPI.Get("/notifications/settings", cfg.handleGetNotificationSettings)
+	rAPI.Put("/notifications/settings", cfg.handlePutNotificationSettings)
+	rAPI.Post("/media", cfg.handlePostMedia)
+	rAPI.Get("/media/{mediaID}", cfg.handleGetMedia)
+	rAPI.Get("/media/{mediaID}/thumbnail", cfg.handleGetMediaThumbnail)
+	rAPI.Get("/hashtags/{tag}/chirps", cfg.handleGetHashtagChirps)
+	rAPI.Get("/trends", cfg.handleGetTrends)
+	rAPI.Delete("/chirps/{chirpID}", cfg.handleDelChirpID)
+	rAPI.Post("/login", cfg.handlePostLogin)
+	rAPI.Post("/login/mfa", cfg.handlePostLoginMFA)
+	rAPI.Post("/login/magic", cfg.handlePostLoginMagic)
+	rAPI.Post("/login/magic/verify", cfg.handlePostLoginMagicVerify)
+	rAPI.Post("/refresh", cfg.handlePostRefresh)
+	rAPI.Post("/revoke", cfg.handlePostRevoke)
+	rAPI.Post("/logout", cfg.handlePostLogout)
+	rAPI.Post("/logout/all", cfg.handlePostLogoutAll)
+	rAPI.Post("/polka/webhooks", cfg.handlePostPolkaWebhooks)
+	rAPI.Post("/password/forgot", cfg.handlePostPasswordForgot)
+	rAPI.Post("/password/reset", cfg.handlePostPasswordReset)
+	rAPI.Post("/oauth/clients", cfg.handlePostOAuthClients)
+	rAPI.Get("/oauth/clients", cfg.handleGetOAuthClients)
+	rAPI.Delete("/oauth/clients/{clientID}", cfg.handleDelOAuthClientID)
+	rAPI.Get("/oauth/authorize", cfg.handleGetOAuthAuthorize)
+	rAPI.Post("/oauth/authorize", cfg.handlePostOAuthAuthorize)
+	rAPI.Post("/oauth/token", cfg.handlePostOAuthToken)
+	rAPI.Post("/oauth/introspect", cfg.handlePostOAuthIntrospect)
+	rAPI.Post("/oauth/revoke", cfg.handlePostOAuthRevoke)
+	rAPI.Get("/oidc/login", cfg.handleGetOIDCLogin)
+	rAPI.Get("/oidc/callback", cfg.handleGetOIDCCallback)
 
-	rAdmin.Get("/metrics", apiCfg.handleMetrics)
+	rAdmin.Group(func(r chi.Router) {
+		r.Use(cfg.middlewareRequireRole(auth.RoleAdmin))
+		r.Get("/metrics", cfg.handleMetrics)
+		r.Put("/users/{userID}/role", cfg.handlePutAdminUserRole)
+		r.Post("/users/{userID}/unlock", cfg.handlePostAdminUserUnlock)
+		r.Post("/users/{userID}/impersonate", cfg.handlePostAdminUserImpersonate)
+	})
+
+	// moderators can work through the moderation queues, admins can too
+	rAdmin.Group(func(r chi.Router) {
+		r.Use(cfg.middlewareRequireRole(auth.RoleModerator))
+		r.Get("/moderation/held", cfg.handleGetModerationHeld)
+		r.Post("/moderation/held/{heldID}/approve", cfg.handlePostModerationHeldApprove)
+		r.Post("/moderation/held/{heldID}/reject", cfg.handlePostModerationHeldReject)
+		r.Get("/reports", cfg.handleGetAdminReports)
+		r.Post("/reports/{reportID}/claim", cfg.handlePostAdminReportClaim)
+		r.Post("/reports/{reportID}/resolve", cfg.handlePostAdminReportResolve)
+	})
 
 	// mount namespaces routers to /api
 	rChi.Mount("/api", rAPI)
 	rChi.Mount("/admin", rAdmin)
 
-	fmt.Printf("Starting server at http://%v\n", s.Addr)
-	s.ListenAndServe()
+	return rChi
 }
 
 func middlewareCors(next http.Handler) http.Handler {
@@ -95,6 +319,103 @@ func middlewareCors(next http.Handler) http.Handler {
 	})
 }
 
+// hashes new passwords with PASSWORD_HASH (argon2id or bcrypt, argon2id by default) and the
+// ARGON2_MEMORY (KiB), ARGON2_TIME, ARGON2_THREADS or BCRYPT_COST parameters, hashes of either algorithm are verified
+func newPasswordHasher() (auth.PasswordHasher, error) {
+	argon := auth.DefaultArgon2id
+	bc := auth.BcryptHasher{Cost: bcrypt.DefaultCost}
+
+	envs := []struct {
+		name string
+		dst  any
+	}{
+		{name: "ARGON2_MEMORY", dst: &argon.Memory},
+		{name: "ARGON2_TIME", dst: &argon.Time},
+		{name: "ARGON2_THREADS", dst: &argon.Threads},
+		{name: "BCRYPT_COST", dst: &bc.Cost},
+	}
+	for _, e := range envs {
+		v := os.Getenv(e.name)
+		if v == "" {
+			continue
+		}
+
+		_, err := fmt.Sscan(v, e.dst)
+		if err != nil {
+			return auth.PasswordHasher{}, fmt.Errorf("invalid %s: %s", e.name, v)
+		}
+	}
+
+	switch alg := os.Getenv("PASSWORD_HASH"); alg {
+	case "", "argon2id":
+		if argon.Time < 1 || argon.Threads < 1 || argon.Memory < 8*uint32(argon.Threads) {
+			return auth.PasswordHasher{}, errors.New("ARGON2_TIME and ARGON2_THREADS must be at least 1 and ARGON2_MEMORY at least 8 KiB per thread")
+		}
+		return auth.PasswordHasher{Current: argon, Known: []auth.Hasher{bc}}, nil
+	case "bcrypt":
+		if bc.Cost < bcrypt.MinCost || bc.Cost > bcrypt.MaxCost {
+			return auth.PasswordHasher{}, fmt.Errorf("BCRYPT_COST must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+		}
+		return auth.PasswordHasher{Current: bc, Known: []auth.Hasher{argon}}, nil
+	default:
+		return auth.PasswordHasher{}, fmt.Errorf("unknown PASSWORD_HASH: %s", alg)
+	}
+}
+
+// requires PASSWORD_MIN_LENGTH (8 by default) characters and a PASSWORD_MIN_SCORE (2 by default) strength
+// estimate, and checks passwords against the BREACHED_PASSWORDS_FILE list of SHA-1 hashes if it's set
+func newPasswordPolicy() (auth.PasswordPolicy, error) {
+	policy := auth.PasswordPolicy{
+		MinLength:     8,
+		MinScore:      2,
+		DisallowEmail: true,
+	}
+
+	envs := []struct {
+		name string
+		dst  *int
+	}{
+		{name: "PASSWORD_MIN_LENGTH", dst: &policy.MinLength},
+		{name: "PASSWORD_MIN_SCORE", dst: &policy.MinScore},
+	}
+	for _, e := range envs {
+		v := os.Getenv(e.name)
+		if v == "" {
+			continue
+		}
+
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return auth.PasswordPolicy{}, fmt.Errorf("invalid %s: %s", e.name, v)
+		}
+		*e.dst = n
+	}
+
+	if path := os.Getenv("BREACHED_PASSWORDS_FILE"); path != "" {
+		bl, err := auth.LoadBreachedList(path)
+		if err != nil {
+			return auth.PasswordPolicy{}, err
+		}
+		policy.Breached = bl
+	}
+
+	return policy, nil
+}
+
+// relays mail through SMTP_ADDR if it's set, otherwise drops it in the local MAIL_OUTBOX directory
+func newMailer() (mailer.Mailer, error) {
+	if addr := os.Getenv("SMTP_ADDR"); addr != "" {
+		return mailer.NewSMTP(addr, os.Getenv("SMTP_FROM"), os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD"))
+	}
+
+	dir := os.Getenv("MAIL_OUTBOX")
+	if dir == "" {
+		dir = "outbox"
+	}
+
+	return mailer.NewOutbox(dir)
+}
+
 func deleteDB() {
 	err := os.Remove("internal/database/database.json")
 
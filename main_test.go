@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hatrnuhn/chirpy-webserver/internal/audit"
+	"github.com/hatrnuhn/chirpy-webserver/internal/auth"
+	"github.com/hatrnuhn/chirpy-webserver/internal/blobstore"
+	"github.com/hatrnuhn/chirpy-webserver/internal/database"
+	"github.com/hatrnuhn/chirpy-webserver/internal/mailer"
+	"github.com/hatrnuhn/chirpy-webserver/internal/moderation"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// satisfies the password policy newTestConfig sets up
+const testPassword = "we1rd-Chirps-at-noon"
+
+// returns an apiConfig backed by a fresh database and directories under a temporary directory,
+// with cheap password hashing so tests stay fast
+func newTestConfig(t *testing.T) *apiConfig {
+	t.Helper()
+	dir := t.TempDir()
+
+	db, err := database.NewDB(filepath.Join(dir, "db.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hasher := auth.PasswordHasher{Current: auth.BcryptHasher{Cost: bcrypt.MinCost}}
+	db.SetPasswordHasher(hasher)
+
+	outbox, err := mailer.NewOutbox(filepath.Join(dir, "outbox"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blobs, err := blobstore.NewDisk(filepath.Join(dir, "media"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exportDir := filepath.Join(dir, "exports")
+	err = os.MkdirAll(exportDir, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	throttle := auth.ThrottleConfig{
+		FreeAttempts: 3,
+		BaseDelay:    time.Minute,
+		MaxDelay:     time.Hour,
+		ForgetAfter:  time.Hour,
+	}
+
+	cfg := &apiConfig{
+		jwtSecret:             "test-secret",
+		db:                    db,
+		polka:                 map[string]any{"polkakey": "test-polka-key"},
+		mailer:                outbox,
+		baseURL:               "http://chirpy.test",
+		passwords:             hasher,
+		passwordPolicy:        auth.PasswordPolicy{MinLength: 8, DisallowEmail: true},
+		accountThrottle:       auth.NewLoginThrottle(throttle),
+		ipThrottle:            auth.NewLoginThrottle(throttle),
+		magicLinkThrottle:     auth.NewLoginThrottle(throttle),
+		passwordResetThrottle: auth.NewLoginThrottle(throttle),
+		oidcLogins:            newOIDCLogins(),
+		exportDir:             exportDir,
+		audit:                 audit.NewLogger(io.Discard),
+		blobs:                 blobs,
+		moderation:            moderation.NewPipeline(),
+	}
+
+	cfg.dummyHash, err = hasher.Hash("chirpy-dummy-password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cfg
+}
+
+// creates a user with testPassword
+func createTestUser(t *testing.T, cfg *apiConfig, email string) database.User {
+	t.Helper()
+
+	u, err := cfg.db.CreateUser(`{"email":"` + email + `","password":"` + testPassword + `"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return u
+}
+
+// returns a first-party AJWT for the user as they're stored right now
+func testAccessToken(t *testing.T, cfg *apiConfig, uID int) string {
+	t.Helper()
+
+	u, err := cfg.db.GetUser(uID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := auth.CreateAccessToken(u.ID, u.Role, auth.PlanOf(u.IsChirpyRed), u.TokenGeneration, cfg.jwtSecret, 3600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return token
+}
+
+// sends a request to the router with token as its bearer token if it's set and body encoded as JSON
+// if it isn't nil
+func doRequest(t *testing.T, h http.Handler, method, path, token string, body any) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var r io.Reader
+	if body != nil {
+		dat, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = bytes.NewReader(dat)
+	}
+
+	req := httptest.NewRequest(method, path, r)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+// sends a form-encoded POST request to the router
+func postForm(t *testing.T, h http.Handler, path string, v url.Values) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(v.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+// decodes the JSON response body into v
+func decodeBody(t *testing.T, w *httptest.ResponseRecorder, v any) {
+	t.Helper()
+
+	err := json.Unmarshal(w.Body.Bytes(), v)
+	if err != nil {
+		t.Fatalf("couldn't decode %q: %s", w.Body.String(), err)
+	}
+}
+
+// stores a personal access token for the user with scopes and returns it
+func testPAT(t *testing.T, cfg *apiConfig, uID int, scopes ...string) string {
+	t.Helper()
+
+	token, err := auth.CreatePersonalAccessToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = cfg.db.CreatePersonalAccessToken(database.PersonalAccessToken{
+		UserID:    uID,
+		Name:      "test",
+		Scopes:    scopes,
+		Hash:      auth.HashToken(token),
+		CreatedAt: time.Now().Unix(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return token
+}
+
+// returns an AJWT issued to a third-party client on the user's behalf with scopes
+func testOAuthToken(t *testing.T, cfg *apiConfig, uID int, scopes ...string) string {
+	t.Helper()
+
+	u, err := cfg.db.GetUser(uID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := auth.CreateOAuthAccessToken(u.ID, u.Role, auth.PlanOf(u.IsChirpyRed), u.TokenGeneration, "test-client", scopes, cfg.jwtSecret, 3600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return token
+}
+
+// returns an AJWT for the user that actorID impersonates them with
+func testImpersonationToken(t *testing.T, cfg *apiConfig, uID, actorID int) string {
+	t.Helper()
+
+	u, err := cfg.db.GetUser(uID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, _, err := auth.CreateImpersonationToken(u.ID, u.Role, auth.PlanOf(u.IsChirpyRed), u.TokenGeneration, actorID, cfg.jwtSecret, 3600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return token
+}
+
+// gives the user role
+func setTestRole(t *testing.T, cfg *apiConfig, uID int, role string) {
+	t.Helper()
+
+	u, err := cfg.db.GetUser(uID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u.Role = role
+	_, err = cfg.db.UpdateUser(&u, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+type testLogin struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	MFARequired  bool   `json:"mfa_required"`
+	MFAToken     string `json:"mfa_token"`
+}
+
+// logs in through /api/login with testPassword
+func loginTestUser(t *testing.T, h http.Handler, email string) testLogin {
+	t.Helper()
+
+	w := doRequest(t, h, http.MethodPost, "/api/login", "", map[string]string{"email": email, "password": testPassword})
+	if w.Code != http.StatusOK {
+		t.Fatalf("couldn't log in: %d %s", w.Code, w.Body.String())
+	}
+
+	l := testLogin{}
+	decodeBody(t, w, &l)
+	return l
+}
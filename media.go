@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/hatrnuhn/chirpy-webserver/internal/auth"
+	"github.com/hatrnuhn/chirpy-webserver/internal/blobstore"
+	"github.com/hatrnuhn/chirpy-webserver/internal/database"
+	"github.com/hatrnuhn/chirpy-webserver/internal/media"
+)
+
+// uploads can't be any larger than 5 MiB
+const maxMediaSize = 5 << 20
+
+var mediaExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+type mediaResponse struct {
+	ID           string `json:"id"`
+	URL          string `json:"url"`
+	ThumbnailURL string `json:"thumbnail_url"`
+	ContentType  string `json:"content_type"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+}
+
+func (cfg *apiConfig) newMediaResponse(m database.Media) mediaResponse {
+	url := fmt.Sprintf("%s/api/media/%s", cfg.baseURL, m.ID)
+	return mediaResponse{
+		ID:           m.ID,
+		URL:          url,
+		ThumbnailURL: url + "/thumbnail",
+		ContentType:  m.ContentType,
+		Width:        m.Width,
+		Height:       m.Height,
+	}
+}
+
+// accepts an image in the file field of a multipart form, stores it without its metadata along with a
+// thumbnail, and responds with the media ID to attach it to a chirp with
+func (cfg *apiConfig) handlePostMedia(w http.ResponseWriter, r *http.Request) {
+	p, err := cfg.authenticate(r, auth.ScopeChirpsWrite)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	// leaves room for the rest of the form
+	r.Body = http.MaxBytesReader(w, r.Body, maxMediaSize+1<<20)
+	err = r.ParseMultipartForm(maxMediaSize)
+	if err != nil {
+		respondWithError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("couldn't read upload, it can't be larger than %d MiB", maxMediaSize>>20))
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	f, _, err := r.FormFile("file")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "upload needs a file field")
+		return
+	}
+	defer f.Close()
+
+	dat, err := io.ReadAll(io.LimitReader(f, maxMediaSize+1))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "couldn't read upload")
+		return
+	}
+	if len(dat) > maxMediaSize {
+		respondWithError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("upload can't be larger than %d MiB", maxMediaSize>>20))
+		return
+	}
+
+	img, err := media.Process(dat)
+	if errors.Is(err, media.ErrUnsupported) {
+		respondWithError(w, http.StatusUnsupportedMediaType, err.Error())
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	id, err := auth.MakeRandomToken(16)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't create media id")
+		return
+	}
+
+	m := database.Media{
+		ID:            id,
+		UserID:        p.UserID,
+		ContentType:   img.ContentType,
+		ThumbnailType: img.ThumbnailType,
+		Width:         img.Width,
+		Height:        img.Height,
+		Key:           id + mediaExtensions[img.ContentType],
+		ThumbnailKey:  id + "-thumb" + mediaExtensions[img.ThumbnailType],
+		CreatedAt:     time.Now().Unix(),
+	}
+
+	err = cfg.blobs.Put(r.Context(), m.Key, bytes.NewReader(img.Data))
+	if err == nil {
+		err = cfg.blobs.Put(r.Context(), m.ThumbnailKey, bytes.NewReader(img.Thumbnail))
+	}
+	if err == nil {
+		err = cfg.db.CreateMedia(m)
+	}
+	if err != nil {
+		cfg.removeMediaBlobs(r.Context(), []database.Media{m})
+		respondWithError(w, http.StatusInternalServerError, "couldn't store media")
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, cfg.newMediaResponse(m))
+}
+
+func (cfg *apiConfig) handleGetMedia(w http.ResponseWriter, r *http.Request) {
+	cfg.serveMedia(w, r, false)
+}
+
+func (cfg *apiConfig) handleGetMediaThumbnail(w http.ResponseWriter, r *http.Request) {
+	cfg.serveMedia(w, r, true)
+}
+
+// responds with the media in the URL or its thumbnail, media never change so they can be cached for good
+func (cfg *apiConfig) serveMedia(w http.ResponseWriter, r *http.Request, thumbnail bool) {
+	id := chi.URLParam(r, "mediaID")
+	m, err := cfg.db.GetMedia(id)
+	if errors.Is(err, database.ErrNotExist) {
+		respondWithError(w, http.StatusNotFound, "media is not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't get media")
+		return
+	}
+
+	key, ct := m.Key, m.ContentType
+	if thumbnail {
+		key, ct = m.ThumbnailKey, m.ThumbnailType
+	}
+
+	rc, err := cfg.blobs.Get(r.Context(), key)
+	if errors.Is(err, blobstore.ErrNotExist) {
+		respondWithError(w, http.StatusNotFound, "media is not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't get media")
+		return
+	}
+	defer rc.Close()
+
+	w.Header().Set("Content-Type", ct)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.WriteHeader(http.StatusOK)
+	io.Copy(w, rc)
+}
+
+// deletes the blobs of removed media, failing to only leaves unreachable blobs behind
+func (cfg *apiConfig) removeMediaBlobs(ctx context.Context, media []database.Media) {
+	for _, m := range media {
+		for _, key := range []string{m.Key, m.ThumbnailKey} {
+			err := cfg.blobs.Delete(ctx, key)
+			if err != nil {
+				log.Printf("couldn't remove blob of media %s: %s", m.ID, err.Error())
+			}
+		}
+	}
+}
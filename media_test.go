@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hatrnuhn/chirpy-webserver/internal/blobstore"
+	"github.com/hatrnuhn/chirpy-webserver/internal/database"
+)
+
+// returns a PNG of the given size
+func testPNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 200, A: 255})
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	err := png.Encode(buf, img)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+// uploads dat as the file field of a multipart form to /api/media
+func uploadMedia(t *testing.T, h http.Handler, token string, dat []byte) *httptest.ResponseRecorder {
+	t.Helper()
+
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	fw, err := mw.CreateFormFile("file", "upload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write(dat)
+	mw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/media", body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+// uploads a small PNG and returns what it responded with
+func uploadTestMedia(t *testing.T, h http.Handler, token string) mediaResponse {
+	t.Helper()
+
+	w := uploadMedia(t, h, token, testPNG(t, 64, 48))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("upload got %d %q, want %d", w.Code, w.Body.String(), http.StatusCreated)
+	}
+
+	m := mediaResponse{}
+	decodeBody(t, w, &m)
+	return m
+}
+
+func TestPostMedia(t *testing.T) {
+	cfg := newTestConfig(t)
+	h := cfg.routes()
+	u := createTestUser(t, cfg, "walt@example.com")
+	token := testAccessToken(t, cfg, u.ID)
+
+	m := uploadTestMedia(t, h, token)
+	if m.ID == "" || m.ContentType != "image/png" || m.Width != 64 || m.Height != 48 {
+		t.Errorf("got %+v, want a 64x48 PNG", m)
+	}
+	if m.URL != cfg.baseURL+"/api/media/"+m.ID || m.ThumbnailURL != m.URL+"/thumbnail" {
+		t.Errorf("got URLs %s and %s", m.URL, m.ThumbnailURL)
+	}
+
+	for _, path := range []string{"/api/media/" + m.ID, "/api/media/" + m.ID + "/thumbnail"} {
+		w := doRequest(t, h, http.MethodGet, path, "", nil)
+		if w.Code != http.StatusOK || w.Body.Len() == 0 {
+			t.Fatalf("%s: got %d with %d bytes, want the image", path, w.Code, w.Body.Len())
+		}
+		if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "image/") {
+			t.Errorf("%s: got content type %s, want an image", path, ct)
+		}
+	}
+
+	w := doRequest(t, h, http.MethodGet, "/api/media/nope/thumbnail", "", nil)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("missing media got %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestPostMediaRejected(t *testing.T) {
+	cfg := newTestConfig(t)
+	h := cfg.routes()
+	u := createTestUser(t, cfg, "walt@example.com")
+	token := testAccessToken(t, cfg, u.ID)
+
+	tests := []struct {
+		name string
+		dat  []byte
+		want int
+	}{
+		{"not an image", []byte("#!/bin/sh\necho hi\n"), http.StatusUnsupportedMediaType},
+		{"too large", bytes.Repeat([]byte{0}, maxMediaSize+1), http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := uploadMedia(t, h, token, tc.dat)
+			if w.Code != tc.want {
+				t.Fatalf("got %d %q, want %d", w.Code, w.Body.String(), tc.want)
+			}
+		})
+	}
+}
+
+func TestChirpMedia(t *testing.T) {
+	cfg := newTestConfig(t)
+	h := cfg.routes()
+	u := createTestUser(t, cfg, "walt@example.com")
+	other := createTestUser(t, cfg, "jesse@example.com")
+	token := testAccessToken(t, cfg, u.ID)
+
+	ids := make([]string, 0, database.MaxChirpMedia+1)
+	for i := 0; i <= database.MaxChirpMedia; i++ {
+		ids = append(ids, uploadTestMedia(t, h, token).ID)
+	}
+	othersID := uploadTestMedia(t, h, testAccessToken(t, cfg, other.ID)).ID
+
+	post := func(mediaIDs []string) *httptest.ResponseRecorder {
+		return doRequest(t, h, http.MethodPost, "/api/chirps", token, map[string]any{"body": "look", "media_ids": mediaIDs})
+	}
+
+	w := post(ids)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("%d media got %d %q, want %d", len(ids), w.Code, w.Body.String(), http.StatusBadRequest)
+	}
+	w = post([]string{othersID})
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("another user's media got %d %q, want %d", w.Code, w.Body.String(), http.StatusBadRequest)
+	}
+
+	w = post(ids[:2])
+	if w.Code != http.StatusCreated {
+		t.Fatalf("got %d %q, want %d", w.Code, w.Body.String(), http.StatusCreated)
+	}
+	c := chirpResponse{}
+	decodeBody(t, w, &c)
+	if len(c.Media) != 2 || c.Media[0].ID != ids[0] || c.Media[1].URL != cfg.baseURL+"/api/media/"+ids[1] {
+		t.Fatalf("got media %+v, want %v", c.Media, ids[:2])
+	}
+
+	w = post(ids[1:3])
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("already attached media got %d %q, want %d", w.Code, w.Body.String(), http.StatusBadRequest)
+	}
+
+	w = doRequest(t, h, http.MethodGet, fmt.Sprintf("/api/chirps/%d", c.ID), "", nil)
+	got := chirpResponse{}
+	decodeBody(t, w, &got)
+	if len(got.Media) != 2 {
+		t.Errorf("GET got media %+v, want 2", got.Media)
+	}
+
+	attached := make([]database.Media, 0, 2)
+	for _, id := range ids[:2] {
+		m, err := cfg.db.GetMedia(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		attached = append(attached, m)
+	}
+
+	w = doRequest(t, h, http.MethodDelete, fmt.Sprintf("/api/chirps/%d", c.ID), token, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("delete got %d %q", w.Code, w.Body.String())
+	}
+
+	for _, m := range attached {
+		for _, key := range []string{m.Key, m.ThumbnailKey} {
+			rc, err := cfg.blobs.Get(context.Background(), key)
+			if err == nil {
+				rc.Close()
+			}
+			if !errors.Is(err, blobstore.ErrNotExist) {
+				t.Errorf("blob %s of the deleted chirp got %v, want it gone", key, err)
+			}
+		}
+		w = doRequest(t, h, http.MethodGet, "/api/media/"+m.ID, "", nil)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("media %s of the deleted chirp got %d, want %d", m.ID, w.Code, http.StatusNotFound)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/hatrnuhn/chirpy-webserver/internal/database"
+	"github.com/hatrnuhn/chirpy-webserver/internal/moderation"
+)
+
+// builds the moderation pipeline from the MODERATION_CONFIG file, every chirp is allowed if it isn't set
+func newModerationPipeline(db *database.DB) (*moderation.Pipeline, error) {
+	path := os.Getenv("MODERATION_CONFIG")
+	if path == "" {
+		return moderation.NewPipeline(), nil
+	}
+
+	mcfg, err := moderation.LoadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return moderation.NewPipelineFromConfig(mcfg, func(uID int, since time.Time) ([]string, error) {
+		chirps, err := db.GetChirpsSince(since.Unix())
+		if err != nil {
+			return nil, err
+		}
+
+		bodies := make([]string, 0)
+		for _, c := range chirps {
+			if c.UserID == uID {
+				bodies = append(bodies, c.Body)
+			}
+		}
+		return bodies, nil
+	})
+}
+
+// responds with a page of the chirps held for review with the status query parameter, pending by default
+func (cfg *apiConfig) handleGetModerationHeld(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "":
+		status = database.HeldPending
+	case database.HeldPending, database.HeldApproved, database.HeldRejected:
+	default:
+		respondWithError(w, http.StatusBadRequest, "status must be pending, approved or rejected")
+		return
+	}
+
+	limit, offset, err := parsePage(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	held, err := cfg.db.GetHeldChirps(status)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't get held chirps")
+		return
+	}
+
+	start, end := pageBounds(len(held), limit, offset)
+	respondWithJSON(w, http.StatusOK, struct {
+		Total int                  `json:"total"`
+		Held  []database.HeldChirp `json:"held"`
+	}{
+		Total: len(held),
+		Held:  held[start:end],
+	})
+}
+
+// publishes the held chirp
+func (cfg *apiConfig) handlePostModerationHeldApprove(w http.ResponseWriter, r *http.Request) {
+	cfg.reviewHeldChirp(w, r, true)
+}
+
+// discards the held chirp
+func (cfg *apiConfig) handlePostModerationHeldReject(w http.ResponseWriter, r *http.Request) {
+	cfg.reviewHeldChirp(w, r, false)
+}
+
+func (cfg *apiConfig) reviewHeldChirp(w http.ResponseWriter, r *http.Request, approve bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "heldID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "bad url")
+		return
+	}
+
+	p, ok := principalFromContext(r)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "couldn't authenticate")
+		return
+	}
+
+	h, err := cfg.db.ReviewHeldChirp(id, p.UserID, approve)
+	switch {
+	case errors.Is(err, database.ErrNotExist):
+		respondWithError(w, http.StatusNotFound, fmt.Sprintf("held chirp with id: %v is not found", id))
+		return
+	case errors.Is(err, database.ErrAlreadyReviewed):
+		respondWithError(w, http.StatusConflict, err.Error())
+		return
+	case errors.Is(err, database.ErrTargetGone), errors.Is(err, database.ErrAlreadyRechirped),
+		errors.Is(err, database.ErrMediaUnusable), errors.Is(err, database.ErrBlocked):
+		respondWithError(w, http.StatusConflict, fmt.Sprintf("chirp can't be published anymore: %s", err.Error()))
+		return
+	case err != nil:
+		respondWithError(w, http.StatusInternalServerError, "couldn't review held chirp")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, h)
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/hatrnuhn/chirpy-webserver/internal/auth"
+	"github.com/hatrnuhn/chirpy-webserver/internal/database"
+	"github.com/hatrnuhn/chirpy-webserver/internal/moderation"
+)
+
+// holds every chirp for review
+type holdAll struct{}
+
+func (holdAll) Name() string { return "hold_all" }
+
+func (holdAll) Check(c moderation.Chirp) (moderation.Verdict, error) {
+	return moderation.Verdict{Action: moderation.Hold, Reason: "everything is held"}, nil
+}
+
+// posts a chirp as the user, which holdAll holds, and returns the held chirp's ID
+func holdTestChirp(t *testing.T, cfg *apiConfig, h http.Handler, uID int, body map[string]any) int {
+	t.Helper()
+
+	w := doRequest(t, h, http.MethodPost, "/api/chirps", testAccessToken(t, cfg, uID), body)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("chirp wasn't held: %d %s", w.Code, w.Body.String())
+	}
+
+	resp := struct {
+		HeldID int `json:"held_id"`
+	}{}
+	decodeBody(t, w, &resp)
+	return resp.HeldID
+}
+
+func TestReviewHeldChirp(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.moderation = moderation.NewPipeline(holdAll{})
+	h := cfg.routes()
+	author := createTestUser(t, cfg, "walt@example.com")
+	mod := createTestUser(t, cfg, "marie@example.com")
+	setTestRole(t, cfg, mod.ID, auth.RoleModerator)
+	modToken := testAccessToken(t, cfg, mod.ID)
+
+	approved := holdTestChirp(t, cfg, h, author.ID, map[string]any{"body": "publish me"})
+	rejected := holdTestChirp(t, cfg, h, author.ID, map[string]any{"body": "reject me"})
+
+	w := doRequest(t, h, http.MethodPost, fmt.Sprintf("/admin/moderation/held/%d/approve", approved), testAccessToken(t, cfg, author.ID), nil)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("approving as a user: got %d, want 403", w.Code)
+	}
+
+	w = doRequest(t, h, http.MethodPost, fmt.Sprintf("/admin/moderation/held/%d/approve", approved), modToken, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("approve: got %d %s", w.Code, w.Body.String())
+	}
+	held := database.HeldChirp{}
+	decodeBody(t, w, &held)
+	if held.Status != database.HeldApproved || held.ChirpID == 0 || held.ReviewerID != mod.ID {
+		t.Fatalf("approve: got %+v", held)
+	}
+
+	w = doRequest(t, h, http.MethodGet, fmt.Sprintf("/api/chirps/%d", held.ChirpID), "", nil)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "publish me") {
+		t.Fatalf("approved chirp: got %d %s", w.Code, w.Body.String())
+	}
+
+	w = doRequest(t, h, http.MethodPost, fmt.Sprintf("/admin/moderation/held/%d/reject", rejected), modToken, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("reject: got %d %s", w.Code, w.Body.String())
+	}
+	held = database.HeldChirp{}
+	decodeBody(t, w, &held)
+	if held.Status != database.HeldRejected || held.ChirpID != 0 {
+		t.Fatalf("reject: got %+v", held)
+	}
+
+	w = doRequest(t, h, http.MethodPost, fmt.Sprintf("/admin/moderation/held/%d/approve", rejected), modToken, nil)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("approving a rejected chirp: got %d %s, want 409", w.Code, w.Body.String())
+	}
+
+	w = doRequest(t, h, http.MethodPost, "/admin/moderation/held/999/approve", modToken, nil)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("unknown held chirp: got %d %s, want 404", w.Code, w.Body.String())
+	}
+}
+
+func TestApproveHeldReplyToDeletedChirp(t *testing.T) {
+	cfg := newTestConfig(t)
+	h := cfg.routes()
+	author := createTestUser(t, cfg, "walt@example.com")
+	mod := createTestUser(t, cfg, "marie@example.com")
+	setTestRole(t, cfg, mod.ID, auth.RoleModerator)
+
+	parent, err := cfg.db.CreateChirp(database.Chirp{Body: "going away soon"}, mod.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg.moderation = moderation.NewPipeline(holdAll{})
+	heldID := holdTestChirp(t, cfg, h, author.ID, map[string]any{"body": "a reply", "in_reply_to_id": parent.ID})
+
+	_, err = cfg.db.DeleteChirp(parent.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := doRequest(t, h, http.MethodPost, fmt.Sprintf("/admin/moderation/held/%d/approve", heldID), testAccessToken(t, cfg, mod.ID), nil)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("got %d %s, want 409", w.Code, w.Body.String())
+	}
+
+	// it's left for a moderator to reject
+	pending, err := cfg.db.GetHeldChirps(database.HeldPending)
+	if err != nil || len(pending) != 1 {
+		t.Fatalf("pending held chirps: got %+v, %v", pending, err)
+	}
+}
+
+func TestRewrittenChirpTooLong(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.moderation = moderation.NewPipeline(moderation.NewBannedWords([]string{"kerfuffle"}, strings.Repeat("*", 20)))
+	h := cfg.routes()
+	u := createTestUser(t, cfg, "walt@example.com")
+
+	body := strings.TrimSpace(strings.Repeat("kerfuffle ", 14))
+	if len(body) > 140 {
+		t.Fatalf("test body is %d characters", len(body))
+	}
+
+	w := doRequest(t, h, http.MethodPost, "/api/chirps", testAccessToken(t, cfg, u.ID), map[string]string{"body": body})
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("got %d %s, want 400", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi"
+	"github.com/hatrnuhn/chirpy-webserver/internal/auth"
+	"github.com/hatrnuhn/chirpy-webserver/internal/database"
+)
+
+// mutes the user as the authenticated user, muting them again does nothing
+func (cfg *apiConfig) handlePostUserMute(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "bad url")
+		return
+	}
+
+	p, err := cfg.authenticate(r, auth.ScopeProfileWrite)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	m, created, err := cfg.db.Mute(p.UserID, id)
+	if errors.Is(err, database.ErrSelfMute) {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if errors.Is(err, database.ErrNotExist) {
+		respondWithError(w, http.StatusNotFound, fmt.Sprintf("user with id: %v is not found", id))
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't mute user")
+		return
+	}
+
+	if created {
+		respondWithJSON(w, http.StatusCreated, m)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, m)
+}
+
+// unmutes the user as the authenticated user, if they're muted
+func (cfg *apiConfig) handleDelUserMute(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "bad url")
+		return
+	}
+
+	p, err := cfg.authenticate(r, auth.ScopeProfileWrite)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	err = cfg.db.Unmute(p.UserID, id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't unmute user")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("OK"))
+}
+
+// responds with a page of the users the authenticated user muted, most recent first
+func (cfg *apiConfig) handleGetUsersMeMutes(w http.ResponseWriter, r *http.Request) {
+	p, err := cfg.authenticate(r, auth.ScopeProfileRead)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	limit, offset, err := parsePage(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	mutes, err := cfg.db.GetMutes(p.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't get mutes")
+		return
+	}
+
+	type mutedUser struct {
+		UserID  int   `json:"user_id"`
+		MutedAt int64 `json:"muted_at"`
+	}
+
+	start, end := pageBounds(len(mutes), limit, offset)
+	users := make([]mutedUser, 0, end-start)
+	for _, m := range mutes[start:end] {
+		users = append(users, mutedUser{UserID: m.MutedID, MutedAt: m.MutedAt})
+	}
+
+	respondWithJSON(w, http.StatusOK, struct {
+		Total int         `json:"total"`
+		Users []mutedUser `json:"users"`
+	}{
+		Total: len(mutes),
+		Users: users,
+	})
+}
+
+type mutedKeywords struct {
+	Keywords []string `json:"keywords"`
+}
+
+// responds with the keywords the authenticated user muted
+func (cfg *apiConfig) handleGetUsersMeMutedKeywords(w http.ResponseWriter, r *http.Request) {
+	p, err := cfg.authenticate(r, auth.ScopeProfileRead)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	keywords, err := cfg.db.GetMutedKeywords(p.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't get muted keywords")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, mutedKeywords{Keywords: keywords})
+}
+
+// replaces the keywords the authenticated user muted, chirps containing them are hidden from their
+// timelines and notifications
+func (cfg *apiConfig) handlePutUsersMeMutedKeywords(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	p, err := cfg.authenticate(r, auth.ScopeProfileWrite)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	dat, err := io.ReadAll(r.Body)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "couldn't read request")
+		return
+	}
+
+	req := mutedKeywords{}
+	err = json.Unmarshal(dat, &req)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "couldn't unmarshal request")
+		return
+	}
+
+	if len(req.Keywords) > database.MaxMutedKeywords {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("can't mute more than %d keywords", database.MaxMutedKeywords))
+		return
+	}
+	for _, kw := range req.Keywords {
+		if utf8.RuneCountInString(kw) > database.MaxMutedKeywordLength {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("keywords can't be longer than %d characters", database.MaxMutedKeywordLength))
+			return
+		}
+	}
+
+	keywords, err := cfg.db.UpdateMutedKeywords(p.UserID, req.Keywords)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't update muted keywords")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, mutedKeywords{Keywords: keywords})
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/hatrnuhn/chirpy-webserver/internal/auth"
+	"github.com/hatrnuhn/chirpy-webserver/internal/database"
+)
+
+// how many of the users behind a group of notifications are listed
+const maxGroupActors = 5
+
+// notifications of the same type about the same chirp, like "5 people liked your chirp". replies,
+// mentions and quotes are each about a chirp of their own and reports are each about a report of
+// their own, so they're never grouped
+type notificationGroup struct {
+	Type    string `json:"type"`
+	ChirpID int    `json:"chirp_id,omitempty"`
+	// the resolved report, only for report notifications
+	ReportID   int    `json:"report_id,omitempty"`
+	Resolution string `json:"resolution,omitempty"`
+	// most recent first
+	ActorIDs   []int `json:"actor_ids"`
+	ActorCount int   `json:"actor_count"`
+	// the notifications in the group, newest first, for marking them read
+	NotificationIDs []int `json:"notification_ids"`
+	// when the most recent notification in the group was created
+	CreatedAt int64 `json:"created_at"`
+	Read      bool  `json:"read"`
+}
+
+// groups notifications sorted newest first, the groups are sorted by their most recent notification
+func groupNotifications(notifications []database.Notification) []*notificationGroup {
+	groups := make([]*notificationGroup, 0)
+	byKey := make(map[string]*notificationGroup)
+	for _, n := range notifications {
+		key := fmt.Sprintf("%s:%d", n.Type, n.ChirpID)
+		switch n.Type {
+		case database.NotificationReply, database.NotificationMention, database.NotificationQuote, database.NotificationReport:
+			key = strconv.Itoa(n.ID)
+		}
+
+		g, ok := byKey[key]
+		if !ok {
+			g = &notificationGroup{
+				Type:       n.Type,
+				ChirpID:    n.ChirpID,
+				ReportID:   n.ReportID,
+				Resolution: n.Resolution,
+				ActorIDs:   make([]int, 0, 1),
+				CreatedAt:  n.CreatedAt,
+				Read:       true,
+			}
+			byKey[key] = g
+			groups = append(groups, g)
+		}
+
+		// moderators resolving reports stay anonymous
+		if n.ActorID != 0 {
+			if len(g.ActorIDs) < maxGroupActors {
+				g.ActorIDs = append(g.ActorIDs, n.ActorID)
+			}
+			g.ActorCount++
+		}
+		g.NotificationIDs = append(g.NotificationIDs, n.ID)
+		g.Read = g.Read && n.ReadAt != 0
+	}
+
+	return groups
+}
+
+// responds with a page of the authenticated user's grouped notifications, newest first, along with how
+// many groups are unread
+func (cfg *apiConfig) handleGetNotifications(w http.ResponseWriter, r *http.Request) {
+	p, err := cfg.authenticate(r, auth.ScopeProfileRead)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	limit, offset, err := parsePage(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	notifications, err := cfg.db.GetNotifications(p.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't get notifications")
+		return
+	}
+
+	groups := groupNotifications(notifications)
+	unread := 0
+	for _, g := range groups {
+		if !g.Read {
+			unread++
+		}
+	}
+
+	start, end := pageBounds(len(groups), limit, offset)
+	respondWithJSON(w, http.StatusOK, struct {
+		UnreadCount   int                  `json:"unread_count"`
+		Total         int                  `json:"total"`
+		Notifications []*notificationGroup `json:"notifications"`
+	}{
+		UnreadCount:   unread,
+		Total:         len(groups),
+		Notifications: groups[start:end],
+	})
+}
+
+// marks the authenticated user's notifications with the given ids as read, or all of them if the body
+// has none
+func (cfg *apiConfig) handlePostNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	p, err := cfg.authenticate(r, auth.ScopeProfileWrite)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	dat, err := io.ReadAll(r.Body)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "couldn't read request")
+		return
+	}
+
+	req := struct {
+		IDs []int `json:"ids"`
+	}{}
+	if len(dat) != 0 {
+		err = json.Unmarshal(dat, &req)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "couldn't unmarshal request")
+			return
+		}
+	}
+
+	err = cfg.db.MarkNotificationsRead(p.UserID, req.IDs)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't mark notifications read")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("OK"))
+}
+
+// responds with whether each type of notification is on for the authenticated user
+func (cfg *apiConfig) handleGetNotificationSettings(w http.ResponseWriter, r *http.Request) {
+	p, err := cfg.authenticate(r, auth.ScopeProfileRead)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	settings, err := cfg.db.GetNotificationSettings(p.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't get notification settings")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, settings)
+}
+
+// turns types of notifications on or off for the authenticated user, the body maps types to whether
+// they're on and types that aren't in it are left as they are
+func (cfg *apiConfig) handlePutNotificationSettings(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	p, err := cfg.authenticate(r, auth.ScopeProfileWrite)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	dat, err := io.ReadAll(r.Body)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "couldn't read request")
+		return
+	}
+
+	req := map[string]bool{}
+	err = json.Unmarshal(dat, &req)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "couldn't unmarshal request")
+		return
+	}
+
+	for t := range req {
+		if !database.ValidNotificationType(t) {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("unknown notification type: %s", t))
+			return
+		}
+	}
+
+	settings, err := cfg.db.UpdateNotificationSettings(p.UserID, req)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't update notification settings")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, settings)
+}
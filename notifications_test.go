@@ -0,0 +1,68 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hatrnuhn/chirpy-webserver/internal/database"
+)
+
+func TestGroupNotifications(t *testing.T) {
+	// newest first, like GetNotifications returns them
+	notifications := []database.Notification{
+		{ID: 9, Type: database.NotificationLike, ActorID: 7, ChirpID: 1, CreatedAt: 90},
+		{ID: 8, Type: database.NotificationReply, ActorID: 6, ChirpID: 5, CreatedAt: 80},
+		{ID: 7, Type: database.NotificationReply, ActorID: 6, ChirpID: 4, CreatedAt: 70},
+		{ID: 6, Type: database.NotificationLike, ActorID: 6, ChirpID: 2, CreatedAt: 60, ReadAt: 65},
+		{ID: 5, Type: database.NotificationLike, ActorID: 5, ChirpID: 1, CreatedAt: 50, ReadAt: 55},
+		{ID: 4, Type: database.NotificationFollow, ActorID: 4, CreatedAt: 40},
+		{ID: 3, Type: database.NotificationFollow, ActorID: 3, CreatedAt: 30},
+		{ID: 2, Type: database.NotificationReport, ReportID: 1, Resolution: "removed", CreatedAt: 20},
+		{ID: 1, Type: database.NotificationReport, ReportID: 2, Resolution: "dismissed", CreatedAt: 10},
+	}
+
+	want := []*notificationGroup{
+		{Type: database.NotificationLike, ChirpID: 1, ActorIDs: []int{7, 5}, ActorCount: 2, NotificationIDs: []int{9, 5}, CreatedAt: 90},
+		{Type: database.NotificationReply, ChirpID: 5, ActorIDs: []int{6}, ActorCount: 1, NotificationIDs: []int{8}, CreatedAt: 80},
+		{Type: database.NotificationReply, ChirpID: 4, ActorIDs: []int{6}, ActorCount: 1, NotificationIDs: []int{7}, CreatedAt: 70},
+		{Type: database.NotificationLike, ChirpID: 2, ActorIDs: []int{6}, ActorCount: 1, NotificationIDs: []int{6}, CreatedAt: 60, Read: true},
+		{Type: database.NotificationFollow, ActorIDs: []int{4, 3}, ActorCount: 2, NotificationIDs: []int{4, 3}, CreatedAt: 40},
+		{Type: database.NotificationReport, ReportID: 1, Resolution: "removed", ActorIDs: []int{}, NotificationIDs: []int{2}, CreatedAt: 20},
+		{Type: database.NotificationReport, ReportID: 2, Resolution: "dismissed", ActorIDs: []int{}, NotificationIDs: []int{1}, CreatedAt: 10},
+	}
+
+	got := groupNotifications(notifications)
+	if len(got) != len(want) {
+		t.Fatalf("got %d groups, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if !reflect.DeepEqual(got[i], want[i]) {
+			t.Errorf("group %d: got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestGroupNotificationsActorLimit(t *testing.T) {
+	notifications := make([]database.Notification, 0)
+	for i := maxGroupActors + 2; i > 0; i-- {
+		notifications = append(notifications, database.Notification{
+			ID:      i,
+			Type:    database.NotificationLike,
+			ActorID: 100 + i,
+			ChirpID: 1,
+		})
+	}
+
+	groups := groupNotifications(notifications)
+	if len(groups) != 1 {
+		t.Fatalf("got %d groups, want 1", len(groups))
+	}
+
+	g := groups[0]
+	if len(g.ActorIDs) != maxGroupActors || g.ActorIDs[0] != 100+maxGroupActors+2 {
+		t.Errorf("got actors %v, want the %d most recent", g.ActorIDs, maxGroupActors)
+	}
+	if g.ActorCount != maxGroupActors+2 || len(g.NotificationIDs) != maxGroupActors+2 {
+		t.Errorf("got %d actors and notifications %v, want all %d", g.ActorCount, g.NotificationIDs, maxGroupActors+2)
+	}
+}
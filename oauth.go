@@ -0,0 +1,760 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/hatrnuhn/chirpy-webserver/internal/auth"
+	"github.com/hatrnuhn/chirpy-webserver/internal/database"
+)
+
+// chirpy as an OAuth 2.0 authorization server (RFC 6749) for third-party clients: authorization code
+// grant with PKCE (RFC 7636), refresh token grant, introspection (RFC 7662) and revocation (RFC 7009)
+
+const (
+	oauthCodeTTL         = 5 * time.Minute
+	oauthAccessTokenTTL  = 3600
+	oauthRefreshTokenTTL = 24 * 3600 * 30
+)
+
+// scope granted when the client doesn't ask for any
+var oauthDefaultScopes = []string{auth.ScopeChirpsRead}
+
+type oauthClientResponse struct {
+	ClientID     string   `json:"client_id"`
+	Name         string   `json:"name"`
+	RedirectURIs []string `json:"redirect_uris"`
+	Confidential bool     `json:"confidential"`
+	CreatedAt    int64    `json:"created_at"`
+	// only set when the client is registered
+	ClientSecret string `json:"client_secret,omitempty"`
+}
+
+func newOAuthClientResponse(c database.OAuthClient) oauthClientResponse {
+	return oauthClientResponse{
+		ClientID:     c.ID,
+		Name:         c.Name,
+		RedirectURIs: c.RedirectURIs,
+		Confidential: c.SecretHash != "",
+		CreatedAt:    c.CreatedAt,
+	}
+}
+
+// registers an OAuth client owned by the authenticated user, confidential clients get a secret
+// that's only ever shown in this response
+func (cfg *apiConfig) handlePostOAuthClients(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	uID, err := cfg.authUserID(r)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	dat, err := io.ReadAll(r.Body)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "couldn't read request")
+		return
+	}
+
+	req := struct {
+		Name         string   `json:"name"`
+		RedirectURIs []string `json:"redirect_uris"`
+		Confidential bool     `json:"confidential"`
+	}{}
+	err = json.Unmarshal(dat, &req)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "couldn't unmarshal request")
+		return
+	}
+
+	if req.Name == "" || len(req.Name) > 100 {
+		respondWithError(w, http.StatusBadRequest, "name is required and must be at most 100 characters")
+		return
+	}
+
+	if len(req.RedirectURIs) == 0 {
+		respondWithError(w, http.StatusBadRequest, "at least one redirect uri is required")
+		return
+	}
+
+	for _, u := range req.RedirectURIs {
+		if !validRedirectURI(u) {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("invalid redirect uri: %s, it must be https or http on localhost and have no fragment", u))
+			return
+		}
+	}
+
+	clientID, err := auth.MakeRandomToken(16)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("couldn't create client id: %s", err.Error()))
+		return
+	}
+
+	var secret, secretHash string
+	if req.Confidential {
+		secret, err = auth.MakeRandomToken(32)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("couldn't create client secret: %s", err.Error()))
+			return
+		}
+		secretHash = auth.HashToken(secret)
+	}
+
+	c, err := cfg.db.CreateOAuthClient(database.OAuthClient{
+		ID:           clientID,
+		OwnerID:      uID,
+		Name:         req.Name,
+		SecretHash:   secretHash,
+		RedirectURIs: req.RedirectURIs,
+		CreatedAt:    time.Now().Unix(),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("couldn't write client to db: %s", err.Error()))
+		return
+	}
+
+	resp := newOAuthClientResponse(c)
+	resp.ClientSecret = secret
+	respondWithJSON(w, http.StatusCreated, resp)
+}
+
+// lists the OAuth clients the authenticated user registered
+func (cfg *apiConfig) handleGetOAuthClients(w http.ResponseWriter, r *http.Request) {
+	uID, err := cfg.authUserID(r)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	cs, err := cfg.db.GetOAuthClients(uID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't get clients")
+		return
+	}
+
+	resp := make([]oauthClientResponse, 0, len(cs))
+	for _, c := range cs {
+		resp = append(resp, newOAuthClientResponse(c))
+	}
+
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+// deletes an OAuth client the authenticated user registered, tokens already issued to it stop being refreshable
+func (cfg *apiConfig) handleDelOAuthClientID(w http.ResponseWriter, r *http.Request) {
+	uID, err := cfg.authUserID(r)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	id := chi.URLParam(r, "clientID")
+	err = cfg.db.DeleteOAuthClient(uID, id)
+	if errors.Is(err, database.ErrNotExist) {
+		respondWithError(w, http.StatusNotFound, fmt.Sprintf("client with id: %s is not found", id))
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't delete client")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("OK"))
+}
+
+// authorizeRequest is an authorization request of the code grant, section 4.1.1
+type authorizeRequest struct {
+	Client      database.OAuthClient
+	RedirectURI string
+	// whether the client sent RedirectURI rather than relying on the one it registered
+	RedirectURIGiven bool
+	Scopes           []string
+	State            string
+	CodeChallenge    string
+}
+
+// validates the authorization request in the query or form values. errors with a redirect uri set are
+// sent back to the client, those without it are shown to the user since the redirect uri can't be trusted
+func (cfg *apiConfig) parseAuthorizeRequest(v url.Values) (authorizeRequest, string, error) {
+	c, err := cfg.db.GetOAuthClient(v.Get("client_id"))
+	if err != nil {
+		return authorizeRequest{}, "", errors.New("unknown client")
+	}
+
+	redirectURI := v.Get("redirect_uri")
+	if redirectURI == "" && len(c.RedirectURIs) == 1 {
+		redirectURI = c.RedirectURIs[0]
+	}
+
+	registered := false
+	for _, u := range c.RedirectURIs {
+		if u == redirectURI {
+			registered = true
+			break
+		}
+	}
+	if !registered {
+		return authorizeRequest{}, "", errors.New("redirect uri isn't registered for this client")
+	}
+
+	ar := authorizeRequest{
+		Client:           c,
+		RedirectURI:      redirectURI,
+		RedirectURIGiven: v.Get("redirect_uri") != "",
+		State:            v.Get("state"),
+		CodeChallenge:    v.Get("code_challenge"),
+	}
+
+	if v.Get("response_type") != "code" {
+		return ar, "unsupported_response_type", errors.New("only the code response type is supported")
+	}
+
+	ar.Scopes, err = parseScopes(v.Get("scope"))
+	if err != nil {
+		return ar, "invalid_scope", err
+	}
+
+	// public clients can't keep a secret, PKCE is what keeps their codes from being stolen
+	if ar.CodeChallenge == "" && c.SecretHash == "" {
+		return ar, "invalid_request", errors.New("public clients must use PKCE")
+	}
+	if ar.CodeChallenge != "" && v.Get("code_challenge_method") != "S256" {
+		return ar, "invalid_request", errors.New("only the S256 code challenge method is supported")
+	}
+
+	return ar, "", nil
+}
+
+// shows the user which client wants access to what, the user signs in and allows or denies it on the page
+func (cfg *apiConfig) handleGetOAuthAuthorize(w http.ResponseWriter, r *http.Request) {
+	ar, errCode, err := cfg.parseAuthorizeRequest(r.URL.Query())
+	if err != nil {
+		respondWithAuthorizeError(w, r, ar, errCode, err)
+		return
+	}
+
+	renderConsentPage(w, http.StatusOK, ar, r.URL.Query(), "")
+}
+
+// handles the consent page form, on approval redirects back to the client with an authorization code
+func (cfg *apiConfig) handlePostOAuthAuthorize(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "couldn't parse form")
+		return
+	}
+
+	ar, errCode, err := cfg.parseAuthorizeRequest(r.PostForm)
+	if err != nil {
+		respondWithAuthorizeError(w, r, ar, errCode, err)
+		return
+	}
+
+	if r.PostForm.Get("decision") != "allow" {
+		redirectWithAuthorizeResult(w, r, ar, url.Values{"error": {"access_denied"}})
+		return
+	}
+
+	user, err := cfg.verifyCredentials(r, r.PostForm.Get("email"), r.PostForm.Get("password"))
+	if err == nil && user.TOTPEnabled {
+		err = cfg.verifySecondFactor(r, user, r.PostForm.Get("code"), r.PostForm.Get("recovery_code"))
+	}
+	if err != nil {
+		renderConsentPage(w, http.StatusUnauthorized, ar, r.PostForm, err.Error())
+		return
+	}
+
+	code, err := auth.MakeRandomToken(32)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("couldn't create authorization code: %s", err.Error()))
+		return
+	}
+
+	// the token request only has to repeat the redirect uri if the authorization request had one
+	redirectURI := ""
+	if ar.RedirectURIGiven {
+		redirectURI = ar.RedirectURI
+	}
+
+	err = cfg.db.CreateOAuthCode(auth.HashToken(code), database.OAuthCode{
+		ClientID:      ar.Client.ID,
+		UserID:        user.ID,
+		RedirectURI:   redirectURI,
+		Scopes:        ar.Scopes,
+		CodeChallenge: ar.CodeChallenge,
+		ExpiresAt:     time.Now().Add(oauthCodeTTL).Unix(),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("couldn't write authorization code to db: %s", err.Error()))
+		return
+	}
+
+	redirectWithAuthorizeResult(w, r, ar, url.Values{"code": {code}})
+}
+
+// issues tokens for the authorization_code and refresh_token grants, section 4.1.3 and 6
+func (cfg *apiConfig) handlePostOAuthToken(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_request", "couldn't parse form")
+		return
+	}
+
+	c, err := cfg.authOAuthClient(r)
+	if err != nil {
+		respondWithOAuthError(w, http.StatusUnauthorized, "invalid_client", err.Error())
+		return
+	}
+
+	switch r.PostForm.Get("grant_type") {
+	case "authorization_code":
+		cfg.grantAuthorizationCode(w, r, c)
+	case "refresh_token":
+		cfg.grantRefreshToken(w, r, c)
+	default:
+		respondWithOAuthError(w, http.StatusBadRequest, "unsupported_grant_type", "only authorization_code and refresh_token are supported")
+	}
+}
+
+func (cfg *apiConfig) grantAuthorizationCode(w http.ResponseWriter, r *http.Request, c database.OAuthClient) {
+	code, err := cfg.db.ConsumeOAuthCode(auth.HashToken(r.PostForm.Get("code")))
+	if err != nil {
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_grant", "invalid, expired or used authorization code")
+		return
+	}
+
+	if code.ClientID != c.ID {
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_grant", "authorization code was issued to another client")
+		return
+	}
+
+	if ru := r.PostForm.Get("redirect_uri"); ru != code.RedirectURI {
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_grant", "redirect uri doesn't match the authorization request's")
+		return
+	}
+
+	if code.CodeChallenge != "" && !auth.VerifyPKCE(r.PostForm.Get("code_verifier"), code.CodeChallenge) {
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_grant", "code verifier doesn't match the code challenge")
+		return
+	}
+
+	cfg.respondWithOAuthTokens(w, code.UserID, c.ID, code.Scopes, "")
+}
+
+func (cfg *apiConfig) grantRefreshToken(w http.ResponseWriter, r *http.Request, c database.OAuthClient) {
+	rt := r.PostForm.Get("refresh_token")
+	claims, err := cfg.parseOAuthToken(rt, c.ID)
+	if err != nil || claims.Issuer != "chirpy-refresh" {
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_grant", "invalid refresh token")
+		return
+	}
+
+	isNotExp, err := cfg.db.RJWTNotExp(rt)
+	if err != nil {
+		respondWithOAuthError(w, http.StatusInternalServerError, "server_error", "couldn't verify refresh token")
+		return
+	}
+	if !isNotExp {
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_grant", "refresh token is revoked")
+		return
+	}
+
+	_, err = cfg.checkTokenRevocation(claims)
+	if err != nil {
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_grant", err.Error())
+		return
+	}
+
+	// the client may narrow the scopes down but never widen them
+	scopes := strings.Fields(claims.Scope)
+	if s := r.PostForm.Get("scope"); s != "" {
+		requested, err := parseScopes(s)
+		if err != nil || !subsetOf(requested, scopes) {
+			respondWithOAuthError(w, http.StatusBadRequest, "invalid_scope", "scope exceeds the one originally granted")
+			return
+		}
+		scopes = requested
+	}
+
+	uID, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_grant", "invalid refresh token")
+		return
+	}
+
+	// refresh tokens are rotated, a leaked one can only be used once
+	cfg.respondWithOAuthTokens(w, uID, c.ID, scopes, rt)
+}
+
+// responds with an AJWT and RJWT pair issued to the client in the format of section 5.1. the RJWT
+// replaces, if it isn't empty, is revoked as the new one is stored
+func (cfg *apiConfig) respondWithOAuthTokens(w http.ResponseWriter, uID int, clientID string, scopes []string, replaces string) {
+	user, err := cfg.db.GetUser(uID)
+	if err != nil {
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_grant", "user doesn't exist anymore")
+		return
+	}
+	if user.SuspendedAt != 0 {
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_grant", errSuspended.Error())
+		return
+	}
+
+	aToken, err := auth.CreateOAuthAccessToken(user.ID, user.Role, auth.PlanOf(user.IsChirpyRed), user.TokenGeneration, clientID, scopes, cfg.jwtSecret, oauthAccessTokenTTL)
+	if err != nil {
+		respondWithOAuthError(w, http.StatusInternalServerError, "server_error", "couldn't create access token")
+		return
+	}
+
+	rToken, err := auth.CreateOAuthRefreshToken(user.ID, user.TokenGeneration, clientID, scopes, cfg.jwtSecret, oauthRefreshTokenTTL)
+	if err != nil {
+		respondWithOAuthError(w, http.StatusInternalServerError, "server_error", "couldn't create refresh token")
+		return
+	}
+
+	if replaces != "" {
+		err = cfg.db.RotateRefreshToken(replaces, rToken, time.Now().Unix())
+	} else {
+		_, err = cfg.db.WriteRefreshToken(rToken, 0)
+	}
+	if errors.Is(err, database.ErrTokenRevoked) {
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_grant", "refresh token is revoked")
+		return
+	}
+	if err != nil {
+		respondWithOAuthError(w, http.StatusInternalServerError, "server_error", "couldn't write refresh token to database")
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	respondWithJSON(w, http.StatusOK, struct {
+		AccessToken  string `json:"access_token"`
+		TokenType    string `json:"token_type"`
+		ExpiresIn    int    `json:"expires_in"`
+		RefreshToken string `json:"refresh_token"`
+		Scope        string `json:"scope"`
+	}{
+		AccessToken:  aToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    oauthAccessTokenTTL,
+		RefreshToken: rToken,
+		Scope:        strings.Join(scopes, " "),
+	})
+}
+
+// tells the client whether a token it was issued is active and what it grants, RFC 7662
+func (cfg *apiConfig) handlePostOAuthIntrospect(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_request", "couldn't parse form")
+		return
+	}
+
+	c, err := cfg.authOAuthClient(r)
+	if err != nil {
+		respondWithOAuthError(w, http.StatusUnauthorized, "invalid_client", err.Error())
+		return
+	}
+
+	type introspection struct {
+		Active    bool   `json:"active"`
+		Scope     string `json:"scope,omitempty"`
+		ClientID  string `json:"client_id,omitempty"`
+		TokenType string `json:"token_type,omitempty"`
+		Exp       int64  `json:"exp,omitempty"`
+		Iat       int64  `json:"iat,omitempty"`
+		Sub       string `json:"sub,omitempty"`
+		Iss       string `json:"iss,omitempty"`
+	}
+
+	// anything that isn't an active token issued to this client is just inactive, section 2.2
+	token := r.PostForm.Get("token")
+	claims, err := cfg.parseOAuthToken(token, c.ID)
+	if err != nil {
+		respondWithJSON(w, http.StatusOK, introspection{Active: false})
+		return
+	}
+
+	_, err = cfg.checkTokenRevocation(claims)
+	if err != nil {
+		respondWithJSON(w, http.StatusOK, introspection{Active: false})
+		return
+	}
+
+	tokenType := "access_token"
+	if claims.Issuer == "chirpy-refresh" {
+		tokenType = "refresh_token"
+		isNotExp, err := cfg.db.RJWTNotExp(token)
+		if err != nil || !isNotExp {
+			respondWithJSON(w, http.StatusOK, introspection{Active: false})
+			return
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, introspection{
+		Active:    true,
+		Scope:     claims.Scope,
+		ClientID:  claims.ClientID,
+		TokenType: tokenType,
+		Exp:       claims.ExpiresAt.Unix(),
+		Iat:       claims.IssuedAt.Unix(),
+		Sub:       claims.Subject,
+		Iss:       claims.Issuer,
+	})
+}
+
+// revokes a refresh token issued to the client, RFC 7009. AJWTs can't be revoked, they're short-lived
+func (cfg *apiConfig) handlePostOAuthRevoke(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_request", "couldn't parse form")
+		return
+	}
+
+	c, err := cfg.authOAuthClient(r)
+	if err != nil {
+		respondWithOAuthError(w, http.StatusUnauthorized, "invalid_client", err.Error())
+		return
+	}
+
+	token := r.PostForm.Get("token")
+	t, err := auth.ParseToken(token, cfg.jwtSecret)
+	if err != nil {
+		// invalid tokens need no revoking, section 2.2
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	claims, ok := t.Claims.(*auth.Claims)
+	if !ok || claims.ClientID != c.ID {
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_request", "token wasn't issued to this client")
+		return
+	}
+
+	// access tokens are denied by ID until they expire
+	if claims.Issuer == "chirpy-access" {
+		err = cfg.db.DenyTokenID(claims.ID, claims.ExpiresAt.Unix())
+		if err != nil {
+			respondWithOAuthError(w, http.StatusServiceUnavailable, "server_error", "couldn't revoke access token")
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	_, err = cfg.db.WriteRefreshToken(token, time.Now().Unix())
+	if err != nil {
+		respondWithOAuthError(w, http.StatusServiceUnavailable, "server_error", "couldn't revoke refresh token")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// authenticates the client with HTTP Basic or client_id and client_secret form values,
+// public clients only identify themselves with client_id
+func (cfg *apiConfig) authOAuthClient(r *http.Request) (database.OAuthClient, error) {
+	clientID, secret, ok := r.BasicAuth()
+	if ok {
+		// section 2.3.1, credentials are form-urlencoded before they're put in the header
+		clientID, _ = url.QueryUnescape(clientID)
+		secret, _ = url.QueryUnescape(secret)
+	} else {
+		clientID, secret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+
+	c, err := cfg.db.GetOAuthClient(clientID)
+	if err != nil {
+		return database.OAuthClient{}, errors.New("unknown client")
+	}
+
+	if c.SecretHash == "" {
+		return c, nil
+	}
+
+	if subtle.ConstantTimeCompare([]byte(auth.HashToken(secret)), []byte(c.SecretHash)) != 1 {
+		return database.OAuthClient{}, errors.New("invalid client credentials")
+	}
+
+	return c, nil
+}
+
+// parses a token and makes sure it was issued to the client
+func (cfg *apiConfig) parseOAuthToken(token, clientID string) (*auth.Claims, error) {
+	t, err := auth.ParseToken(token, cfg.jwtSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := t.Claims.(*auth.Claims)
+	if !ok || claims.ClientID == "" || claims.ClientID != clientID {
+		return nil, errors.New("token wasn't issued to this client")
+	}
+
+	return claims, nil
+}
+
+// parses a space separated scope parameter, defaulting to oauthDefaultScopes
+func parseScopes(scope string) ([]string, error) {
+	fields := strings.Fields(scope)
+	if len(fields) == 0 {
+		return oauthDefaultScopes, nil
+	}
+
+	scopes := make([]string, 0, len(fields))
+	for _, s := range fields {
+		if !auth.ValidScope(s) {
+			return nil, fmt.Errorf("unknown scope: %s", s)
+		}
+		if !subsetOf([]string{s}, scopes) {
+			scopes = append(scopes, s)
+		}
+	}
+
+	return scopes, nil
+}
+
+func subsetOf(a, b []string) bool {
+	for _, x := range a {
+		found := false
+		for _, y := range b {
+			if x == y {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// redirect uris must be absolute, without a fragment, and https unless they point back at the user's own machine
+func validRedirectURI(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || !u.IsAbs() || u.Fragment != "" || u.Host == "" {
+		return false
+	}
+
+	switch u.Scheme {
+	case "https":
+		return true
+	case "http":
+		host := u.Hostname()
+		return host == "localhost" || host == "127.0.0.1" || host == "::1"
+	default:
+		return false
+	}
+}
+
+// sends an authorization error back to the client if its redirect uri is known, otherwise shows it to the user
+func respondWithAuthorizeError(w http.ResponseWriter, r *http.Request, ar authorizeRequest, errCode string, err error) {
+	if errCode == "" {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	redirectWithAuthorizeResult(w, r, ar, url.Values{
+		"error":             {errCode},
+		"error_description": {err.Error()},
+	})
+}
+
+func redirectWithAuthorizeResult(w http.ResponseWriter, r *http.Request, ar authorizeRequest, params url.Values) {
+	u, err := url.Parse(ar.RedirectURI)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid redirect uri")
+		return
+	}
+
+	q := u.Query()
+	for k, vs := range params {
+		q[k] = vs
+	}
+	if ar.State != "" {
+		q.Set("state", ar.State)
+	}
+	u.RawQuery = q.Encode()
+
+	http.Redirect(w, r, u.String(), http.StatusFound)
+}
+
+// responds with an error in the format of section 5.2
+func respondWithOAuthError(w http.ResponseWriter, code int, errCode, description string) {
+	w.Header().Set("Cache-Control", "no-store")
+	respondWithJSON(w, code, map[string]string{
+		"error":             errCode,
+		"error_description": description,
+	})
+}
+
+var consentPage = template.Must(template.New("consent").Parse(`
+<html>
+
+<body>
+	<h1>Authorize {{.ClientName}}</h1>
+	<p>{{.ClientName}} wants to access your Chirpy account and be able to:</p>
+	<ul>
+		{{range .Scopes}}<li>{{.}}</li>{{end}}
+	</ul>
+	{{if .Error}}<p style="color: red">{{.Error}}</p>{{end}}
+	<form method="post">
+		{{range $k, $v := .Params}}<input type="hidden" name="{{$k}}" value="{{$v}}">
+		{{end}}
+		<p><input type="email" name="email" placeholder="Email" value="{{.Email}}"></p>
+		<p><input type="password" name="password" placeholder="Password"></p>
+		<p><input type="text" name="code" placeholder="Authentication code, if you have 2FA enabled" autocomplete="one-time-code"></p>
+		<button type="submit" name="decision" value="allow">Allow</button>
+		<button type="submit" name="decision" value="deny">Deny</button>
+	</form>
+</body>
+
+</html>
+`))
+
+func renderConsentPage(w http.ResponseWriter, code int, ar authorizeRequest, v url.Values, errMsg string) {
+	params := make(map[string]string)
+	for _, k := range []string{"response_type", "client_id", "redirect_uri", "scope", "state", "code_challenge", "code_challenge_method"} {
+		if v.Get(k) != "" {
+			params[k] = v.Get(k)
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/html")
+	// the page takes credentials, it mustn't be framed by the client
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("Content-Security-Policy", "frame-ancestors 'none'")
+	w.WriteHeader(code)
+
+	err := consentPage.Execute(w, struct {
+		ClientName string
+		Scopes     []string
+		Params     map[string]string
+		Email      string
+		Error      string
+	}{
+		ClientName: ar.Client.Name,
+		Scopes:     ar.Scopes,
+		Params:     params,
+		Email:      v.Get("email"),
+		Error:      errMsg,
+	})
+	if err != nil {
+		log.Printf("couldn't render consent page: %s", err.Error())
+	}
+}
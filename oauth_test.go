@@ -0,0 +1,339 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hatrnuhn/chirpy-webserver/internal/auth"
+)
+
+const (
+	testRedirectURI  = "https://client.example/callback"
+	testCodeVerifier = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+)
+
+type oauthTokens struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	Scope        string `json:"scope"`
+	Error        string `json:"error"`
+}
+
+// registers a public client owned by the user and returns its ID
+func createTestClient(t *testing.T, cfg *apiConfig, h http.Handler, ownerID int) string {
+	t.Helper()
+
+	w := doRequest(t, h, http.MethodPost, "/api/oauth/clients", testAccessToken(t, cfg, ownerID), map[string]any{
+		"name":          "test client",
+		"redirect_uris": []string{testRedirectURI},
+	})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("couldn't create client: %d %s", w.Code, w.Body.String())
+	}
+
+	c := oauthClientResponse{}
+	decodeBody(t, w, &c)
+	return c.ClientID
+}
+
+// approves an authorization request for the client as the user with testPassword and returns the code.
+// params are added to the request's
+func authorizeTestClient(t *testing.T, h http.Handler, clientID, email string, params url.Values) string {
+	t.Helper()
+
+	v := url.Values{
+		"client_id":             {clientID},
+		"response_type":         {"code"},
+		"code_challenge":        {auth.PKCEChallenge(testCodeVerifier)},
+		"code_challenge_method": {"S256"},
+		"decision":              {"allow"},
+		"email":                 {email},
+		"password":              {testPassword},
+	}
+	for k, vs := range params {
+		v[k] = vs
+	}
+
+	w := postForm(t, h, "/api/oauth/authorize", v)
+	if w.Code != http.StatusFound {
+		t.Fatalf("couldn't authorize: %d %s", w.Code, w.Body.String())
+	}
+
+	loc, err := url.Parse(w.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := loc.Query().Get("code")
+	if code == "" {
+		t.Fatalf("no code in %s", loc)
+	}
+
+	return code
+}
+
+func exchangeCode(t *testing.T, h http.Handler, clientID, code string, params url.Values) (int, oauthTokens) {
+	t.Helper()
+
+	v := url.Values{
+		"grant_type":    {"authorization_code"},
+		"client_id":     {clientID},
+		"code":          {code},
+		"code_verifier": {testCodeVerifier},
+	}
+	for k, vs := range params {
+		v[k] = vs
+	}
+
+	w := postForm(t, h, "/api/oauth/token", v)
+	tokens := oauthTokens{}
+	decodeBody(t, w, &tokens)
+	return w.Code, tokens
+}
+
+func refreshTokens(t *testing.T, h http.Handler, clientID, rt, scope string) (int, oauthTokens) {
+	t.Helper()
+
+	v := url.Values{
+		"grant_type":    {"refresh_token"},
+		"client_id":     {clientID},
+		"refresh_token": {rt},
+	}
+	if scope != "" {
+		v.Set("scope", scope)
+	}
+
+	w := postForm(t, h, "/api/oauth/token", v)
+	tokens := oauthTokens{}
+	decodeBody(t, w, &tokens)
+	return w.Code, tokens
+}
+
+// sets up a user and a client and returns tokens the client got for the user with scope
+func issueTestTokens(t *testing.T, scope string) (*apiConfig, http.Handler, string, oauthTokens) {
+	t.Helper()
+
+	cfg := newTestConfig(t)
+	h := cfg.routes()
+	u := createTestUser(t, cfg, "walt@example.com")
+	clientID := createTestClient(t, cfg, h, u.ID)
+
+	code := authorizeTestClient(t, h, clientID, u.Email, url.Values{"scope": {scope}})
+	status, tokens := exchangeCode(t, h, clientID, code, nil)
+	if status != http.StatusOK {
+		t.Fatalf("couldn't exchange code: %d %+v", status, tokens)
+	}
+
+	return cfg, h, clientID, tokens
+}
+
+func TestOAuthPKCE(t *testing.T) {
+	cfg := newTestConfig(t)
+	h := cfg.routes()
+	u := createTestUser(t, cfg, "walt@example.com")
+	clientID := createTestClient(t, cfg, h, u.ID)
+
+	code := authorizeTestClient(t, h, clientID, u.Email, nil)
+	status, tokens := exchangeCode(t, h, clientID, code, url.Values{"code_verifier": {strings.Repeat("x", 43)}})
+	if status != http.StatusBadRequest || tokens.Error != "invalid_grant" {
+		t.Fatalf("wrong verifier: got %d %+v, want invalid_grant", status, tokens)
+	}
+
+	code = authorizeTestClient(t, h, clientID, u.Email, nil)
+	status, tokens = exchangeCode(t, h, clientID, code, nil)
+	if status != http.StatusOK || tokens.AccessToken == "" {
+		t.Fatalf("right verifier: got %d %+v", status, tokens)
+	}
+}
+
+func TestOAuthPublicClientNeedsPKCE(t *testing.T) {
+	cfg := newTestConfig(t)
+	h := cfg.routes()
+	u := createTestUser(t, cfg, "walt@example.com")
+	clientID := createTestClient(t, cfg, h, u.ID)
+
+	w := postForm(t, h, "/api/oauth/authorize", url.Values{
+		"client_id":     {clientID},
+		"response_type": {"code"},
+		"decision":      {"allow"},
+		"email":         {u.Email},
+		"password":      {testPassword},
+	})
+	loc, err := url.Parse(w.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loc.Query().Get("error") != "invalid_request" || loc.Query().Get("code") != "" {
+		t.Fatalf("got %d redirect to %s, want invalid_request", w.Code, loc)
+	}
+}
+
+func TestOAuthCodeReuse(t *testing.T) {
+	cfg := newTestConfig(t)
+	h := cfg.routes()
+	u := createTestUser(t, cfg, "walt@example.com")
+	clientID := createTestClient(t, cfg, h, u.ID)
+
+	code := authorizeTestClient(t, h, clientID, u.Email, nil)
+	status, _ := exchangeCode(t, h, clientID, code, nil)
+	if status != http.StatusOK {
+		t.Fatalf("first exchange: got %d", status)
+	}
+
+	status, tokens := exchangeCode(t, h, clientID, code, nil)
+	if status != http.StatusBadRequest || tokens.Error != "invalid_grant" {
+		t.Fatalf("second exchange: got %d %+v, want invalid_grant", status, tokens)
+	}
+}
+
+func TestOAuthRedirectURIMustMatch(t *testing.T) {
+	cfg := newTestConfig(t)
+	h := cfg.routes()
+	u := createTestUser(t, cfg, "walt@example.com")
+	clientID := createTestClient(t, cfg, h, u.ID)
+
+	tests := []struct {
+		name      string
+		requested string
+		exchanged string
+		want      int
+	}{
+		{"repeated", testRedirectURI, testRedirectURI, http.StatusOK},
+		{"left out", testRedirectURI, "", http.StatusBadRequest},
+		{"different", testRedirectURI, "https://client.example/other", http.StatusBadRequest},
+		{"registered default", "", "", http.StatusOK},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			params := url.Values{}
+			if tc.requested != "" {
+				params.Set("redirect_uri", tc.requested)
+			}
+			code := authorizeTestClient(t, h, clientID, u.Email, params)
+
+			params = url.Values{}
+			if tc.exchanged != "" {
+				params.Set("redirect_uri", tc.exchanged)
+			}
+			status, tokens := exchangeCode(t, h, clientID, code, params)
+			if status != tc.want {
+				t.Fatalf("got %d %+v, want %d", status, tokens, tc.want)
+			}
+		})
+	}
+}
+
+func TestOAuthRefreshScopeNarrowing(t *testing.T) {
+	_, h, clientID, tokens := issueTestTokens(t, auth.ScopeChirpsRead+" "+auth.ScopeChirpsWrite)
+
+	status, narrowed := refreshTokens(t, h, clientID, tokens.RefreshToken, auth.ScopeChirpsRead)
+	if status != http.StatusOK || narrowed.Scope != auth.ScopeChirpsRead {
+		t.Fatalf("narrowing: got %d %+v", status, narrowed)
+	}
+
+	// the narrowed grant is all the new refresh token carries
+	status, widened := refreshTokens(t, h, clientID, narrowed.RefreshToken, auth.ScopeChirpsRead+" "+auth.ScopeChirpsWrite)
+	if status != http.StatusBadRequest || widened.Error != "invalid_scope" {
+		t.Fatalf("widening: got %d %+v, want invalid_scope", status, widened)
+	}
+}
+
+func TestOAuthRefreshRotation(t *testing.T) {
+	_, h, clientID, tokens := issueTestTokens(t, auth.ScopeChirpsRead)
+
+	status, rotated := refreshTokens(t, h, clientID, tokens.RefreshToken, "")
+	if status != http.StatusOK {
+		t.Fatalf("first refresh: got %d %+v", status, rotated)
+	}
+
+	status, reused := refreshTokens(t, h, clientID, tokens.RefreshToken, "")
+	if status != http.StatusBadRequest || reused.Error != "invalid_grant" {
+		t.Fatalf("reused refresh: got %d %+v, want invalid_grant", status, reused)
+	}
+
+	// racing requests with the same refresh token can't all get new tokens
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	succeeded := 0
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v := url.Values{
+				"grant_type":    {"refresh_token"},
+				"client_id":     {clientID},
+				"refresh_token": {rotated.RefreshToken},
+			}
+			w := postForm(t, h, "/api/oauth/token", v)
+			if w.Code == http.StatusOK {
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if succeeded != 1 {
+		t.Fatalf("%d concurrent refreshes succeeded, want 1", succeeded)
+	}
+}
+
+func TestOAuthRevokeAndIntrospect(t *testing.T) {
+	cfg, h, clientID, tokens := issueTestTokens(t, auth.ScopeChirpsRead)
+
+	introspect := func(token string) (active bool, scope string) {
+		t.Helper()
+
+		w := postForm(t, h, "/api/oauth/introspect", url.Values{"client_id": {clientID}, "token": {token}})
+		resp := struct {
+			Active bool   `json:"active"`
+			Scope  string `json:"scope"`
+		}{}
+		decodeBody(t, w, &resp)
+		return resp.Active, resp.Scope
+	}
+
+	for _, token := range []string{tokens.AccessToken, tokens.RefreshToken} {
+		active, scope := introspect(token)
+		if !active || scope != auth.ScopeChirpsRead {
+			t.Fatalf("fresh token: got active %v scope %q", active, scope)
+		}
+	}
+
+	// tokens issued to other clients look inactive
+	u, err := cfg.db.GetUser(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherClient := createTestClient(t, cfg, h, u.ID)
+	w := postForm(t, h, "/api/oauth/introspect", url.Values{"client_id": {otherClient}, "token": {tokens.AccessToken}})
+	if !strings.Contains(w.Body.String(), `"active":false`) {
+		t.Fatalf("other client's introspection: got %s", w.Body.String())
+	}
+
+	for _, token := range []string{tokens.AccessToken, tokens.RefreshToken} {
+		w := postForm(t, h, "/api/oauth/revoke", url.Values{"client_id": {clientID}, "token": {token}})
+		if w.Code != http.StatusOK {
+			t.Fatalf("revoke: got %d %s", w.Code, w.Body.String())
+		}
+
+		active, _ := introspect(token)
+		if active {
+			t.Fatal("revoked token is still active")
+		}
+	}
+
+	w = doRequest(t, h, http.MethodGet, "/api/users/me", tokens.AccessToken, nil)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("revoked access token: got %d, want 401", w.Code)
+	}
+
+	status, refreshed := refreshTokens(t, h, clientID, tokens.RefreshToken, "")
+	if status != http.StatusBadRequest || refreshed.Error != "invalid_grant" {
+		t.Fatalf("revoked refresh token: got %d %+v, want invalid_grant", status, refreshed)
+	}
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/hatrnuhn/chirpy-webserver/internal/auth"
+	"github.com/hatrnuhn/chirpy-webserver/internal/database"
+	"github.com/hatrnuhn/chirpy-webserver/internal/oidc"
+)
+
+// how long users have to sign in at the provider
+const oidcLoginTTL = 10 * time.Minute
+
+const oidcStateCookie = "chirpy_oidc_state"
+
+// a sign in started at the provider, keyed by its state
+type oidcLogin struct {
+	nonce     string
+	verifier  string
+	expiresAt time.Time
+}
+
+// sign ins in progress, they're short lived so they're kept in memory rather than the db
+type oidcLogins struct {
+	mux    *sync.Mutex
+	logins map[string]oidcLogin
+}
+
+func newOIDCLogins() *oidcLogins {
+	return &oidcLogins{
+		mux:    &sync.Mutex{},
+		logins: make(map[string]oidcLogin),
+	}
+}
+
+func (l *oidcLogins) put(state string, login oidcLogin) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	now := time.Now()
+	for s, lg := range l.logins {
+		if now.After(lg.expiresAt) {
+			delete(l.logins, s)
+		}
+	}
+
+	l.logins[state] = login
+}
+
+// removes and returns the unexpired sign in of state, so that each can only be completed once
+func (l *oidcLogins) take(state string) (oidcLogin, bool) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	login, ok := l.logins[state]
+	delete(l.logins, state)
+
+	if !ok || time.Now().After(login.expiresAt) {
+		return oidcLogin{}, false
+	}
+
+	return login, true
+}
+
+// starts signing in with the OpenID Connect provider by redirecting to it
+func (cfg *apiConfig) handleGetOIDCLogin(w http.ResponseWriter, r *http.Request) {
+	if cfg.oidc == nil {
+		respondWithError(w, http.StatusNotFound, "OIDC login isn't configured")
+		return
+	}
+
+	state, err := auth.MakeRandomToken(32)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't create state")
+		return
+	}
+
+	nonce, err := auth.MakeRandomToken(32)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't create nonce")
+		return
+	}
+
+	// 64 hex characters, within the 43 to 128 RFC 7636 allows
+	verifier, err := auth.MakeRandomToken(32)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't create code verifier")
+		return
+	}
+
+	authURL, err := cfg.oidc.AuthCodeURL(r.Context(), state, nonce, auth.PKCEChallenge(verifier))
+	if err != nil {
+		respondWithError(w, http.StatusBadGateway, fmt.Sprintf("couldn't reach OIDC provider: %s", err.Error()))
+		return
+	}
+
+	cfg.oidcLogins.put(state, oidcLogin{
+		nonce:     nonce,
+		verifier:  verifier,
+		expiresAt: time.Now().Add(oidcLoginTTL),
+	})
+
+	// ties the sign in to this browser, so nobody can get someone else's browser to complete theirs
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		Path:     "/api/oidc",
+		MaxAge:   int(oidcLoginTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   cfg.secureCookies(),
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// finishes signing in with the OpenID Connect provider, links or creates the user of the
+// verified email and responds like /api/login does, with an MFA challenge if the user has two-factor sign in
+func (cfg *apiConfig) handleGetOIDCCallback(w http.ResponseWriter, r *http.Request) {
+	if cfg.oidc == nil {
+		respondWithError(w, http.StatusNotFound, "OIDC login isn't configured")
+		return
+	}
+
+	q := r.URL.Query()
+	http.SetCookie(w, &http.Cookie{Name: oidcStateCookie, Path: "/api/oidc", MaxAge: -1})
+
+	state := q.Get("state")
+	cookie, err := r.Cookie(oidcStateCookie)
+	if err != nil || state == "" || cookie.Value != state {
+		respondWithError(w, http.StatusBadRequest, "state doesn't match this browser's sign in")
+		return
+	}
+
+	login, ok := cfg.oidcLogins.take(state)
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "sign in expired, start over")
+		return
+	}
+
+	if e := q.Get("error"); e != "" {
+		respondWithError(w, http.StatusUnauthorized, fmt.Sprintf("OIDC provider refused sign in: %s %s", e, q.Get("error_description")))
+		return
+	}
+
+	claims, err := cfg.oidc.Exchange(r.Context(), q.Get("code"), login.verifier, login.nonce)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, fmt.Sprintf("couldn't verify sign in: %s", err.Error()))
+		return
+	}
+
+	if claims.Email == "" || !claims.EmailVerified {
+		respondWithError(w, http.StatusForbidden, "OIDC provider didn't verify an email")
+		return
+	}
+
+	// users created here sign in through the provider, they can set a password through /api/password/forgot
+	password, err := auth.MakeRandomToken(32)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't create password")
+		return
+	}
+
+	user, created, err := cfg.db.LinkOIDCUser(claims.Issuer, claims.Subject, claims.Email, password)
+	if errors.Is(err, database.ErrIdentityConflict) {
+		respondWithError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("couldn't link user: %s", err.Error()))
+		return
+	}
+
+	if created {
+		log.Printf("created user %d for OIDC subject %s", user.ID, claims.Subject)
+	}
+
+	// the provider only stands in for the password, users with two-factor sign in still need their code
+	cfg.respondWithLogin(w, user, 0, false)
+}
+
+// configures signing in with an OpenID Connect provider when OIDC_ISSUER is set
+func newOIDCProvider(baseURL string) (*oidc.Provider, error) {
+	issuer := os.Getenv("OIDC_ISSUER")
+	if issuer == "" {
+		return nil, nil
+	}
+
+	clientID := os.Getenv("OIDC_CLIENT_ID")
+	if clientID == "" {
+		return nil, errors.New("OIDC_CLIENT_ID isn't set")
+	}
+
+	redirectURL := os.Getenv("OIDC_REDIRECT_URL")
+	if redirectURL == "" {
+		redirectURL = baseURL + "/api/oidc/callback"
+	}
+
+	return oidc.NewProvider(oidc.Config{
+		Issuer:       issuer,
+		ClientID:     clientID,
+		ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+		RedirectURL:  redirectURL,
+	}), nil
+}
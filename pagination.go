@@ -0,0 +1,47 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+// reads the limit and offset query parameters of a paginated list
+func parsePage(r *http.Request) (limit, offset int, err error) {
+	limit = defaultPageLimit
+	if s := r.URL.Query().Get("limit"); s != "" {
+		limit, err = strconv.Atoi(s)
+		if err != nil || limit < 1 || limit > maxPageLimit {
+			return 0, 0, errors.New("limit must be between 1 and 100")
+		}
+	}
+
+	if s := r.URL.Query().Get("offset"); s != "" {
+		offset, err = strconv.Atoi(s)
+		if err != nil || offset < 0 {
+			return 0, 0, errors.New("offset can't be negative")
+		}
+	}
+
+	return limit, offset, nil
+}
+
+// returns the bounds of the page of a list of n items
+func pageBounds(n, limit, offset int) (start, end int) {
+	start = offset
+	if start > n {
+		start = n
+	}
+
+	end = start + limit
+	if end > n {
+		end = n
+	}
+
+	return start, end
+}
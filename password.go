@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/hatrnuhn/chirpy-webserver/internal/auth"
+	"github.com/hatrnuhn/chirpy-webserver/internal/database"
+	"github.com/hatrnuhn/chirpy-webserver/internal/mailer"
+)
+
+const passwordResetTTL = 15 * time.Minute
+
+// accepts an email and mails a password reset link if it belongs to a user, responds with 202 either way so
+// it can't be used to find out which emails are registered. too many requests for an email get a 429
+func (cfg *apiConfig) handlePostPasswordForgot(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	dat, err := io.ReadAll(r.Body)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "couldn't read request")
+		return
+	}
+
+	req := struct {
+		Email string `json:"email"`
+	}{}
+	err = json.Unmarshal(dat, &req)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "couldn't unmarshal request")
+		return
+	}
+
+	if req.Email == "" {
+		respondWithError(w, http.StatusBadRequest, "email is required")
+		return
+	}
+
+	// registered or not, so the throttle doesn't give them away
+	key := loginAccountKey(req.Email)
+	wait, ok := cfg.passwordResetThrottle.Allow(key)
+	if !ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		respondWithError(w, http.StatusTooManyRequests, "too many password resets requested, try again later")
+		return
+	}
+	cfg.passwordResetThrottle.Fail(key)
+
+	// done in the background so response times don't tell registered emails apart
+	go cfg.sendPasswordReset(req.Email)
+
+	w.WriteHeader(http.StatusAccepted)
+	w.Write([]byte("Accepted"))
+}
+
+func (cfg *apiConfig) sendPasswordReset(email string) {
+	user, err := cfg.db.GetUserByEmail(email)
+	if errors.Is(err, database.ErrNotExist) {
+		return
+	}
+	if err != nil {
+		log.Printf("password reset: couldn't get user: %s", err.Error())
+		return
+	}
+
+	token, err := auth.MakeRandomToken(32)
+	if err != nil {
+		log.Printf("password reset: couldn't create token: %s", err.Error())
+		return
+	}
+
+	err = cfg.db.CreatePasswordReset(auth.HashToken(token), user.ID, time.Now().Add(passwordResetTTL).Unix())
+	if err != nil {
+		log.Printf("password reset: couldn't write reset to db: %s", err.Error())
+		return
+	}
+
+	err = cfg.mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your Chirpy password",
+		Body: fmt.Sprintf(
+			"Someone asked to reset the password of your Chirpy account.\r\n\r\n"+
+				"Use this token within %v to choose a new one:\r\n\r\n%s\r\n\r\n"+
+				"or open %s/app/?reset_token=%s\r\n\r\n"+
+				"If it wasn't you, you can ignore this email.",
+			passwordResetTTL, token, cfg.baseURL, token,
+		),
+	})
+	if err != nil {
+		log.Printf("password reset: couldn't send email: %s", err.Error())
+	}
+}
+
+// redeems a password reset token, sets the new password and signs the user out of every session
+func (cfg *apiConfig) handlePostPasswordReset(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	dat, err := io.ReadAll(r.Body)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "couldn't read request")
+		return
+	}
+
+	req := struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}{}
+	err = json.Unmarshal(dat, &req)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "couldn't unmarshal request")
+		return
+	}
+
+	if req.Token == "" || req.Password == "" {
+		respondWithError(w, http.StatusBadRequest, "token and password are required")
+		return
+	}
+
+	// the token is only used up once the new password is accepted
+	tokenHash := auth.HashToken(req.Token)
+	pr, err := cfg.db.GetPasswordReset(tokenHash)
+	if err != nil || pr.UsedAt != 0 || pr.ExpiresAt < time.Now().Unix() {
+		respondWithError(w, http.StatusUnauthorized, "invalid or expired reset token")
+		return
+	}
+
+	user, err := cfg.db.GetUser(pr.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("couldn't get user: %s", err.Error()))
+		return
+	}
+
+	if !cfg.checkPasswordPolicy(w, req.Password, user.Email) {
+		return
+	}
+
+	_, err = cfg.db.ConsumePasswordReset(tokenHash)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid or expired reset token")
+		return
+	}
+
+	user.Password = req.Password
+	_, err = cfg.db.UpdateUser(&user, true)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("couldn't update user: %s", err.Error()))
+		return
+	}
+
+	err = cfg.logOutEverywhere(user.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("couldn't revoke tokens: %s", err.Error()))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("OK"))
+}
+
+// checks password against the policy, if it breaks any rule responds with 400 and every violation and returns false
+func (cfg *apiConfig) checkPasswordPolicy(w http.ResponseWriter, password, email string) bool {
+	err := cfg.passwordPolicy.Check(password, email)
+	if err == nil {
+		return true
+	}
+
+	var pErr *auth.PolicyError
+	if !errors.As(err, &pErr) {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("couldn't check password: %s", err.Error()))
+		return false
+	}
+
+	respondWithJSON(w, http.StatusBadRequest, struct {
+		Error      string                 `json:"error"`
+		Violations []auth.PolicyViolation `json:"violations"`
+	}{
+		Error:      "password doesn't meet the policy",
+		Violations: pErr.Violations,
+	})
+	return false
+}
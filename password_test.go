@@ -0,0 +1,116 @@
+package main
+
+import (
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/hatrnuhn/chirpy-webserver/internal/mailer"
+)
+
+var resetTokenRe = regexp.MustCompile(`reset_token=(\S+)`)
+
+// waits for the nth email to be sent through the test outbox and returns it
+func waitForMail(t *testing.T, cfg *apiConfig, n int) mailer.Message {
+	t.Helper()
+
+	outbox := cfg.mailer.(*mailer.Outbox)
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if msgs := outbox.Messages(); len(msgs) >= n {
+			return msgs[n-1]
+		}
+	}
+
+	t.Fatalf("email %d wasn't sent", n)
+	return mailer.Message{}
+}
+
+func TestPasswordReset(t *testing.T) {
+	cfg := newTestConfig(t)
+	h := cfg.routes()
+	u := createTestUser(t, cfg, "walt@example.com")
+	oldToken := loginTestUser(t, h, u.Email).AccessToken
+
+	w := doRequest(t, h, http.MethodPost, "/api/password/forgot", "", map[string]string{"email": "nobody@example.com"})
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("for an unknown email got %d %q, want %d", w.Code, w.Body.String(), http.StatusAccepted)
+	}
+
+	w = doRequest(t, h, http.MethodPost, "/api/password/forgot", "", map[string]string{"email": u.Email})
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("got %d %q, want %d", w.Code, w.Body.String(), http.StatusAccepted)
+	}
+
+	msg := waitForMail(t, cfg, 1)
+	if msg.To != u.Email {
+		t.Fatalf("reset was mailed to %s, want %s", msg.To, u.Email)
+	}
+	m := resetTokenRe.FindStringSubmatch(msg.Body)
+	if m == nil {
+		t.Fatalf("no reset token in %q", msg.Body)
+	}
+	token := m[1]
+
+	const newPassword = "an0ther-Chirp-at-dusk"
+	tests := []struct {
+		name     string
+		token    string
+		password string
+		want     int
+	}{
+		{"wrong token", "not-the-token", newPassword, http.StatusUnauthorized},
+		{"weak password", token, "short", http.StatusBadRequest},
+		{"new password", token, newPassword, http.StatusOK},
+		{"token used again", token, "yet-An0ther-chirp", http.StatusUnauthorized},
+	}
+
+	for _, tc := range tests {
+		w = doRequest(t, h, http.MethodPost, "/api/password/reset", "", map[string]string{"token": tc.token, "password": tc.password})
+		if w.Code != tc.want {
+			t.Fatalf("%s: got %d %q, want %d", tc.name, w.Code, w.Body.String(), tc.want)
+		}
+	}
+
+	w = doRequest(t, h, http.MethodPost, "/api/login", "", map[string]string{"email": u.Email, "password": testPassword})
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("logging in with the old password got %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+	w = doRequest(t, h, http.MethodPost, "/api/login", "", map[string]string{"email": u.Email, "password": newPassword})
+	if w.Code != http.StatusOK {
+		t.Errorf("logging in with the new password got %d %q, want %d", w.Code, w.Body.String(), http.StatusOK)
+	}
+
+	// the reset signs the user out everywhere
+	w = doRequest(t, h, http.MethodGet, "/api/users/me", oldToken, nil)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("AJWT from before the reset got %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestPasswordForgotThrottle(t *testing.T) {
+	cfg := newTestConfig(t)
+	h := cfg.routes()
+
+	// newTestConfig backs off after the 3 free requests and the one past them
+	for i := 0; i < 4; i++ {
+		w := doRequest(t, h, http.MethodPost, "/api/password/forgot", "", map[string]string{"email": "walt@example.com"})
+		if w.Code != http.StatusAccepted {
+			t.Fatalf("request %d got %d %q, want %d", i+1, w.Code, w.Body.String(), http.StatusAccepted)
+		}
+	}
+
+	w := doRequest(t, h, http.MethodPost, "/api/password/forgot", "", map[string]string{"email": "Walt@example.com"})
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("got %d %q, want %d", w.Code, w.Body.String(), http.StatusTooManyRequests)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("429 has no Retry-After")
+	}
+
+	// other emails aren't held back
+	w = doRequest(t, h, http.MethodPost, "/api/password/forgot", "", map[string]string{"email": "jesse@example.com"})
+	if w.Code != http.StatusAccepted {
+		t.Errorf("another email got %d %q, want %d", w.Code, w.Body.String(), http.StatusAccepted)
+	}
+}
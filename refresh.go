@@ -5,25 +5,36 @@ import (
 	"net/http"
 	"strconv"
 
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/hatrnuhn/chirpy-webserver/internal/auth"
 )
 
-// refreshes access token using refresh token
+// refreshes access token using refresh token, the one in the refresh cookie for session cookie requests
 func (cfg *apiConfig) handlePostRefresh(w http.ResponseWriter, r *http.Request) {
-	rToken, err := auth.ParseReq(r, cfg.jwtSecret, "Bearer")
+	tokenString, err := refreshTokenString(r)
 	if err != nil {
 		respondWithError(w, http.StatusUnauthorized, err.Error())
 		return
 	}
 
-	if claims, ok := rToken.Claims.(*jwt.RegisteredClaims); ok {
+	rToken, err := auth.ParseToken(tokenString, cfg.jwtSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	if claims, ok := rToken.Claims.(*auth.Claims); ok {
 		// verifs RJWT
 		if claims.Issuer != "chirpy-refresh" {
 			respondWithError(w, http.StatusUnauthorized, "invalid RJWT")
 			return
 		}
 
+		// third-party RJWTs are refreshed through /api/oauth/token so they stay scoped
+		if claims.ClientID != "" {
+			respondWithError(w, http.StatusUnauthorized, "RJWT was issued to an OAuth client")
+			return
+		}
+
 		// verifs RJWT expiration
 		isNotExp, err := cfg.db.RJWTNotExp(rToken.Raw)
 		if err != nil {
@@ -43,13 +54,27 @@ func (cfg *apiConfig) handlePostRefresh(w http.ResponseWriter, r *http.Request)
 			return
 		}
 
+		// the role and plan are read again so changes apply on the next refresh
+		user, err := cfg.checkTokenRevocation(claims)
+		if err != nil {
+			respondWithError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+
 		secsInHour := 3600
-		aToken, err := auth.CreateAccessToken(userId, cfg.jwtSecret, int64(secsInHour))
+		aToken, err := auth.CreateAccessToken(userId, user.Role, auth.PlanOf(user.IsChirpyRed), user.TokenGeneration, cfg.jwtSecret, int64(secsInHour))
 		if err != nil {
 			respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("couldn't create AJWT: %s", err.Error()))
 			return
 		}
 
+		if usesSessionCookies(r) {
+			cfg.setAccessCookie(w, aToken, secsInHour)
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte("OK"))
+			return
+		}
+
 		// responds with AJWT
 		respondWithJSON(w, http.StatusOK, aToken)
 
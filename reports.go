@@ -0,0 +1,258 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi"
+	"github.com/hatrnuhn/chirpy-webserver/internal/auth"
+	"github.com/hatrnuhn/chirpy-webserver/internal/database"
+)
+
+// how long the details users give along with a report's reason can be
+const maxReportDetails = 500
+
+type reportReq struct {
+	Reason  string `json:"reason"`
+	Details string `json:"details"`
+}
+
+// reports the chirp to the moderators as the authenticated user
+func (cfg *apiConfig) handlePostChirpReports(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	id, err := strconv.Atoi(chi.URLParam(r, "chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "bad url")
+		return
+	}
+
+	p, err := cfg.authenticate(r, auth.ScopeChirpsWrite)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	req, ok := readReportReq(w, r)
+	if !ok {
+		return
+	}
+
+	rep, err := cfg.db.ReportChirp(p.UserID, id, req.Reason, req.Details)
+	if errors.Is(err, database.ErrNotExist) {
+		respondWithError(w, http.StatusNotFound, fmt.Sprintf("chirp with id: %v is not found", id))
+		return
+	}
+	respondWithReport(w, rep, err)
+}
+
+// reports the user to the moderators as the authenticated user
+func (cfg *apiConfig) handlePostUserReports(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	id, err := strconv.Atoi(chi.URLParam(r, "userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "bad url")
+		return
+	}
+
+	p, err := cfg.authenticate(r, auth.ScopeProfileWrite)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	req, ok := readReportReq(w, r)
+	if !ok {
+		return
+	}
+
+	rep, err := cfg.db.ReportUser(p.UserID, id, req.Reason, req.Details)
+	if errors.Is(err, database.ErrNotExist) {
+		respondWithError(w, http.StatusNotFound, fmt.Sprintf("user with id: %v is not found", id))
+		return
+	}
+	respondWithReport(w, rep, err)
+}
+
+// reads and validates a report's reason and details, responding with an error if they're not ok
+func readReportReq(w http.ResponseWriter, r *http.Request) (reportReq, bool) {
+	dat, err := io.ReadAll(r.Body)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "couldn't read request")
+		return reportReq{}, false
+	}
+
+	req := reportReq{}
+	err = json.Unmarshal(dat, &req)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "couldn't unmarshal request")
+		return reportReq{}, false
+	}
+
+	if !database.ValidReportReason(req.Reason) {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("reason must be one of %v", database.ReportReasons))
+		return reportReq{}, false
+	}
+
+	if utf8.RuneCountInString(req.Details) > maxReportDetails {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("details can't be longer than %d characters", maxReportDetails))
+		return reportReq{}, false
+	}
+
+	return req, true
+}
+
+// responds with the report that was just filed or with what kept it from being filed
+func respondWithReport(w http.ResponseWriter, rep database.Report, err error) {
+	switch {
+	case errors.Is(err, database.ErrSelfReport):
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, database.ErrAlreadyReported):
+		respondWithError(w, http.StatusConflict, err.Error())
+		return
+	case err != nil:
+		respondWithError(w, http.StatusInternalServerError, "couldn't report")
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, rep)
+}
+
+// responds with a page of the reports with the status query parameter, open by default
+func (cfg *apiConfig) handleGetAdminReports(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "":
+		status = database.ReportOpen
+	case database.ReportOpen, database.ReportClaimed, database.ReportResolved:
+	default:
+		respondWithError(w, http.StatusBadRequest, "status must be open, claimed or resolved")
+		return
+	}
+
+	limit, offset, err := parsePage(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	reports, err := cfg.db.GetReports(status)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't get reports")
+		return
+	}
+
+	start, end := pageBounds(len(reports), limit, offset)
+	respondWithJSON(w, http.StatusOK, struct {
+		Total   int               `json:"total"`
+		Reports []database.Report `json:"reports"`
+	}{
+		Total:   len(reports),
+		Reports: reports[start:end],
+	})
+}
+
+// claims the report for the authenticated moderator
+func (cfg *apiConfig) handlePostAdminReportClaim(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "reportID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "bad url")
+		return
+	}
+
+	p, ok := principalFromContext(r)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "couldn't authenticate")
+		return
+	}
+
+	rep, err := cfg.db.ClaimReport(id, p.UserID)
+	if err != nil {
+		respondWithReportReviewError(w, id, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, rep)
+}
+
+// resolves the report by dismissing it, deleting the reported chirp or suspending the reported user
+func (cfg *apiConfig) handlePostAdminReportResolve(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	id, err := strconv.Atoi(chi.URLParam(r, "reportID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "bad url")
+		return
+	}
+
+	p, ok := principalFromContext(r)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "couldn't authenticate")
+		return
+	}
+
+	dat, err := io.ReadAll(r.Body)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "couldn't read request")
+		return
+	}
+
+	req := struct {
+		Action string `json:"action"`
+	}{}
+	err = json.Unmarshal(dat, &req)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "couldn't unmarshal request")
+		return
+	}
+
+	if !database.ValidReportAction(req.Action) {
+		respondWithError(w, http.StatusBadRequest, "action must be dismiss, delete_chirp or suspend_user")
+		return
+	}
+
+	if req.Action == database.ReportSuspendUser {
+		rep, err := cfg.db.GetReport(id)
+		if err != nil {
+			respondWithReportReviewError(w, id, err)
+			return
+		}
+
+		// staff are managed by admins through roles, not by each other through reports
+		u, err := cfg.db.GetUser(rep.UserID)
+		if err == nil && auth.HasRole(u.Role, auth.RoleModerator) {
+			respondWithError(w, http.StatusForbidden, "moderators and admins can't be suspended")
+			return
+		}
+	}
+
+	rep, media, err := cfg.db.ResolveReport(id, p.UserID, req.Action)
+	if err != nil {
+		respondWithReportReviewError(w, id, err)
+		return
+	}
+
+	cfg.removeMediaBlobs(r.Context(), media)
+
+	respondWithJSON(w, http.StatusOK, rep)
+}
+
+func respondWithReportReviewError(w http.ResponseWriter, id int, err error) {
+	switch {
+	case errors.Is(err, database.ErrNotExist):
+		respondWithError(w, http.StatusNotFound, fmt.Sprintf("report with id: %v is not found", id))
+	case errors.Is(err, database.ErrReportClaimed), errors.Is(err, database.ErrReportResolved):
+		respondWithError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, database.ErrInvalidReportAction):
+		respondWithError(w, http.StatusBadRequest, err.Error())
+	default:
+		respondWithError(w, http.StatusInternalServerError, "couldn't review report")
+	}
+}
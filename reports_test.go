@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/hatrnuhn/chirpy-webserver/internal/auth"
+	"github.com/hatrnuhn/chirpy-webserver/internal/database"
+)
+
+func TestAdminReports(t *testing.T) {
+	cfg := newTestConfig(t)
+	h := cfg.routes()
+	author := createTestUser(t, cfg, "walt@example.com")
+	reporter := createTestUser(t, cfg, "hank@example.com")
+	mod := createTestUser(t, cfg, "marie@example.com")
+	setTestRole(t, cfg, mod.ID, auth.RoleModerator)
+
+	c, err := cfg.db.CreateChirp(database.Chirp{Body: "blue sky for sale"}, author.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := doRequest(t, h, http.MethodPost, fmt.Sprintf("/api/chirps/%d/reports", c.ID), testAccessToken(t, cfg, reporter.ID), map[string]string{
+		"reason": database.ReportSpam,
+	})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("report: got %d %s", w.Code, w.Body.String())
+	}
+	rep := database.Report{}
+	decodeBody(t, w, &rep)
+
+	w = doRequest(t, h, http.MethodGet, "/admin/reports", testAccessToken(t, cfg, reporter.ID), nil)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("queue as a user: got %d, want 403", w.Code)
+	}
+
+	resolve := fmt.Sprintf("/admin/reports/%d/resolve", rep.ID)
+	modToken := testAccessToken(t, cfg, mod.ID)
+
+	// staff are suspended by admins changing their role, not through reports
+	setTestRole(t, cfg, author.ID, auth.RoleModerator)
+	w = doRequest(t, h, http.MethodPost, resolve, modToken, map[string]string{"action": database.ReportSuspendUser})
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("suspending staff: got %d %s, want 403", w.Code, w.Body.String())
+	}
+	setTestRole(t, cfg, author.ID, auth.RoleUser)
+
+	w = doRequest(t, h, http.MethodPost, resolve, modToken, map[string]string{"action": database.ReportDeleteChirp})
+	if w.Code != http.StatusOK {
+		t.Fatalf("resolve: got %d %s", w.Code, w.Body.String())
+	}
+
+	w = doRequest(t, h, http.MethodGet, fmt.Sprintf("/api/chirps/%d", c.ID), "", nil)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("reported chirp: got %d, want 404", w.Code)
+	}
+
+	w = doRequest(t, h, http.MethodPost, resolve, modToken, map[string]string{"action": database.ReportDismiss})
+	if w.Code != http.StatusConflict {
+		t.Fatalf("resolving again: got %d %s, want 409", w.Code, w.Body.String())
+	}
+}
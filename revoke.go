@@ -5,20 +5,24 @@ import (
 	"net/http"
 	"time"
 
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/hatrnuhn/chirpy-webserver/internal/auth"
 )
 
 func (cfg *apiConfig) handlePostRevoke(w http.ResponseWriter, r *http.Request) {
-	// reads rToken from Header
-	rToken, err := auth.ParseReq(r, cfg.jwtSecret, "Bearer")
+	// reads rToken from Header, or the refresh cookie
+	tokenString, err := refreshTokenString(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
 
+	rToken, err := auth.ParseToken(tokenString, cfg.jwtSecret)
 	if err != nil {
 		respondWithError(w, http.StatusUnauthorized, err.Error())
 		return
 	}
 
-	if claims, ok := rToken.Claims.(*jwt.RegisteredClaims); ok {
+	if claims, ok := rToken.Claims.(*auth.Claims); ok {
 		// verifs RJWT
 		if claims.Issuer != "chirpy-refresh" {
 			respondWithError(w, http.StatusUnauthorized, "invalid RJWT")
@@ -44,6 +48,10 @@ func (cfg *apiConfig) handlePostRevoke(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		if usesSessionCookies(r) {
+			cfg.clearSessionCookies(w)
+		}
+
 		// returns with StatusOK
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("OK"))
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hatrnuhn/chirpy-webserver/internal/auth"
+)
+
+// cookies of the session mode browser clients log in with, so that tokens never have to be readable from JavaScript
+const (
+	accessCookie  = "chirpy_access"
+	refreshCookie = "chirpy_refresh"
+	// the only one JavaScript can read, it's echoed back in csrfHeader on unsafe requests
+	csrfCookie = "chirpy_csrf"
+	csrfHeader = "X-CSRF-Token"
+)
+
+var errCSRF = errors.New("missing or mismatched CSRF token")
+
+type sessionCtxKey struct{}
+
+// how middlewareSessionCookies handled a request that carried session cookies
+type sessionState struct {
+	csrfFailed bool
+}
+
+// reports whether the request is authenticated with session cookies rather than an Authorization header
+func usesSessionCookies(r *http.Request) bool {
+	s, ok := r.Context().Value(sessionCtxKey{}).(sessionState)
+	return ok && !s.csrfFailed
+}
+
+func csrfFailed(r *http.Request) bool {
+	s, ok := r.Context().Value(sessionCtxKey{}).(sessionState)
+	return ok && s.csrfFailed
+}
+
+// cookies are only marked Secure when chirpy is served over https, browsers would drop them otherwise
+func (cfg *apiConfig) secureCookies() bool {
+	return strings.HasPrefix(cfg.baseURL, "https://")
+}
+
+// lets requests without an Authorization header authenticate with session cookies: unsafe methods must
+// carry the CSRF cookie's value in csrfHeader, then the access cookie is handed to the handlers as a bearer token.
+// unsafe requests without it aren't authenticated at all, so endpoints that need no credentials still work
+func (cfg *apiConfig) middlewareSessionCookies(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		aCookie, aErr := r.Cookie(accessCookie)
+		_, rErr := r.Cookie(refreshCookie)
+		if aErr != nil && rErr != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if !safeMethod(r.Method) && !validCSRF(r) {
+			ctx := context.WithValue(r.Context(), sessionCtxKey{}, sessionState{csrfFailed: true})
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+
+		if aErr == nil {
+			r.Header.Set("Authorization", "Bearer "+aCookie.Value)
+		}
+
+		ctx := context.WithValue(r.Context(), sessionCtxKey{}, sessionState{})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func safeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// double-submit check, a cross-site page can make the browser send the cookie but can't read it to set the header
+func validCSRF(r *http.Request) bool {
+	c, err := r.Cookie(csrfCookie)
+	if err != nil || c.Value == "" {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(c.Value), []byte(r.Header.Get(csrfHeader))) == 1
+}
+
+// returns the RJWT of the request, from the refresh cookie for session cookie requests and the Authorization header otherwise
+func refreshTokenString(r *http.Request) (string, error) {
+	if csrfFailed(r) {
+		return "", errCSRF
+	}
+
+	if usesSessionCookies(r) {
+		c, err := r.Cookie(refreshCookie)
+		if err != nil {
+			return "", errors.New("missing refresh cookie")
+		}
+		return c.Value, nil
+	}
+
+	tokenString, err := auth.GetAuthHeadToken(r, "Bearer")
+	if err != nil {
+		return "", errors.New("couldn't read request header")
+	}
+
+	return tokenString, nil
+}
+
+func (cfg *apiConfig) setAccessCookie(w http.ResponseWriter, aToken string, expiresInSecs int) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     accessCookie,
+		Value:    aToken,
+		Path:     "/",
+		MaxAge:   expiresInSecs,
+		HttpOnly: true,
+		Secure:   cfg.secureCookies(),
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+// sets the session cookies and returns the CSRF token the client has to send back on unsafe requests
+func (cfg *apiConfig) setSessionCookies(w http.ResponseWriter, aToken string, expiresInSecs int, rToken string, refreshExpiresInSecs int) (string, error) {
+	csrf, err := auth.MakeRandomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	cfg.setAccessCookie(w, aToken, expiresInSecs)
+
+	// only the endpoints that take an RJWT ever see it
+	http.SetCookie(w, &http.Cookie{
+		Name:     refreshCookie,
+		Value:    rToken,
+		Path:     "/api",
+		MaxAge:   refreshExpiresInSecs,
+		HttpOnly: true,
+		Secure:   cfg.secureCookies(),
+		SameSite: http.SameSiteStrictMode,
+	})
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     csrfCookie,
+		Value:    csrf,
+		Path:     "/",
+		MaxAge:   refreshExpiresInSecs,
+		Secure:   cfg.secureCookies(),
+		SameSite: http.SameSiteStrictMode,
+	})
+
+	return csrf, nil
+}
+
+func (cfg *apiConfig) clearSessionCookies(w http.ResponseWriter) {
+	for _, c := range []struct {
+		name string
+		path string
+	}{
+		{name: accessCookie, path: "/"},
+		{name: refreshCookie, path: "/api"},
+		{name: csrfCookie, path: "/"},
+	} {
+		http.SetCookie(w, &http.Cookie{
+			Name:     c.name,
+			Path:     c.path,
+			MaxAge:   -1,
+			Expires:  time.Unix(0, 0),
+			HttpOnly: c.name != csrfCookie,
+			Secure:   cfg.secureCookies(),
+			SameSite: http.SameSiteStrictMode,
+		})
+	}
+}
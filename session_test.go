@@ -0,0 +1,209 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// logs in through /api/login with session cookies and returns them along with the CSRF token
+func loginWithCookies(t *testing.T, h http.Handler, email string) ([]*http.Cookie, string) {
+	t.Helper()
+
+	w := doRequest(t, h, http.MethodPost, "/api/login", "", map[string]any{
+		"email":       email,
+		"password":    testPassword,
+		"use_cookies": true,
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("couldn't log in: %d %s", w.Code, w.Body.String())
+	}
+
+	resp := struct {
+		CSRFToken string `json:"csrf_token"`
+	}{}
+	decodeBody(t, w, &resp)
+	if strings.Contains(w.Body.String(), "access_token") {
+		t.Fatalf("tokens leaked into the body: %s", w.Body.String())
+	}
+
+	return w.Result().Cookies(), resp.CSRFToken
+}
+
+// sends a request carrying cookies and csrf in csrfHeader if it's set
+func doCookieRequest(t *testing.T, h http.Handler, method, path string, cookies []*http.Cookie, csrf, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+	if csrf != "" {
+		req.Header.Set(csrfHeader, csrf)
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func TestLoginSetsSessionCookies(t *testing.T) {
+	cfg := newTestConfig(t)
+	h := cfg.routes()
+	u := createTestUser(t, cfg, "walt@example.com")
+
+	cookies, csrf := loginWithCookies(t, h, u.Email)
+	if csrf == "" {
+		t.Fatal("no CSRF token in the response")
+	}
+
+	got := make(map[string]*http.Cookie)
+	for _, c := range cookies {
+		got[c.Name] = c
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		httpOnly bool
+	}{
+		{accessCookie, "/", true},
+		{refreshCookie, "/api", true},
+		// the page has to read it to send it back
+		{csrfCookie, "/", false},
+	}
+	for _, tc := range tests {
+		c, ok := got[tc.name]
+		if !ok {
+			t.Fatalf("%s wasn't set", tc.name)
+		}
+		if c.Path != tc.path || c.HttpOnly != tc.httpOnly || c.SameSite != http.SameSiteStrictMode {
+			t.Errorf("%s: got path %s, HttpOnly %v, SameSite %v", tc.name, c.Path, c.HttpOnly, c.SameSite)
+		}
+		// chirpy.test is served over http, browsers would drop Secure cookies
+		if c.Secure {
+			t.Errorf("%s is Secure on an http base URL", tc.name)
+		}
+	}
+
+	if got[csrfCookie].Value != csrf {
+		t.Error("CSRF cookie doesn't hold the token in the response")
+	}
+}
+
+func TestSessionCookieCSRF(t *testing.T) {
+	cfg := newTestConfig(t)
+	h := cfg.routes()
+	u := createTestUser(t, cfg, "walt@example.com")
+	cookies, csrf := loginWithCookies(t, h, u.Email)
+
+	// safe requests need no CSRF token
+	w := doCookieRequest(t, h, http.MethodGet, "/api/users/me", cookies, "", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET with cookies: got %d %s", w.Code, w.Body.String())
+	}
+
+	chirp := `{"body":"cookies are great"}`
+	tests := []struct {
+		name string
+		csrf string
+		want int
+	}{
+		{"missing token", "", http.StatusForbidden},
+		{"mismatched token", csrf + "x", http.StatusForbidden},
+		{"matching token", csrf, http.StatusCreated},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := doCookieRequest(t, h, http.MethodPost, "/api/chirps", cookies, tc.csrf, chirp)
+			if w.Code != tc.want {
+				t.Fatalf("got %d %s, want %d", w.Code, w.Body.String(), tc.want)
+			}
+		})
+	}
+
+	// a cross-site page can't read the CSRF cookie, sending it as is doesn't make up for the header
+	withoutCSRF := make([]*http.Cookie, 0)
+	for _, c := range cookies {
+		if c.Name != csrfCookie {
+			withoutCSRF = append(withoutCSRF, c)
+		}
+	}
+	w = doCookieRequest(t, h, http.MethodPost, "/api/chirps", withoutCSRF, csrf, chirp)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("header without the cookie: got %d, want 403", w.Code)
+	}
+}
+
+func TestSessionCookiesIgnoredWithAuthorizationHeader(t *testing.T) {
+	cfg := newTestConfig(t)
+	h := cfg.routes()
+	u := createTestUser(t, cfg, "walt@example.com")
+	other := createTestUser(t, cfg, "jesse@example.com")
+	cookies, _ := loginWithCookies(t, h, u.Email)
+
+	// the header wins, and header clients aren't held to the CSRF check
+	req := httptest.NewRequest(http.MethodPost, "/api/chirps", strings.NewReader(`{"body":"from the header"}`))
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+	req.Header.Set("Authorization", "Bearer "+testAccessToken(t, cfg, other.ID))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("got %d %s, want 201", w.Code, w.Body.String())
+	}
+
+	resp := struct {
+		UserID int `json:"user_id"`
+	}{}
+	decodeBody(t, w, &resp)
+	if resp.UserID != other.ID {
+		t.Fatalf("chirp was posted by %d, want the header's user %d", resp.UserID, other.ID)
+	}
+}
+
+func TestSessionCookieRefreshAndLogout(t *testing.T) {
+	cfg := newTestConfig(t)
+	h := cfg.routes()
+	u := createTestUser(t, cfg, "walt@example.com")
+	cookies, csrf := loginWithCookies(t, h, u.Email)
+
+	w := doCookieRequest(t, h, http.MethodPost, "/api/refresh", cookies, "", "")
+	if w.Code != http.StatusUnauthorized || !strings.Contains(w.Body.String(), errCSRF.Error()) {
+		t.Fatalf("refresh without CSRF token: got %d %s, want 401", w.Code, w.Body.String())
+	}
+
+	w = doCookieRequest(t, h, http.MethodPost, "/api/refresh", cookies, csrf, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("refresh: got %d %s", w.Code, w.Body.String())
+	}
+	refreshed := false
+	for _, c := range w.Result().Cookies() {
+		refreshed = refreshed || (c.Name == accessCookie && c.Value != "")
+	}
+	if !refreshed {
+		t.Fatal("refresh didn't set a new access cookie")
+	}
+
+	w = doCookieRequest(t, h, http.MethodPost, "/api/logout", cookies, csrf, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("logout: got %d %s", w.Code, w.Body.String())
+	}
+	for _, c := range w.Result().Cookies() {
+		if c.MaxAge >= 0 {
+			t.Errorf("%s wasn't cleared", c.Name)
+		}
+	}
+
+	// the session's tokens are revoked, not just forgotten by the browser
+	w = doCookieRequest(t, h, http.MethodGet, "/api/users/me", cookies, "", "")
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("access cookie after logout: got %d, want 401", w.Code)
+	}
+	w = doCookieRequest(t, h, http.MethodPost, "/api/refresh", cookies, csrf, "")
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("refresh cookie after logout: got %d, want 401", w.Code)
+	}
+}
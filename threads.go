@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+	"github.com/hatrnuhn/chirpy-webserver/internal/auth"
+	"github.com/hatrnuhn/chirpy-webserver/internal/database"
+)
+
+// a chirp in a conversation tree, tombstones of deleted chirps are kept so their replies stay in place.
+// so are chirps the viewer's blocks hide, they're marked unavailable and only keep their place too
+type threadNode struct {
+	chirpResponse
+	Unavailable bool          `json:"unavailable,omitempty"`
+	ReplyCount  int           `json:"reply_count"`
+	Replies     []*threadNode `json:"replies"`
+}
+
+// responds with the whole conversation the chirp belongs to as a tree, starting from the chirp that started it
+func (cfg *apiConfig) handleGetChirpThread(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "bad url")
+		return
+	}
+
+	p, err := cfg.authenticateOptional(r, auth.ScopeChirpsRead)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	chirps, err := cfg.db.GetConversation(id)
+	if errors.Is(err, database.ErrNotExist) {
+		respondWithError(w, http.StatusNotFound, fmt.Sprintf("chirp with id: %v is not found", id))
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't get thread")
+		return
+	}
+
+	hidden := make(map[int]bool)
+	if p.UserID != 0 {
+		hidden, err = cfg.db.HiddenChirps(p.UserID, chirps, false)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "couldn't get thread")
+			return
+		}
+	}
+	if hidden[id] {
+		respondWithError(w, http.StatusNotFound, fmt.Sprintf("chirp with id: %v is not found", id))
+		return
+	}
+
+	for i, c := range chirps {
+		if hidden[c.ID] {
+			chirps[i] = database.Chirp{ID: c.ID, InReplyToID: c.InReplyToID, ConversationID: c.ConversationID}
+		}
+	}
+
+	resp, err := cfg.newChirpResponses(chirps)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't get thread")
+		return
+	}
+
+	root := buildThread(resp, hidden)
+	if root == nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't build thread")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, root)
+}
+
+// links chirps sorted by ID into a tree, replies always come after what they reply to. the chirps
+// in unavailable are marked so
+func buildThread(chirps []chirpResponse, unavailable map[int]bool) *threadNode {
+	nodes := make(map[int]*threadNode, len(chirps))
+	var root *threadNode
+	for _, c := range chirps {
+		n := &threadNode{chirpResponse: c, Unavailable: unavailable[c.ID], Replies: make([]*threadNode, 0)}
+		nodes[c.ID] = n
+
+		parent, ok := nodes[c.InReplyToID]
+		if !ok {
+			root = n
+			continue
+		}
+		parent.Replies = append(parent.Replies, n)
+		parent.ReplyCount++
+	}
+
+	return root
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/hatrnuhn/chirpy-webserver/internal/auth"
+)
+
+// responds with a page of the authenticated user's home timeline, their chirps and those of who they
+// follow, newest first
+func (cfg *apiConfig) handleGetTimelineHome(w http.ResponseWriter, r *http.Request) {
+	p, err := cfg.authenticate(r, auth.ScopeChirpsRead)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	limit, offset, err := parsePage(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	chirps, err := cfg.db.GetHomeTimeline(p.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't get timeline")
+		return
+	}
+
+	start, end := pageBounds(len(chirps), limit, offset)
+	resp, err := cfg.newChirpResponses(chirps[start:end])
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't get timeline")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, struct {
+		Total  int             `json:"total"`
+		Chirps []chirpResponse `json:"chirps"`
+	}{
+		Total:  len(chirps),
+		Chirps: resp,
+	})
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hatrnuhn/chirpy-webserver/internal/auth"
+)
+
+const recoveryCodeCount = 10
+
+// starts TOTP enrollment for the authenticated user, responds with the secret and its otpauth URI.
+// the secret isn't enforced on login until it's confirmed with a code
+func (cfg *apiConfig) handlePostUsersMe2FA(w http.ResponseWriter, r *http.Request) {
+	uID, err := cfg.authUserID(r)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	user, err := cfg.db.GetUser(uID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "couldn't get user")
+		return
+	}
+
+	if user.TOTPEnabled {
+		respondWithError(w, http.StatusConflict, "two-factor authentication is already enabled")
+		return
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("couldn't create TOTP secret: %s", err.Error()))
+		return
+	}
+
+	user.TOTPSecret = secret
+	_, err = cfg.db.UpdateUser(&user, false)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("couldn't update user: %s", err.Error()))
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, struct {
+		Secret     string `json:"secret"`
+		OTPAuthURI string `json:"otpauth_uri"`
+	}{
+		Secret:     secret,
+		OTPAuthURI: auth.TOTPURI("Chirpy", user.Email, secret),
+	})
+}
+
+// confirms TOTP enrollment with a code from the authenticator, enables 2FA and responds with the
+// recovery codes, they're stored hashed so this is the only time they can be seen
+func (cfg *apiConfig) handlePostUsersMe2FAConfirm(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	uID, err := cfg.authUserID(r)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	dat, err := io.ReadAll(r.Body)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "couldn't read request")
+		return
+	}
+
+	req := struct {
+		Code string `json:"code"`
+	}{}
+	err = json.Unmarshal(dat, &req)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "couldn't unmarshal request")
+		return
+	}
+
+	user, err := cfg.db.GetUser(uID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "couldn't get user")
+		return
+	}
+
+	if user.TOTPEnabled {
+		respondWithError(w, http.StatusConflict, "two-factor authentication is already enabled")
+		return
+	}
+
+	if user.TOTPSecret == "" {
+		respondWithError(w, http.StatusBadRequest, "two-factor authentication enrollment hasn't been started")
+		return
+	}
+
+	step, ok := auth.ValidateTOTP(user.TOTPSecret, req.Code, time.Now())
+	if !ok || step <= user.TOTPLastStep {
+		respondWithError(w, http.StatusUnauthorized, "invalid TOTP code")
+		return
+	}
+
+	codes, err := auth.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("couldn't create recovery codes: %s", err.Error()))
+		return
+	}
+
+	hashes := make([]string, 0, len(codes))
+	for _, c := range codes {
+		hashes = append(hashes, auth.HashToken(c))
+	}
+
+	user.TOTPEnabled = true
+	user.TOTPLastStep = step
+	user.RecoveryCodes = hashes
+	_, err = cfg.db.UpdateUser(&user, false)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("couldn't update user: %s", err.Error()))
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}{
+		RecoveryCodes: codes,
+	})
+}
+
+// recovery codes are handed out lowercase, users tend to type them however they like
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.TrimSpace(code))
+}
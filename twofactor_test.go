@@ -0,0 +1,105 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/hatrnuhn/chirpy-webserver/internal/auth"
+)
+
+// logs in with testPassword and returns the MFA challenge token
+func loginTestChallenge(t *testing.T, h http.Handler, email string) string {
+	t.Helper()
+
+	l := loginTestUser(t, h, email)
+	if !l.MFARequired || l.MFAToken == "" || l.AccessToken != "" {
+		t.Fatalf("got %+v, want an MFA challenge", l)
+	}
+
+	return l.MFAToken
+}
+
+func TestTOTPLogin(t *testing.T) {
+	cfg := newTestConfig(t)
+	h := cfg.routes()
+	u := createTestUser(t, cfg, "walt@example.com")
+	aToken := testAccessToken(t, cfg, u.ID)
+
+	w := doRequest(t, h, http.MethodPost, "/api/users/me/2fa", aToken, nil)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("enroll: got %d %s", w.Code, w.Body.String())
+	}
+	enroll := struct {
+		Secret string `json:"secret"`
+		URI    string `json:"otpauth_uri"`
+	}{}
+	decodeBody(t, w, &enroll)
+	if enroll.Secret == "" || enroll.URI == "" {
+		t.Fatalf("enroll: got %q, want a secret and its URI", w.Body.String())
+	}
+
+	// not enabled until it's confirmed
+	l := loginTestUser(t, h, u.Email)
+	if l.MFARequired || l.AccessToken == "" {
+		t.Fatalf("before confirming: got %+v, want tokens", l)
+	}
+
+	code, err := auth.TOTPCode(enroll.Secret, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	w = doRequest(t, h, http.MethodPost, "/api/users/me/2fa/confirm", aToken, map[string]string{"code": code})
+	if w.Code != http.StatusOK {
+		t.Fatalf("confirm: got %d %s", w.Code, w.Body.String())
+	}
+	confirm := struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}{}
+	decodeBody(t, w, &confirm)
+	if len(confirm.RecoveryCodes) != recoveryCodeCount {
+		t.Fatalf("confirm: got %d recovery codes, want %d", len(confirm.RecoveryCodes), recoveryCodeCount)
+	}
+
+	// the confirming code's step is spent, the next one is still within the allowed drift
+	code, err = auth.TOTPCode(enroll.Secret, time.Now().Add(30*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w = doRequest(t, h, http.MethodPost, "/api/login/mfa", "", map[string]string{"mfa_token": loginTestChallenge(t, h, u.Email), "code": code})
+	if w.Code != http.StatusOK {
+		t.Fatalf("MFA login: got %d %s", w.Code, w.Body.String())
+	}
+	l = testLogin{}
+	decodeBody(t, w, &l)
+	if l.AccessToken == "" || l.RefreshToken == "" {
+		t.Fatalf("MFA login: got %q, want an AJWT and RJWT", w.Body.String())
+	}
+
+	w = doRequest(t, h, http.MethodGet, "/api/users/me", l.AccessToken, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("AJWT from MFA login: got %d %s, want 200", w.Code, w.Body.String())
+	}
+
+	w = doRequest(t, h, http.MethodPost, "/api/login/mfa", "", map[string]string{"mfa_token": loginTestChallenge(t, h, u.Email), "code": code})
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("reused TOTP step: got %d %s, want 401", w.Code, w.Body.String())
+	}
+
+	recovery := map[string]string{"mfa_token": loginTestChallenge(t, h, u.Email), "recovery_code": confirm.RecoveryCodes[0]}
+	w = doRequest(t, h, http.MethodPost, "/api/login/mfa", "", recovery)
+	if w.Code != http.StatusOK {
+		t.Fatalf("recovery code: got %d %s", w.Code, w.Body.String())
+	}
+
+	recovery["mfa_token"] = loginTestChallenge(t, h, u.Email)
+	w = doRequest(t, h, http.MethodPost, "/api/login/mfa", "", recovery)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("used recovery code: got %d %s, want 401", w.Code, w.Body.String())
+	}
+
+	w = doRequest(t, h, http.MethodPost, "/api/users/me/2fa", aToken, nil)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("enrolling again: got %d %s, want 409", w.Code, w.Body.String())
+	}
+}
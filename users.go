@@ -5,9 +5,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"strconv"
 
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/hatrnuhn/chirpy-webserver/internal/auth"
 	"github.com/hatrnuhn/chirpy-webserver/internal/database"
 )
@@ -37,6 +35,18 @@ func (cfg *apiConfig) handlePostUsers(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// the password is read on its own so it never makes it into the response
+	creds := reqBody{}
+	err = json.Unmarshal(dat, &creds)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "couldn't unmarshal request")
+		return
+	}
+
+	if !cfg.checkPasswordPolicy(w, creds.Password, req.Email) {
+		return
+	}
+
 	users, err := cfg.db.GetUsers()
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "couldn't get users")
@@ -64,43 +74,58 @@ func (cfg *apiConfig) handlePostUsers(w http.ResponseWriter, r *http.Request) {
 func (cfg *apiConfig) handlePutUsers(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 
-	token, err := auth.ParseReq(r, cfg.jwtSecret, "Bearer")
+	p, err := cfg.authenticate(r, auth.ScopeProfileWrite)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, err.Error())
+		respondWithAuthError(w, err)
 		return
 	}
 
-	if claims, ok := token.Claims.(*jwt.RegisteredClaims); ok {
-		if claims.Issuer != "chirpy-access" {
-			respondWithError(w, http.StatusUnauthorized, "invalid AJWT")
-			return
-		}
+	dat, err := io.ReadAll(r.Body)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("couldn't read request: %s", err.Error()))
+		return
+	}
+	req := database.User{}
+	err = json.Unmarshal(dat, &req)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("couldn't unmarshal request: %s", err.Error()))
+		return
+	}
 
-		if !ok {
-			respondWithError(w, http.StatusUnauthorized, "access token is expired")
-			return
-		}
+	if p.ActorID != 0 {
+		respondWithAuthError(w, errImpersonated)
+		return
+	}
 
-		dat, err := io.ReadAll(r.Body)
-		if err != nil {
-			respondWithError(w, 500, fmt.Sprintf("couldn't read request: %s", err.Error()))
-			return
-		}
-		req := database.User{}
-		err = json.Unmarshal(dat, &req)
-		if err != nil {
-			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("couldn't unmarshal request: %s", err.Error()))
-			return
-		}
+	// a leaked personal access token or third-party token shouldn't be enough to take the account over
+	if !p.firstParty() && req.Password != "" {
+		respondWithError(w, http.StatusForbidden, "password can only be changed with a first-party access token")
+		return
+	}
+
+	if len(req.Email) > 140 {
+		respondWithError(w, http.StatusBadRequest, "email address is too long!")
+		return
+	}
 
-		if len(req.Email) > 140 {
-			respondWithError(w, http.StatusBadRequest, "email address is too long!")
+	// only email and password can be changed here, the rest of the user is kept as is
+	user, err := cfg.db.GetUser(p.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, fmt.Sprintf("couldn't get user: %s", err.Error()))
+		return
+	}
+
+	// whoever controls the email can reset the password through it, so it's guarded the same way
+	if req.Email != "" && req.Email != user.Email {
+		if !p.firstParty() {
+			respondWithError(w, http.StatusForbidden, "email can only be changed with a first-party access token")
 			return
 		}
 
 		users, err := cfg.db.GetUsers()
 		if err != nil {
 			respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("couldn't get users: %s", err.Error()))
+			return
 		}
 
 		for _, u := range users {
@@ -110,29 +135,85 @@ func (cfg *apiConfig) handlePutUsers(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 
-		id, err := strconv.Atoi(claims.Subject)
-		if err != nil {
-			respondWithJSON(w, http.StatusBadRequest, "couldn't get id")
+		user.Email = req.Email
+	}
+
+	newPw := req.Password != ""
+	if newPw {
+		if !cfg.checkPasswordPolicy(w, req.Password, user.Email) {
 			return
 		}
+		user.Password = req.Password
+	}
 
-		req.ID = id
+	resp, err := cfg.db.UpdateUser(&user, newPw)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("couldn't update : %s", err.Error()))
+		return
+	}
 
-		resp, err := cfg.db.UpdateUser(&req, true)
+	// whoever knew the old password may be signed in somewhere, this one included
+	if newPw {
+		err = cfg.logOutEverywhere(user.ID)
 		if err != nil {
-			respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("couldn't update : %s", err.Error()))
+			respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("couldn't revoke tokens: %s", err.Error()))
 			return
 		}
-		respondWithJSON(w, http.StatusOK, struct {
-			ID    int    `json:"id"`
-			Email string `json:"email"`
-		}{
-			ID:    resp.ID,
-			Email: resp.Email,
-		})
+	}
+	respondWithJSON(w, http.StatusOK, struct {
+		ID    int    `json:"id"`
+		Email string `json:"email"`
+	}{
+		ID:    resp.ID,
+		Email: resp.Email,
+	})
+}
+
+// returns the authenticated user's profile as it's stored, including their role and plan, which may have
+// changed since their AJWT was issued
+func (cfg *apiConfig) handleGetUsersMe(w http.ResponseWriter, r *http.Request) {
+	p, err := cfg.authenticate(r, auth.ScopeProfileRead)
+	if err != nil {
+		respondWithAuthError(w, err)
 		return
-	} else {
-		respondWithError(w, http.StatusUnauthorized, "invalid AJWT token")
+	}
+
+	user, err := cfg.db.GetUser(p.UserID)
+	if err != nil || user.DeletedAt != 0 {
+		respondWithError(w, http.StatusNotFound, "couldn't get user")
 		return
 	}
+
+	// a role that isn't known grants nothing, it's shown as stored
+	role := user.Role
+	roles := auth.EffectiveRoles(user.Role)
+	if len(roles) > 0 {
+		role = roles[len(roles)-1]
+	}
+
+	type settings struct {
+		TwoFactorEnabled bool `json:"two_factor_enabled"`
+		OIDCLinked       bool `json:"oidc_linked"`
+	}
+
+	respondWithJSON(w, http.StatusOK, struct {
+		ID          int      `json:"id"`
+		Email       string   `json:"email"`
+		IsChirpyRed bool     `json:"is_chirpy_red"`
+		Plan        string   `json:"plan"`
+		Role        string   `json:"role"`
+		Roles       []string `json:"roles"`
+		Settings    settings `json:"settings"`
+	}{
+		ID:          user.ID,
+		Email:       user.Email,
+		IsChirpyRed: user.IsChirpyRed,
+		Plan:        auth.PlanOf(user.IsChirpyRed),
+		Role:        role,
+		Roles:       roles,
+		Settings: settings{
+			TwoFactorEnabled: user.TOTPEnabled,
+			OIDCLinked:       user.OIDCSubject != "",
+		},
+	})
 }
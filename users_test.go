@@ -0,0 +1,122 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/hatrnuhn/chirpy-webserver/internal/auth"
+)
+
+func TestPutUsersPasswordNeedsFirstParty(t *testing.T) {
+	cfg := newTestConfig(t)
+	h := cfg.routes()
+	u := createTestUser(t, cfg, "walt@example.com")
+	admin := createTestUser(t, cfg, "skyler@example.com")
+
+	tests := []struct {
+		name  string
+		token string
+		want  int
+	}{
+		{"personal access token", testPAT(t, cfg, u.ID, auth.ScopeProfileWrite), http.StatusForbidden},
+		{"third-party token", testOAuthToken(t, cfg, u.ID, auth.ScopeProfileWrite), http.StatusForbidden},
+		{"impersonation token", testImpersonationToken(t, cfg, u.ID, admin.ID), http.StatusForbidden},
+		{"access token", testAccessToken(t, cfg, u.ID), http.StatusOK},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := doRequest(t, h, http.MethodPut, "/api/users", tc.token, map[string]string{
+				"email":    u.Email,
+				"password": "an0ther-Chirp-at-dusk",
+			})
+			if w.Code != tc.want {
+				t.Fatalf("got %d %q, want %d", w.Code, w.Body.String(), tc.want)
+			}
+		})
+	}
+}
+
+func TestPutUsersEmailNeedsFirstParty(t *testing.T) {
+	cfg := newTestConfig(t)
+	h := cfg.routes()
+	u := createTestUser(t, cfg, "walt@example.com")
+
+	tests := []struct {
+		name  string
+		token string
+		email string
+		want  int
+	}{
+		{"personal access token", testPAT(t, cfg, u.ID, auth.ScopeProfileWrite), "heisenberg@example.com", http.StatusForbidden},
+		{"third-party token", testOAuthToken(t, cfg, u.ID, auth.ScopeProfileWrite), "heisenberg@example.com", http.StatusForbidden},
+		{"personal access token keeping the email", testPAT(t, cfg, u.ID, auth.ScopeProfileWrite), u.Email, http.StatusOK},
+		{"access token", testAccessToken(t, cfg, u.ID), "heisenberg@example.com", http.StatusOK},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := doRequest(t, h, http.MethodPut, "/api/users", tc.token, map[string]string{"email": tc.email})
+			if w.Code != tc.want {
+				t.Fatalf("got %d %q, want %d", w.Code, w.Body.String(), tc.want)
+			}
+		})
+	}
+
+	got, err := cfg.db.GetUser(u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Email != "heisenberg@example.com" {
+		t.Fatalf("email is %s, want it changed by the access token only", got.Email)
+	}
+}
+
+func TestPutUsersEmailTaken(t *testing.T) {
+	cfg := newTestConfig(t)
+	h := cfg.routes()
+	u := createTestUser(t, cfg, "walt@example.com")
+	other := createTestUser(t, cfg, "jesse@example.com")
+
+	w := doRequest(t, h, http.MethodPut, "/api/users", testAccessToken(t, cfg, u.ID), map[string]string{"email": other.Email})
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("got %d %q, want 400", w.Code, w.Body.String())
+	}
+}
+
+func TestGetUsersMeRoles(t *testing.T) {
+	cfg := newTestConfig(t)
+	h := cfg.routes()
+	u := createTestUser(t, cfg, "walt@example.com")
+
+	tests := []struct {
+		stored string
+		role   string
+		roles  []string
+	}{
+		{"", auth.RoleUser, []string{auth.RoleUser}},
+		{auth.RoleModerator, auth.RoleModerator, []string{auth.RoleUser, auth.RoleModerator}},
+		{"superuser", "superuser", []string{}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.stored, func(t *testing.T) {
+			setTestRole(t, cfg, u.ID, tc.stored)
+
+			w := doRequest(t, h, http.MethodGet, "/api/users/me", testAccessToken(t, cfg, u.ID), nil)
+			if w.Code != http.StatusOK {
+				t.Fatalf("got %d %q, want %d", w.Code, w.Body.String(), http.StatusOK)
+			}
+
+			resp := struct {
+				Role  string   `json:"role"`
+				Roles []string `json:"roles"`
+			}{}
+			decodeBody(t, w, &resp)
+			if resp.Role != tc.role || !reflect.DeepEqual(resp.Roles, tc.roles) {
+				t.Errorf("got role %q and roles %v, want %q and %v", resp.Role, resp.Roles, tc.role, tc.roles)
+			}
+		})
+	}
+}